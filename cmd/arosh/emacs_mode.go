@@ -16,7 +16,12 @@ func NewEmacsMode() *EmacsMode {
 
 func (e *EmacsMode) Setup(shell *Arosh) {
 	e.shell = shell
-	e.editor = shell.editor
+	e.bind(shell.editor)
+}
+
+// bind sets the emacs keys up on editor.
+func (e *EmacsMode) bind(editor *lineEditor.LineEditor) {
+	e.editor = editor
 
 	e.editor.Bind(e.moveLeft, "Move the cursor to the left", "left", "ctrl+b")
 	e.editor.Bind(e.moveRight, "Move the cursor to the right", "right", "ctrl+f")
@@ -28,45 +33,45 @@ func (e *EmacsMode) Setup(shell *Arosh) {
 }
 
 func (e *EmacsMode) deleteBehind() (tea.Model, tea.Cmd) {
-	e.shell.editor.Delete(e.editor.Position() - 1)
-	return e.shell.editor, nil
+	e.editor.Delete(e.editor.Position() - 1)
+	return e.editor, nil
 }
 
 func (e *EmacsMode) deleteAllBehind() (tea.Model, tea.Cmd) {
-	tail := []rune(e.shell.editor.Line())[e.shell.editor.Position():]
+	tail := []rune(e.editor.Line())[e.editor.Position():]
 
-	e.shell.editor.SetLine(string(tail))
+	e.editor.SetLine(string(tail))
 	e.editor.SetPostion(0)
 
-	return e.shell.editor, nil
+	return e.editor, nil
 }
 
 func (e *EmacsMode) deleteAllAhead() (tea.Model, tea.Cmd) {
-	position := e.shell.editor.Position()
-	head := []rune(e.shell.editor.Line())[:position]
+	position := e.editor.Position()
+	head := []rune(e.editor.Line())[:position]
 
-	e.shell.editor.SetLine(string(head))
+	e.editor.SetLine(string(head))
 	e.editor.SetPostion(position)
 
-	return e.shell.editor, nil
+	return e.editor, nil
 }
 
 func (e *EmacsMode) moveLeft() (tea.Model, tea.Cmd) {
 	e.editor.MoveN(e.editor.Position() - 1)
-	return e.shell.editor, nil
+	return e.editor, nil
 }
 
 func (e *EmacsMode) moveRight() (tea.Model, tea.Cmd) {
 	e.editor.MoveN(e.editor.Position() + 1)
-	return e.shell.editor, nil
+	return e.editor, nil
 }
 
 func (e *EmacsMode) startOfLine() (tea.Model, tea.Cmd) {
 	e.editor.MoveN(0)
-	return e.shell.editor, nil
+	return e.editor, nil
 }
 
 func (e *EmacsMode) endOfLine() (tea.Model, tea.Cmd) {
 	e.editor.MoveN(len(e.editor.Line()))
-	return e.shell.editor, nil
+	return e.editor, nil
 }
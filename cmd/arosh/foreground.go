@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/marcos-brito/arosh/internal/interpreter"
+)
+
+// foreground implements tea.ExecCommand, so bubbletea can release the
+// terminal while a program runs.
+type foreground struct {
+	interpreter *interpreter.Interpreter
+	program     *interpreter.Program
+}
+
+func (f *foreground) Run() error {
+	f.interpreter.Run(f.program)
+	return nil
+}
+
+func (f *foreground) SetStdin(reader io.Reader) {
+	if file, ok := reader.(*os.File); ok {
+		f.interpreter.Stdin = file
+	}
+}
+
+func (f *foreground) SetStdout(writer io.Writer) {
+	if file, ok := writer.(*os.File); ok {
+		f.interpreter.Stdout = file
+	}
+}
+
+func (f *foreground) SetStderr(writer io.Writer) {
+	if file, ok := writer.(*os.File); ok {
+		f.interpreter.Stderr = file
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/marcos-brito/arosh/internal/interpreter"
 	"github.com/marcos-brito/arosh/internal/line_editor"
+	"golang.org/x/term"
 )
 
 type Plugin interface {
@@ -15,63 +19,197 @@ type Plugin interface {
 }
 
 type Arosh struct {
-	editor  *lineEditor.LineEditor
-	Plugins []Plugin
+	editor      *lineEditor.LineEditor
+	interpreter *interpreter.Interpreter
+	Plugins     []Plugin
 }
 
 func NewShell() *Arosh {
-	return &Arosh{
-		editor:  lineEditor.New(),
-		Plugins: []Plugin{},
+	return newShell(interpreter.New())
+}
+
+func newShell(interpreter *interpreter.Interpreter) *Arosh {
+	shell := &Arosh{
+		editor:      lineEditor.New(),
+		interpreter: interpreter,
+		Plugins:     []Plugin{},
 	}
 
+	shell.interpreter.Editor = shell.ReadLine
+
+	return shell
 }
 
 func main() {
-	shell := NewShell()
+	interpreter := interpreter.New()
+	invocation, err := parseInvocation(os.Args[1:], &interpreter.Options)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "arosh: %s\n%s\n", err, usage)
+		os.Exit(2)
+	}
+
+	invocation.login = invocation.login || strings.HasPrefix(os.Args[0], "-")
+
+	// Scripts are run without the line editor, which is only for a user at
+	// a terminal.
+	if !invocation.interactive(term.IsTerminal(int(os.Stdin.Fd()))) {
+		if !invocation.startup(interpreter, false) {
+			exit(interpreter)
+		}
+
+		os.Exit(invocation.run(interpreter))
+	}
+
+	interpreter.SetPositional(invocation.operands)
+
+	shell := newShell(interpreter)
 	shell.AddPlugin(NewEmacsMode())
 	shell.setupPlugins()
 	shell.editor.Bind(shell.AcceptLine, "Accepts the line", "enter")
-	shell.editor.Bind(shell.Quit, "Quit", "ctrl+c")
+	shell.editor.Bind(shell.Interrupt, "Drops the line", "ctrl+c")
+	shell.editor.Bind(shell.EndOfInput, "Quit on an empty line", "ctrl+d")
 	shell.editor.Bind(shell.Clear, "Clear the screen", "ctrl+l")
 
 	app := tea.NewProgram(shell.editor)
 
+	// Jobs that stop or end while the user is typing are reported above
+	// the prompt, which the editor is asked to redraw for it.
+	interpreter.SetInteractive()
+
+	notify := func(message string) {
+		shell.editor.Notify(message)
+		go app.Send(lineEditor.NotifyMsg{})
+	}
+
+	if err := interpreter.StartJobControl(os.Stdin, notify); err != nil {
+		fmt.Fprintf(os.Stderr, "arosh: no job control: %s\n", err)
+	}
+
+	if !invocation.startup(interpreter, true) {
+		exit(interpreter)
+	}
+
 	if _, err := app.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
 	}
+
+	shell.interpreter.RunExitTrap()
+
+	if shell.interpreter.Exited() {
+		os.Exit(shell.interpreter.Status())
+	}
+}
+
+// exit runs the EXIT trap and exits with the status of the shell.
+func exit(shell *interpreter.Interpreter) {
+	shell.RunExitTrap()
+	os.Exit(shell.Status())
 }
 
 func (sh *Arosh) AcceptLine() (tea.Model, tea.Cmd) {
+	source := sh.editor.Line()
+	// The line is parsed with the newline that accepted it, so something
+	// like "echo >" is an error right away instead of waiting for more input.
+	lexer := interpreter.NewLexer(source + "\n")
+	parser := interpreter.NewParser(lexer)
+
+	program, err := parser.Parse()
+
+	// Keep editing on a new line until the command is complete, like when a
+	// here-document is waiting for its delimiter or an if for its fi.
+	if errors.Is(err, interpreter.ErrIncomplete) {
+		sh.editor.SetLine(source + "\n")
+		return sh.editor, nil
+	}
+
 	defer func() {
 		sh.editor.SetLine("")
 	}()
 
-	source := sh.editor.Line()
-	lexer := interpreter.NewLexer(source)
-	parser := interpreter.NewParser(lexer)
+	if err != nil {
+		return sh.Errorln(err)
+	}
+
+	return sh.Exec(program)
+}
 
-	ast, err := parser.Parse()
+// Exec runs program in the foreground. The terminal is handed over to the
+// program while it runs and given back to the editor when it's done, unless
+// the program ran exit.
+func (sh *Arosh) Exec(program *interpreter.Program) (tea.Model, tea.Cmd) {
+	line := sh.editor.PromptedLine()
+	style := lipgloss.NewStyle().Width(sh.editor.Width())
+	job := &foreground{interpreter: sh.interpreter, program: program}
+	done := func(error) tea.Msg {
+		if sh.interpreter.Exited() {
+			return tea.Quit()
+		}
 
-	if err != nil {
-		return sh.Errorln(err.Error())
+		return nil
+	}
+
+	return sh.editor, tea.Sequence(tea.Println(style.Render(line)), tea.Exec(job, done))
+}
+
+// ReadLine lets the user edit a line for the read builtin. It runs an
+// editor of its own, with the emacs keys, since the one of the command line
+// has given the terminal away while commands run.
+func (sh *Arosh) ReadLine(prompt string) (string, error) {
+	editor := lineEditor.New()
+	editor.SetPrompt(prompt)
+	NewEmacsMode().bind(editor)
+
+	var err error
+	done := func() (tea.Model, tea.Cmd) {
+		style := lipgloss.NewStyle().Width(editor.Width())
+		return editor, tea.Sequence(tea.Println(style.Render(editor.PromptedLine())), tea.Quit)
 	}
 
-	return sh.Println(ast.String())
+	editor.Bind(done, "Accepts the line", "enter")
+	editor.Bind(func() (tea.Model, tea.Cmd) {
+		if editor.Line() != "" {
+			return editor, nil
+		}
+
+		err = io.EOF
+		return done()
+	}, "Ends the input on an empty line", "ctrl+d")
+	editor.Bind(func() (tea.Model, tea.Cmd) {
+		err = errInterrupted
+		return done()
+	}, "Cancels reading", "ctrl+c")
+
+	if _, runErr := tea.NewProgram(editor, tea.WithOutput(os.Stderr)).Run(); runErr != nil {
+		return "", runErr
+	}
+
+	return editor.Line(), err
 }
 
+var errInterrupted = errors.New("interrupted")
+
 func (sh *Arosh) Println(text string) (tea.Model, tea.Cmd) {
-	line := fmt.Sprintf("%s%s", sh.editor.Prompt(), sh.editor.Line())
+	line := sh.editor.PromptedLine()
 	style := lipgloss.NewStyle().Width(sh.editor.Width())
 
 	return sh.editor, tea.Println(style.Render(fmt.Sprintf("%s\n%s", line, text)))
 }
 
-func (sh *Arosh) Errorln(text string) (tea.Model, tea.Cmd) {
+// Errorln prints err below the line. Syntax errors are shown with the
+// offending tokens underlined.
+func (sh *Arosh) Errorln(err error) (tea.Model, tea.Cmd) {
 	width := lipgloss.NewStyle().Width(sh.editor.Width())
 	red := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
-	line := fmt.Sprintf("%s%s", sh.editor.Prompt(), sh.editor.Line())
+	line := sh.editor.PromptedLine()
+	text := err.Error()
+
+	var parseErrors interpreter.ParseErrors
+	if errors.As(err, &parseErrors) {
+		text = parseErrors.Diagnostic()
+	}
+
 	message := red.Render("error: ") + text
 
 	return sh.editor, tea.Println(width.Render(fmt.Sprintf("%s\n%s", line, message)))
@@ -82,6 +220,25 @@ func (sh *Arosh) Quit() (tea.Model, tea.Cmd) {
 	return sh.editor, tea.Quit
 }
 
+// Interrupt drops the line being edited, leaving it on the screen with a
+// ^C after it, like a terminal does.
+func (sh *Arosh) Interrupt() (tea.Model, tea.Cmd) {
+	line := sh.editor.PromptedLine()
+	style := lipgloss.NewStyle().Width(sh.editor.Width())
+	sh.editor.SetLine("")
+
+	return sh.editor, tea.Println(style.Render(line + "^C"))
+}
+
+// EndOfInput quits on an empty line.
+func (sh *Arosh) EndOfInput() (tea.Model, tea.Cmd) {
+	if sh.editor.Line() != "" {
+		return sh.editor, nil
+	}
+
+	return sh.Quit()
+}
+
 func (sh *Arosh) Clear() (tea.Model, tea.Cmd) {
 	return sh.editor, tea.ClearScreen
 }
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/marcos-brito/arosh/internal/builtins"
+	"github.com/marcos-brito/arosh/internal/interpreter"
+)
+
+const usage = "usage: arosh [--login] [--noprofile] [--norc] [-cls] [-o option]\n" +
+	"             [file | command_string [name]] [arg ...]"
+
+// invocation is what arosh was asked to do by its arguments.
+type invocation struct {
+	// command is set by -c: the first operand is the commands to run and
+	// the second one is $0.
+	command bool
+	// stdin is set by -s: commands are read from the standard input and
+	// every operand is a positional parameter.
+	stdin bool
+	// login is set by -l or --login, and when arosh is started with a name
+	// that starts with "-": the profiles are run before anything else.
+	login bool
+	// noProfile and noRC skip the profiles and the rc file.
+	noProfile bool
+	noRC      bool
+	operands  []string
+}
+
+// parseInvocation reads the options arosh was started with. Besides -c,
+// -l, -s and the long ones, it takes the ones set takes, which are turned
+// on or off in options. The operands start after the first argument that
+// isn't an option, or after "--" or "-".
+func parseInvocation(args []string, options *interpreter.Options) (invocation, error) {
+	invocation := invocation{}
+
+	for len(args) > 0 {
+		arg := args[0]
+
+		if arg == "--" || arg == "-" {
+			args = args[1:]
+			break
+		}
+
+		if long, ok := longOptions[arg]; ok {
+			long(&invocation)
+			args = args[1:]
+
+			continue
+		}
+
+		if len(arg) < 2 || arg[0] != '-' && arg[0] != '+' {
+			break
+		}
+
+		args = args[1:]
+		on := arg[0] == '-'
+
+		for _, letter := range arg[1:] {
+			switch {
+			case letter == 'c' && on:
+				invocation.command = true
+			case letter == 's' && on:
+				invocation.stdin = true
+			case letter == 'l' && on:
+				invocation.login = true
+			case letter == 'o':
+				if len(args) == 0 {
+					return invocation, fmt.Errorf("%co: option requires an argument", arg[0])
+				}
+
+				if err := options.Set(args[0], on); err != nil {
+					return invocation, err
+				}
+
+				args = args[1:]
+			default:
+				if err := options.SetFlag(letter, on); err != nil {
+					return invocation, err
+				}
+			}
+		}
+	}
+
+	if invocation.command && len(args) == 0 {
+		return invocation, errors.New("-c: option requires an argument")
+	}
+
+	invocation.operands = args
+
+	return invocation, nil
+}
+
+var longOptions = map[string]func(*invocation){
+	"--login":     func(invocation *invocation) { invocation.login = true },
+	"--noprofile": func(invocation *invocation) { invocation.noProfile = true },
+	"--norc":      func(invocation *invocation) { invocation.noRC = true },
+}
+
+// interactive tells if the commands come from the user at a terminal,
+// instead of from a command string, a file or a standard input that's
+// been redirected.
+func (inv invocation) interactive(terminal bool) bool {
+	return !inv.command && (inv.stdin || len(inv.operands) == 0) && terminal
+}
+
+// run runs a command string, a file or the standard input, whichever the
+// invocation asks for, and returns the status arosh exits with.
+func (inv invocation) run(shell *interpreter.Interpreter) int {
+	operands := inv.operands
+
+	switch {
+	case inv.command:
+		if len(operands) > 1 {
+			shell.SetName(operands[1])
+			shell.SetPositional(operands[2:])
+		}
+
+		return runScript(shell, "-c", interpreter.StringLines(operands[0]))
+	case inv.stdin || len(operands) == 0:
+		shell.SetPositional(operands)
+
+		return runScript(shell, "", func() (string, error) {
+			line, err := builtins.ReadLine(shell.Stdin)
+
+			if err == nil {
+				line += "\n"
+			}
+
+			return line, err
+		})
+	}
+
+	source, err := os.ReadFile(operands[0])
+
+	if err != nil {
+		fmt.Fprintf(shell.Stderr, "arosh: %s\n", fileError(err))
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return 127
+		}
+
+		return 126
+	}
+
+	shell.SetName(operands[0])
+	shell.SetPositional(operands[1:])
+
+	return runScript(shell, operands[0], interpreter.StringLines(string(source)))
+}
+
+// fileError puts the name of the file first in errors about it, like
+// "script.sh: no such file or directory".
+func fileError(err error) error {
+	var pathError *fs.PathError
+
+	if errors.As(err, &pathError) {
+		return fmt.Errorf("%s: %w", pathError.Path, pathError.Err)
+	}
+
+	return err
+}
+
+// runScript runs the lines of a script and then the EXIT trap.
+func runScript(shell *interpreter.Interpreter, where string, next func() (string, error)) int {
+	status := shell.RunLines(where, next)
+	shell.RunExitTrap()
+
+	if shell.Exited() {
+		return shell.Status()
+	}
+
+	return status
+}
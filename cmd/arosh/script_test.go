@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/marcos-brito/arosh/internal/interpreter"
+)
+
+func TestParseInvocation(t *testing.T) {
+	tests := []struct {
+		args     []string
+		command  bool
+		stdin    bool
+		operands []string
+		flags    string
+		err      bool
+	}{
+		{[]string{}, false, false, []string{}, "", false},
+		{[]string{"a.sh", "-c", "a"}, false, false, []string{"a.sh", "-c", "a"}, "", false},
+		{[]string{"-c", "echo", "name"}, true, false, []string{"echo", "name"}, "", false},
+		{[]string{"-s", "a", "b"}, false, true, []string{"a", "b"}, "", false},
+		{[]string{"-Cf", "-c", "echo"}, true, false, []string{"echo"}, "Cf", false},
+		{[]string{"-f", "+f", "script.sh"}, false, false, []string{"script.sh"}, "", false},
+		{[]string{"-o", "noclobber", "script.sh"}, false, false, []string{"script.sh"}, "C", false},
+		{[]string{"-sc", "echo"}, true, true, []string{"echo"}, "", false},
+		{[]string{"--", "-c"}, false, false, []string{"-c"}, "", false},
+		{[]string{"-", "-c"}, false, false, []string{"-c"}, "", false},
+		{[]string{"-c"}, false, false, nil, "", true},
+		{[]string{"-o"}, false, false, nil, "", true},
+		{[]string{"-o", "nothing"}, false, false, nil, "", true},
+		{[]string{"-Z"}, false, false, nil, "", true},
+	}
+
+	for _, tt := range tests {
+		options := interpreter.Options{}
+		got, err := parseInvocation(tt.args, &options)
+
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: want an error, got none", tt.args)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: %s", tt.args, err)
+			continue
+		}
+
+		if got.command != tt.command || got.stdin != tt.stdin ||
+			!slices.Equal(got.operands, tt.operands) || options.Flags() != tt.flags {
+			t.Errorf("%q:\nwant: %v %v %q %q\ngot: %v %v %q %q", tt.args,
+				tt.command, tt.stdin, tt.operands, tt.flags,
+				got.command, got.stdin, got.operands, options.Flags())
+		}
+	}
+}
+
+func TestRunInvocation(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.sh")
+	source := "#!/usr/bin/env arosh\necho $0 $#\n" +
+		"if true\nthen\n\techo \"$2\"\nfi\nexit 3\necho no\n"
+
+	if err := os.WriteFile(script, []byte(source), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		want   string
+		status int
+	}{
+		{[]string{script, "a", "b c"}, "", script + " 2\nb c\n", 3},
+		{[]string{"-c", "echo $0 $1; false", "name", "a"}, "", "name a\n", 1},
+		{[]string{"-c", "echo $0"}, "", "arosh\n", 0},
+		{[]string{"-c", "echo a\necho ("}, "", "a\n", 2},
+		{[]string{"-c", "echo one \\\n  two \\\n\tthree"}, "", "one two three\n", 0},
+		{[]string{}, "ls nothing \\\n 2>/dev/null \\\n|| echo $?\n", "2\n", 0},
+		{[]string{}, "echo a\\\nb\\\n", "ab\n", 0},
+		{[]string{"-c", "echo a; echo ${x:?}; echo b"}, "", "a\n", 1},
+		{[]string{"-c", "trap 'echo bye' EXIT\nexit 4"}, "", "bye\n", 4},
+		{[]string{}, "echo a\nread line\nsome text\necho $line\n", "a\nsome text\n", 0},
+		{[]string{"-s", "a", "b"}, "echo $2\nfor i\ndo echo $i\ndone", "b\na\nb\n", 0},
+		{[]string{}, "echo \"a\nb\"\nexit 5\necho no\n", "a\nb\n", 5},
+		{[]string{filepath.Join(dir, "nothing")}, "", "", 127},
+		{[]string{dir}, "", "", 126},
+	}
+
+	for _, tt := range tests {
+		shell := interpreter.New()
+		stdin := filepath.Join(dir, "stdin")
+		stdout := filepath.Join(dir, "stdout")
+
+		if err := os.WriteFile(stdin, []byte(tt.stdin), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		shell.Stdin, _ = os.Open(stdin)
+		shell.Stdout, _ = os.Create(stdout)
+		shell.Stderr, _ = os.Create(os.DevNull)
+
+		invocation, err := parseInvocation(tt.args, &shell.Options)
+
+		if err != nil {
+			t.Errorf("%q: %s", tt.args, err)
+			continue
+		}
+
+		status := invocation.run(shell)
+		shell.Stdin.Close()
+		shell.Stdout.Close()
+		shell.Stderr.Close()
+		got, _ := os.ReadFile(stdout)
+
+		if string(got) != tt.want || status != tt.status {
+			t.Errorf("%q:\nwant: %q %d\ngot: %q %d", tt.args, tt.want, tt.status, got, status)
+		}
+	}
+}
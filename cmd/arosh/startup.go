@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/marcos-brito/arosh/internal/interpreter"
+)
+
+// startupFiles are the files run before anything else. A login shell runs
+// /etc/profile and then ~/.arosh_profile or, if there isn't one,
+// ~/.profile. An interactive shell runs the file $ENV names, after
+// parameter expansion, or ~/.aroshrc.
+func (inv invocation) startupFiles(shell *interpreter.Interpreter, interactive bool) []string {
+	home, _ := shell.Env().Get("HOME")
+	files := []string{}
+
+	if inv.login && !inv.noProfile {
+		files = append(files, "/etc/profile")
+
+		if home != "" {
+			profile := filepath.Join(home, ".arosh_profile")
+
+			if _, err := os.Stat(profile); err != nil {
+				profile = filepath.Join(home, ".profile")
+			}
+
+			files = append(files, profile)
+		}
+	}
+
+	if !interactive || inv.noRC {
+		return files
+	}
+
+	if env, _ := shell.Env().Get("ENV"); env != "" {
+		return append(files, os.Expand(env, func(name string) string {
+			value, _ := shell.Env().Get(name)
+			return value
+		}))
+	}
+
+	if home != "" {
+		files = append(files, filepath.Join(home, ".aroshrc"))
+	}
+
+	return files
+}
+
+// startup runs the startup files, unless one of them exits the shell,
+// which is told by returning false.
+func (inv invocation) startup(shell *interpreter.Interpreter, interactive bool) bool {
+	for _, path := range inv.startupFiles(shell, interactive) {
+		sourceFile(shell, path)
+
+		if shell.Exited() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sourceFile runs the commands of a file in shell, reporting errors with
+// the line they're on. Files that don't exist are skipped.
+func sourceFile(shell *interpreter.Interpreter, path string) {
+	source, err := os.ReadFile(path)
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(shell.Stderr, "arosh: %s\n", fileError(err))
+		return
+	}
+
+	shell.RunLines(path, interpreter.StringLines(string(source)))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/marcos-brito/arosh/internal/interpreter"
+)
+
+func TestParseStartupOptions(t *testing.T) {
+	tests := []struct {
+		args []string
+		want invocation
+	}{
+		{[]string{"-l"}, invocation{login: true, operands: []string{}}},
+		{
+			[]string{"--login", "-c", "a"},
+			invocation{command: true, login: true, operands: []string{"a"}},
+		},
+		{
+			[]string{"--norc", "--noprofile"},
+			invocation{noRC: true, noProfile: true, operands: []string{}},
+		},
+		{
+			[]string{"-ls", "--norc"},
+			invocation{stdin: true, login: true, noRC: true, operands: []string{}},
+		},
+		{[]string{"a.sh", "--norc"}, invocation{operands: []string{"a.sh", "--norc"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseInvocation(tt.args, &interpreter.Options{})
+
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q:\nwant: %+v\ngot: %+v %v", tt.args, tt.want, got, err)
+		}
+	}
+}
+
+func TestStartupFiles(t *testing.T) {
+	home := t.TempDir()
+	other := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(other, ".arosh_profile"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		invocation  invocation
+		interactive bool
+		home        string
+		env         string
+		want        []string
+	}{
+		{invocation{}, false, home, "", []string{}},
+		{invocation{}, true, home, "", []string{home + "/.aroshrc"}},
+		{invocation{noRC: true}, true, home, "", []string{}},
+		{invocation{}, true, home, "$HOME/rc", []string{home + "/rc"}},
+		{invocation{login: true}, false, home, "", []string{"/etc/profile", home + "/.profile"}},
+		{
+			invocation{login: true},
+			false,
+			other,
+			"",
+			[]string{"/etc/profile", other + "/.arosh_profile"},
+		},
+		{invocation{login: true, noProfile: true}, false, home, "", []string{}},
+		{
+			invocation{login: true},
+			true,
+			home,
+			"",
+			[]string{"/etc/profile", home + "/.profile", home + "/.aroshrc"},
+		},
+		{invocation{login: true}, true, "", "", []string{"/etc/profile"}},
+	}
+
+	for _, tt := range tests {
+		shell := interpreter.New()
+		shell.Env().Set("HOME", tt.home)
+		shell.Env().Unset("ENV")
+
+		if tt.env != "" {
+			shell.Env().Set("ENV", tt.env)
+		}
+
+		got := tt.invocation.startupFiles(shell, tt.interactive)
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%+v %v:\nwant: %q\ngot: %q", tt.invocation, tt.interactive, tt.want, got)
+		}
+	}
+}
+
+func TestSourceFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		source string
+		want   string
+		stderr string
+		exited bool
+	}{
+		{"echo a\necho b\n", "a\nb\n", "", false},
+		{
+			"echo a\n\nnothing-here\n",
+			"a\n",
+			"arosh: %s:3: nothing-here: command not found\n",
+			false,
+		},
+		{
+			"if true\nthen\n  cd /nothing\nfi\n",
+			"",
+			"arosh: %s:1: cd: /nothing: no such file or directory\n",
+			false,
+		},
+		{
+			"echo a\necho )\necho b\n",
+			"a\n",
+			"arosh: %s:2:6: unexpected \")\", expected \";\" or \"&\" or newline\n",
+			false,
+		},
+		{"exit 3\necho a\n", "", "", true},
+		{"set -v\necho a\nset +v\necho b\n", "a\nb\n", "echo a\nset +v\n", false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(dir, "rc")
+		stdout := filepath.Join(dir, "stdout")
+		stderr := filepath.Join(dir, "stderr")
+
+		if err := os.WriteFile(path, []byte(tt.source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		shell := interpreter.New()
+		shell.Stdout, _ = os.Create(stdout)
+		shell.Stderr, _ = os.Create(stderr)
+
+		sourceFile(shell, path)
+		shell.Stdout.Close()
+		shell.Stderr.Close()
+		gotStdout, _ := os.ReadFile(stdout)
+		gotStderr, _ := os.ReadFile(stderr)
+		wantStderr := tt.stderr
+
+		if strings.Contains(wantStderr, "%s") {
+			wantStderr = fmt.Sprintf(tt.stderr, path)
+		}
+
+		if string(gotStdout) != tt.want || string(gotStderr) != wantStderr ||
+			shell.Exited() != tt.exited {
+			t.Errorf("%q:\nwant: %q %q %v\ngot: %q %q %v", tt.source, tt.want, wantStderr,
+				tt.exited, gotStdout, gotStderr, shell.Exited())
+		}
+	}
+}
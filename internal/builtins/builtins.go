@@ -0,0 +1,213 @@
+// Package builtins has the commands that are part of the shell itself. They
+// run in the shell process, so they can change it: its working directory,
+// its variables and its options.
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	"github.com/marcos-brito/arosh/internal/env"
+	"golang.org/x/term"
+)
+
+// Shell is what builtins can see and change of the shell running them.
+type Shell interface {
+	Env() *env.Env
+	Dir() string
+	SetDir(dir string)
+	Positional() []string
+	SetPositional(params []string)
+	// Status is the status of the last command.
+	Status() int
+	// Exit makes the shell exit with status once the builtin returns.
+	Exit(status int)
+	UnsetFunction(name string)
+	// InFunction tells if a function is being run, whose scope local
+	// declares variables in.
+	InFunction() bool
+	// ListOptions tells which options are on, by their long names.
+	ListOptions() map[string]bool
+	SetOption(name string, on bool) error
+	SetFlag(flag rune, on bool) error
+	// LineReader is nil when there's no line editor to read lines with.
+	LineReader() LineReader
+	Jobs() Jobs
+	// SetTrap sets the action run on condition, a signal name without SIG
+	// or one of EXIT, ERR, DEBUG and RETURN.
+	SetTrap(condition string, action string)
+	ResetTrap(condition string)
+	Traps() map[string]string
+	// Raise sends signal to the shell itself, which acts on it before the
+	// next command if it catches it.
+	Raise(signal syscall.Signal) error
+}
+
+// LineReader reads a line from the terminal, letting the user edit it.
+// The prompt is shown before it.
+type LineReader func(prompt string) (string, error)
+
+// Streams are the files a builtin reads from and writes to.
+type Streams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Location is where the builtin is in a script, which error messages
+	// start with.
+	Location string
+}
+
+// Builtin runs a builtin command. args[0] is its name, like for programs,
+// and the value returned is its exit status.
+type Builtin func(shell Shell, streams Streams, args []string) int
+
+// Registry is where the interpreter looks for builtins before searching
+// PATH.
+type Registry interface {
+	Lookup(name string) (Builtin, bool)
+}
+
+// Table is a Registry holding builtins by name.
+type Table map[string]Builtin
+
+func (t Table) Lookup(name string) (Builtin, bool) {
+	builtin, ok := t[name]
+
+	return builtin, ok
+}
+
+// New returns a table with every builtin in this package.
+func New() Table {
+	return Table{
+		"cd":       cd,
+		"pwd":      pwd,
+		"exit":     exit,
+		"export":   export,
+		"readonly": readonly,
+		"local":    local,
+		"unset":    unset,
+		"set":      set,
+		"shift":    shift,
+		"test":     test,
+		"[":        test,
+		"echo":     echo,
+		":":        colon,
+		"printf":   printf,
+		"read":     read,
+		"jobs":     jobs,
+		"fg":       fg,
+		"bg":       bg,
+		"wait":     wait,
+		"kill":     kill,
+		"trap":     trap,
+	}
+}
+
+// colon does nothing and succeeds. Its operands are still expanded, and
+// the redirections written with it still done.
+func colon(shell Shell, streams Streams, args []string) int {
+	return 0
+}
+
+func errorf(streams Streams, format string, a ...any) {
+	fmt.Fprintf(streams.Stderr, "arosh: "+streams.Location+format+"\n", a...)
+}
+
+// write writes text to the standard output, the status is 1 if it can't.
+func write(streams Streams, name string, text string) int {
+	if _, err := io.WriteString(streams.Stdout, text); err != nil {
+		return writeError(streams, name, err)
+	}
+
+	return 0
+}
+
+// writeln writes a line to the standard output. Failing to do it is an
+// error like any other, the status is 1.
+func writeln(streams Streams, name string, a ...any) int {
+	if _, err := fmt.Fprintln(streams.Stdout, a...); err != nil {
+		return writeError(streams, name, err)
+	}
+
+	return 0
+}
+
+// writeError reports an error writing to the standard output, unless it's
+// because the output is a pipe nobody reads, which only ends the builtin
+// like SIGPIPE ends programs.
+func writeError(streams Streams, name string, err error) int {
+	if errors.Is(err, syscall.EPIPE) {
+		return 128 + int(syscall.SIGPIPE)
+	}
+
+	errorf(streams, "%s: write error: %s", name, err)
+
+	return 1
+}
+
+// options splits the options at the start of args from the operands. It
+// returns the letters of the options, which must be in allowed. A "--"
+// ends the options, a "-" alone is an operand.
+func options(args []string, allowed string) (string, []string, error) {
+	letters := ""
+
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			return letters, args[1:], nil
+		}
+
+		for _, letter := range args[0][1:] {
+			if !strings.ContainsRune(allowed, letter) {
+				return "", nil, fmt.Errorf("-%c: invalid option", letter)
+			}
+
+			letters += string(letter)
+		}
+
+		args = args[1:]
+	}
+
+	return letters, args, nil
+}
+
+// isTerminal tells if stream is a file open on a terminal.
+func isTerminal(stream any) bool {
+	file, ok := stream.(interface{ Fd() uintptr })
+
+	return ok && file != nil && term.IsTerminal(int(file.Fd()))
+}
+
+// Quote quotes str so the shell reads it back as it is.
+func Quote(str string) string {
+	safe := func(char rune) bool {
+		return isNameChar(char) || strings.ContainsRune("-./:,+=@%^", char)
+	}
+
+	if str != "" && strings.IndexFunc(str, func(char rune) bool { return !safe(char) }) == -1 {
+		return str
+	}
+
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+func isName(str string) bool {
+	if str == "" || '0' <= str[0] && str[0] <= '9' {
+		return false
+	}
+
+	for _, char := range str {
+		if !isNameChar(char) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isNameChar(char rune) bool {
+	return char == '_' || 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' ||
+		'0' <= char && char <= '9'
+}
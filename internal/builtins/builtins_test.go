@@ -0,0 +1,1130 @@
+package builtins
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/marcos-brito/arosh/internal/env"
+	"golang.org/x/sys/unix"
+)
+
+type fakeShell struct {
+	env        *env.Env
+	dir        string
+	positional []string
+	status     int
+	exited     bool
+	functions  []string
+	options    map[string]bool
+	lineReader LineReader
+	jobs       *fakeJobs
+	traps      map[string]string
+	calls      int
+	raised     []syscall.Signal
+}
+
+func newFakeShell(dir string) *fakeShell {
+	return &fakeShell{
+		env:        env.New(),
+		dir:        dir,
+		positional: []string{},
+		functions:  []string{"f", "g"},
+		options:    map[string]bool{"noclobber": false, "noglob": false},
+		jobs:       newFakeJobs(),
+		traps:      map[string]string{},
+	}
+}
+
+func (s *fakeShell) Env() *env.Env                 { return s.env }
+func (s *fakeShell) Dir() string                   { return s.dir }
+func (s *fakeShell) SetDir(dir string)             { s.dir = dir }
+func (s *fakeShell) Positional() []string          { return s.positional }
+func (s *fakeShell) SetPositional(params []string) { s.positional = params }
+func (s *fakeShell) Status() int                   { return s.status }
+func (s *fakeShell) ListOptions() map[string]bool  { return s.options }
+func (s *fakeShell) LineReader() LineReader        { return s.lineReader }
+func (s *fakeShell) Jobs() Jobs                    { return s.jobs }
+func (s *fakeShell) InFunction() bool              { return s.calls > 0 }
+func (s *fakeShell) Traps() map[string]string      { return maps.Clone(s.traps) }
+func (s *fakeShell) ResetTrap(condition string)    { delete(s.traps, condition) }
+
+func (s *fakeShell) SetTrap(condition string, action string) {
+	s.traps[condition] = action
+}
+
+func (s *fakeShell) Raise(signal syscall.Signal) error {
+	s.raised = append(s.raised, signal)
+	return nil
+}
+
+func (s *fakeShell) Exit(status int) {
+	s.status, s.exited = status, true
+}
+
+func (s *fakeShell) UnsetFunction(name string) {
+	s.functions = slices.DeleteFunc(s.functions, func(f string) bool { return f == name })
+}
+
+func (s *fakeShell) SetOption(name string, on bool) error {
+	if _, ok := s.options[name]; !ok {
+		return fmt.Errorf("%s: invalid option name", name)
+	}
+
+	s.options[name] = on
+
+	return nil
+}
+
+func (s *fakeShell) SetFlag(flag rune, on bool) error {
+	switch flag {
+	case 'C':
+		s.options["noclobber"] = on
+	case 'f':
+		s.options["noglob"] = on
+	default:
+		return fmt.Errorf("-%c: invalid option", flag)
+	}
+
+	return nil
+}
+
+type fakeJob struct {
+	id      int
+	command string
+	pgid    int
+	state   string
+}
+
+func (j *fakeJob) ID() int         { return j.id }
+func (j *fakeJob) Command() string { return j.command }
+func (j *fakeJob) Pgid() int       { return j.pgid }
+func (j *fakeJob) Pids() []int     { return []int{j.pgid} }
+func (j *fakeJob) State() string   { return j.state }
+
+// fakeJobs has the jobs %1 to %3, the last one being the current job. Jobs
+// that are waited for or put in the foreground end with their id as their
+// status.
+type fakeJobs struct {
+	jobs []*fakeJob
+}
+
+func newFakeJobs() *fakeJobs {
+	return &fakeJobs{jobs: []*fakeJob{
+		{1, "sleep 10", 100, "Running"},
+		{2, "vim", 200, "Stopped"},
+		{3, "false", 300, "Done(1)"},
+	}}
+}
+
+func (t *fakeJobs) List() []Job {
+	jobs := []Job{}
+
+	for _, job := range t.jobs {
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+func (t *fakeJobs) Find(spec string) (Job, error) {
+	for _, job := range t.jobs {
+		if spec == "%"+strconv.Itoa(job.id) || spec == "%+" && job == t.jobs[len(t.jobs)-1] {
+			return job, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: no such job", spec)
+}
+
+func (t *fakeJobs) Marker(job Job) string {
+	if len(t.jobs) > 0 && job == t.jobs[len(t.jobs)-1] {
+		return "+"
+	}
+
+	return " "
+}
+
+func (t *fakeJobs) Foreground(job Job) int {
+	return t.Wait(job)
+}
+
+func (t *fakeJobs) Background(job Job) {
+	job.(*fakeJob).state = "Running"
+}
+
+func (t *fakeJobs) Wait(job Job) int {
+	job.(*fakeJob).state = "Done"
+	return job.ID()
+}
+
+func (t *fakeJobs) Signal(job Job, signal syscall.Signal) error {
+	return nil
+}
+
+func (t *fakeJobs) Remove(job Job) {
+	t.jobs = slices.DeleteFunc(t.jobs, func(other *fakeJob) bool { return other == job })
+}
+
+// run runs the builtin called args[0] on shell, returning what it wrote and
+// its status.
+func run(shell Shell, args ...string) (string, string, int) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	builtin, _ := New().Lookup(args[0])
+	status := builtin(shell, Streams{Stdout: stdout, Stderr: stderr}, args)
+
+	return stdout.String(), stderr.String(), status
+}
+
+func TestCd(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "a", "b"), 0o755)
+	os.MkdirAll(filepath.Join(root, "search", "found"), 0o755)
+	os.WriteFile(filepath.Join(root, "file"), nil, 0o644)
+	os.Symlink(filepath.Join(root, "a", "b"), filepath.Join(root, "link"))
+
+	tests := []struct {
+		args           []string
+		cdpath         string
+		expectedDir    string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{[]string{"cd", "a/b"}, "", "a/b", "", 0},
+		{[]string{"cd", "a/b/.."}, "", "a", "", 0},
+		{[]string{"cd"}, "", "a", "", 0},
+		{[]string{"cd", "-"}, "", "old", "old\n", 0},
+		{[]string{"cd", "link/.."}, "", "", "", 0},
+		{[]string{"cd", "-P", "link"}, "", "a/b", "", 0},
+		{[]string{"cd", "-L", "link"}, "", "link", "", 0},
+		{[]string{"cd", "-P", "link/.."}, "", "a", "", 0},
+		{[]string{"cd", "ROOT/link/.."}, "", "", "", 0},
+		{[]string{"cd", "ROOT/a/../link/../a/./b"}, "", "a/b", "", 0},
+		{[]string{"cd", "-P", "ROOT/link/.."}, "", "a", "", 0},
+		{[]string{"cd", "found"}, "search", "search/found", "search/found\n", 0},
+		{[]string{"cd", "found"}, ":search", "search/found", "search/found\n", 0},
+		{[]string{"cd", "a"}, "search", "a", "", 0},
+		{[]string{"cd", "./found"}, "search", "", "", 1},
+		{[]string{"cd", "missing"}, "", "", "", 1},
+		{[]string{"cd", "file"}, "", "", "", 1},
+		{[]string{"cd", "a", "b"}, "", "", "", 1},
+		{[]string{"cd", "-x"}, "", "", "", 2},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell(root)
+		shell.env.Set("HOME", filepath.Join(root, "a"))
+		shell.env.Set("OLDPWD", filepath.Join(root, "old"))
+		shell.env.Set("CDPATH", tt.cdpath)
+		os.MkdirAll(filepath.Join(root, "old"), 0o755)
+
+		args := slices.Clone(tt.args)
+
+		for n := range args {
+			args[n] = strings.Replace(args[n], "ROOT", root, 1)
+		}
+
+		output, _, status := run(shell, args...)
+		expectedDir := filepath.Join(root, tt.expectedDir)
+		expectedOutput := strings.ReplaceAll(tt.expectedOutput, "old", expectedDir)
+
+		if tt.expectedOutput != "" && !strings.HasPrefix(tt.expectedOutput, "old") {
+			expectedOutput = filepath.Join(root, tt.expectedOutput)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if status != 0 {
+			if shell.dir != root {
+				t.Errorf("%v: Expected to stay in %s, but went to %s", tt.args, root, shell.dir)
+			}
+
+			continue
+		}
+
+		if shell.dir != expectedDir {
+			t.Errorf("%v: Expected to be in %s, but got %s", tt.args, expectedDir, shell.dir)
+		}
+
+		if output != expectedOutput {
+			t.Errorf("%v: Expected output %q, but got %q", tt.args, expectedOutput, output)
+		}
+
+		pwd, _ := shell.env.Get("PWD")
+		oldpwd, _ := shell.env.Get("OLDPWD")
+
+		if pwd != expectedDir || oldpwd != root {
+			t.Errorf(
+				"%v: Expected PWD and OLDPWD to change, but got %s and %s",
+				tt.args,
+				pwd,
+				oldpwd,
+			)
+		}
+	}
+}
+
+func TestCdWithoutHome(t *testing.T) {
+	shell := newFakeShell(t.TempDir())
+
+	for _, args := range [][]string{{"cd"}, {"cd", "-"}} {
+		if _, stderr, status := run(shell, args...); status != 1 || stderr == "" {
+			t.Errorf("%v: Expected an error, but got status %d", args, status)
+		}
+	}
+}
+
+func TestPwd(t *testing.T) {
+	root := t.TempDir()
+	os.Symlink(root, filepath.Join(root, "link"))
+	physical, _ := filepath.EvalSymlinks(root)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"pwd"}, filepath.Join(root, "link") + "\n"},
+		{[]string{"pwd", "-L"}, filepath.Join(root, "link") + "\n"},
+		{[]string{"pwd", "-P"}, physical + "\n"},
+	}
+
+	for _, tt := range tests {
+		output, _, status := run(newFakeShell(filepath.Join(root, "link")), tt.args...)
+
+		if status != 0 || output != tt.expected {
+			t.Errorf(
+				"%v: Expected %q, but got %q and status %d",
+				tt.args,
+				tt.expected,
+				output,
+				status,
+			)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expectedStatus int
+		expectedExit   bool
+	}{
+		{[]string{"exit"}, 3, true},
+		{[]string{"exit", "7"}, 7, true},
+		{[]string{"exit", "257"}, 1, true},
+		{[]string{"exit", "-1"}, 255, true},
+		{[]string{"exit", "x"}, 2, true},
+		{[]string{"exit", "1", "2"}, 1, false},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.status = 3
+		_, _, status := run(shell, tt.args...)
+
+		if status != tt.expectedStatus || shell.exited != tt.expectedExit {
+			t.Errorf(
+				"%v: Expected status %d and exited %t, but got %d and %t",
+				tt.args,
+				tt.expectedStatus,
+				tt.expectedExit,
+				status,
+				shell.exited,
+			)
+		}
+	}
+}
+
+func TestExportAndReadonly(t *testing.T) {
+	tests := []struct {
+		args           [][]string
+		expectedOutput string
+		expectedStatus int
+		expectedVars   map[string]env.Variable
+	}{
+		{
+			[][]string{{"export", "A=1", "B"}},
+			"",
+			0,
+			map[string]env.Variable{
+				"A": {Value: "1", IsSet: true, Exported: true},
+				"B": {Exported: true},
+			},
+		},
+		{
+			[][]string{{"export", "X"}, {"export", "A='q'", "B"}, {"export", "-p"}},
+			"export A=''\\''q'\\'''\nexport B\nexport X=x\n",
+			0,
+			nil,
+		},
+		{
+			[][]string{{"readonly", "A=1", "X"}, {"readonly"}},
+			"readonly A=1\nreadonly X=x\n",
+			0,
+			map[string]env.Variable{
+				"A": {Value: "1", IsSet: true, Readonly: true},
+				"X": {Value: "x", IsSet: true, Readonly: true},
+			},
+		},
+		{
+			[][]string{{"readonly", "X"}, {"export", "X=y", "A=2"}},
+			"",
+			1,
+			map[string]env.Variable{
+				"A": {Value: "2", IsSet: true, Exported: true},
+				"X": {Value: "x", IsSet: true, Readonly: true},
+			},
+		},
+		{
+			[][]string{{"export", "1A=1", "B-C"}},
+			"",
+			1,
+			map[string]env.Variable{},
+		},
+		{
+			[][]string{{"export", "-n", "A"}},
+			"",
+			2,
+			map[string]env.Variable{},
+		},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.env.Set("X", "x")
+		output, status := "", 0
+
+		for _, args := range tt.args {
+			stdout, _, code := run(shell, args...)
+			output, status = output+stdout, code
+		}
+
+		if output != tt.expectedOutput {
+			t.Errorf("%v: Expected output %q, but got %q", tt.args, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		for name, expected := range tt.expectedVars {
+			if variable := shell.env.Lookup(name); variable == nil || *variable != expected {
+				t.Errorf(
+					"%v: Expected %s to be %+v, but got %+v",
+					tt.args,
+					name,
+					expected,
+					variable,
+				)
+			}
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	tests := []struct {
+		args           []string
+		calls          int
+		expectedStatus int
+		expectedVars   map[string]string
+	}{
+		{[]string{"local", "A=1", "X"}, 1, 0, map[string]string{"A": "1", "X": "<unset>"}},
+		{[]string{"local", "A=1"}, 0, 1, map[string]string{"A": "<unset>", "X": "x"}},
+		{[]string{"local", "R=2", "1A", "B=3"}, 1, 1, map[string]string{"R": "r", "B": "3"}},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.env.Set("X", "x")
+		shell.env.Set("R", "r")
+		shell.env.SetReadonly("R")
+		shell.env.Push()
+		shell.calls = tt.calls
+		_, _, status := run(shell, tt.args...)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		for name, expected := range tt.expectedVars {
+			value, ok := shell.env.Get(name)
+
+			if !ok {
+				value = "<unset>"
+			}
+
+			if value != expected {
+				t.Errorf("%v: Expected %s to be %q, but got %q", tt.args, name, expected, value)
+			}
+		}
+
+		shell.env.Pop()
+
+		if value, _ := shell.env.Get("X"); value != "x" {
+			t.Errorf("%v: Expected X to be \"x\" after the call, but got %q", tt.args, value)
+		}
+	}
+}
+
+func TestUnset(t *testing.T) {
+	tests := []struct {
+		args              []string
+		expectedStatus    int
+		expectedVars      []string
+		expectedFunctions []string
+	}{
+		{[]string{"unset", "A", "B"}, 0, []string{"R"}, []string{"f", "g"}},
+		{[]string{"unset", "-v", "A", "f"}, 0, []string{"B", "R"}, []string{"f", "g"}},
+		{[]string{"unset", "-f", "A", "f"}, 0, []string{"A", "B", "R"}, []string{"g"}},
+		{[]string{"unset", "A", "f"}, 0, []string{"B", "R"}, []string{"g"}},
+		{[]string{"unset", "R", "A"}, 1, []string{"B", "R"}, []string{"f", "g"}},
+		{[]string{"unset", "-fv", "A"}, 1, []string{"A", "B", "R"}, []string{"f", "g"}},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.env.Set("A", "1")
+		shell.env.Set("B", "2")
+		shell.env.Set("R", "3")
+		shell.env.SetReadonly("R")
+		_, _, status := run(shell, tt.args...)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if names := shell.env.Names(); !reflect.DeepEqual(names, tt.expectedVars) {
+			t.Errorf("%v: Expected variables %v, but got %v", tt.args, tt.expectedVars, names)
+		}
+
+		if !reflect.DeepEqual(shell.functions, tt.expectedFunctions) {
+			t.Errorf(
+				"%v: Expected functions %v, but got %v",
+				tt.args,
+				tt.expectedFunctions,
+				shell.functions,
+			)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		args               []string
+		expectedOutput     string
+		expectedStatus     int
+		expectedPositional []string
+		expectedOptions    map[string]bool
+	}{
+		{
+			[]string{"set"},
+			"A=1\nB='a b'\n",
+			0,
+			[]string{"old"},
+			map[string]bool{"noclobber": false, "noglob": false},
+		},
+		{
+			[]string{"set", "-Cf"},
+			"",
+			0,
+			[]string{"old"},
+			map[string]bool{"noclobber": true, "noglob": true},
+		},
+		{
+			[]string{"set", "-C", "+o", "noclobber", "-o", "noglob", "a", "-b"},
+			"",
+			0,
+			[]string{"a", "-b"},
+			map[string]bool{"noclobber": false, "noglob": true},
+		},
+		{
+			[]string{"set", "--"},
+			"",
+			0,
+			[]string{},
+			map[string]bool{"noclobber": false, "noglob": false},
+		},
+		{
+			[]string{"set", "-", "-C"},
+			"",
+			0,
+			[]string{"-C"},
+			map[string]bool{"noclobber": false, "noglob": false},
+		},
+		{
+			[]string{"set", "-o"},
+			"noclobber       off\nnoglob          off\n",
+			0,
+			[]string{"old"},
+			map[string]bool{"noclobber": false, "noglob": false},
+		},
+		{
+			[]string{"set", "-f", "+o"},
+			"set +o noclobber\nset -o noglob\n",
+			0,
+			[]string{"old"},
+			map[string]bool{"noclobber": false, "noglob": true},
+		},
+		{
+			[]string{"set", "-z"},
+			"",
+			2,
+			[]string{"old"},
+			map[string]bool{"noclobber": false, "noglob": false},
+		},
+		{
+			[]string{"set", "-o", "nope"},
+			"",
+			2,
+			[]string{"old"},
+			map[string]bool{"noclobber": false, "noglob": false},
+		},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.positional = []string{"old"}
+		shell.env.Set("A", "1")
+		shell.env.Set("B", "a b")
+		shell.env.Export("UNSET")
+
+		output, _, status := run(shell, tt.args...)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%v: Expected output %q, but got %q", tt.args, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if !reflect.DeepEqual(shell.positional, tt.expectedPositional) {
+			t.Errorf(
+				"%v: Expected positional parameters %v, but got %v",
+				tt.args,
+				tt.expectedPositional,
+				shell.positional,
+			)
+		}
+
+		if !reflect.DeepEqual(shell.options, tt.expectedOptions) {
+			t.Errorf(
+				"%v: Expected options %v, but got %v",
+				tt.args,
+				tt.expectedOptions,
+				shell.options,
+			)
+		}
+	}
+}
+
+func TestShift(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expectedStatus int
+		expected       []string
+	}{
+		{[]string{"shift"}, 0, []string{"b", "c"}},
+		{[]string{"shift", "2"}, 0, []string{"c"}},
+		{[]string{"shift", "3"}, 0, []string{}},
+		{[]string{"shift", "4"}, 1, []string{"a", "b", "c"}},
+		{[]string{"shift", "-1"}, 1, []string{"a", "b", "c"}},
+		{[]string{"shift", "x"}, 1, []string{"a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.positional = []string{"a", "b", "c"}
+		_, _, status := run(shell, tt.args...)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%v: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if !reflect.DeepEqual(shell.positional, tt.expected) {
+			t.Errorf("%v: Expected %v, but got %v", tt.args, tt.expected, shell.positional)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriteErrors(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	streams := Streams{Stdout: failingWriter{}, Stderr: stderr}
+
+	if status := pwd(newFakeShell("/"), streams, []string{"pwd"}); status != 1 {
+		t.Errorf("Expected status 1, but got %d", status)
+	}
+
+	if !strings.Contains(stderr.String(), "write error") {
+		t.Errorf("Expected a write error, but got %q", stderr.String())
+	}
+}
+
+func TestTest(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "empty"), nil, 0o644)
+	os.WriteFile(filepath.Join(root, "full"), []byte("x"), 0o755)
+	os.Mkdir(filepath.Join(root, "dir"), 0o755)
+	os.Symlink("full", filepath.Join(root, "link"))
+	unix.Mkfifo(filepath.Join(root, "fifo"), 0o644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(root, "empty"), old, old)
+
+	tests := []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"test"}, 1},
+		{[]string{"test", ""}, 1},
+		{[]string{"test", "x"}, 0},
+		{[]string{"test", "-n"}, 0},
+		{[]string{"test", "!"}, 0},
+		{[]string{"test", "!", ""}, 0},
+		{[]string{"test", "-n", ""}, 1},
+		{[]string{"test", "-z", ""}, 0},
+		{[]string{"test", "a", "=", "a"}, 0},
+		{[]string{"test", "a", "!=", "a"}, 1},
+		{[]string{"test", "=", "=", "="}, 0},
+		{[]string{"test", "!", "=", "x"}, 1},
+		{[]string{"test", "(", "", ")"}, 1},
+		{[]string{"test", "!", "-z", "x"}, 0},
+		{[]string{"test", "!", "a", "=", "b"}, 0},
+		{[]string{"test", "(", "-n", "x", ")"}, 0},
+		{[]string{"test", "a", "-a", ""}, 1},
+		{[]string{"test", "a", "-o", ""}, 0},
+		{[]string{"test", "", "-o", "", "-o", "a"}, 0},
+		{[]string{"test", "a", "-o", "b", "-a", ""}, 0},
+		{[]string{"test", "(", "a", "-o", "b", ")", "-a", ""}, 1},
+		{[]string{"test", "!", "(", "a", "=", "b", ")", "-a", "x"}, 0},
+		{[]string{"test", "!", "-a", "x", "-a", "y"}, 0},
+		{[]string{"test", "10", "-gt", "9"}, 0},
+		{[]string{"test", " 10 ", "-eq", "10"}, 0},
+		{[]string{"test", "-1", "-lt", "0"}, 0},
+		{[]string{"test", "1", "-ge", "2"}, 1},
+		{[]string{"test", "2", "-le", "2"}, 0},
+		{[]string{"test", "2", "-ne", "2"}, 1},
+		{[]string{"test", "a", "-eq", "1"}, 2},
+		{[]string{"test", "-e", "empty"}, 0},
+		{[]string{"test", "-e", "missing"}, 1},
+		{[]string{"test", "-f", "empty"}, 0},
+		{[]string{"test", "-f", "dir"}, 1},
+		{[]string{"test", "-d", "dir"}, 0},
+		{[]string{"test", "-s", "empty"}, 1},
+		{[]string{"test", "-s", "full"}, 0},
+		{[]string{"test", "-x", "full"}, 0},
+		{[]string{"test", "-r", "full"}, 0},
+		{[]string{"test", "-w", root + "/full"}, 0},
+		{[]string{"test", "-L", "link"}, 0},
+		{[]string{"test", "-h", "full"}, 1},
+		{[]string{"test", "-f", "link"}, 0},
+		{[]string{"test", "-p", "fifo"}, 0},
+		{[]string{"test", "-p", "full"}, 1},
+		{[]string{"test", "-S", "full"}, 1},
+		{[]string{"test", "-c", "/dev/null"}, 0},
+		{[]string{"test", "-b", "/dev/null"}, 1},
+		{[]string{"test", "-t", "1"}, 1},
+		{[]string{"test", "full", "-nt", "empty"}, 0},
+		{[]string{"test", "full", "-ot", "empty"}, 1},
+		{[]string{"test", "link", "-ef", "full"}, 0},
+		{[]string{"test", "-x", "a", "b"}, 2},
+		{[]string{"test", "a", "b"}, 2},
+		{[]string{"test", "a", "b", "c"}, 2},
+		{[]string{"test", "(", "a", "-a", "b"}, 2},
+		{[]string{"[", "-d", "dir", "]"}, 0},
+		{[]string{"[", "]"}, 1},
+		{[]string{"[", "a"}, 2},
+	}
+
+	for _, tt := range tests {
+		if _, _, status := run(newFakeShell(root), tt.args...); status != tt.expected {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expected, status)
+		}
+	}
+}
+
+func TestEcho(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"echo"}, "\n"},
+		{[]string{"echo", "a", "", "b"}, "a  b\n"},
+		{[]string{"echo", "-n", "a"}, "a"},
+		{[]string{"echo", "-n", "-n", "a"}, "a"},
+		{[]string{"echo", "a", "-n"}, "a -n\n"},
+		{[]string{"echo", "-x", "a"}, "-x a\n"},
+		{[]string{"echo", "-", "a"}, "- a\n"},
+		{[]string{"echo", `a\tb`}, "a\\tb\n"},
+		{[]string{"echo", "-e", `a\tb\\\0101\q`}, "a\tb\\A\\q\n"},
+		{[]string{"echo", "-e", `a\cb`, "c"}, "a"},
+		{[]string{"echo", "-eE", `a\tb`}, "a\\tb\n"},
+		{[]string{"echo", "-ne", `a\n`}, "a\n"},
+	}
+
+	for _, tt := range tests {
+		if output, _, _ := run(newFakeShell("/"), tt.args...); output != tt.expected {
+			t.Errorf("%q: Expected %q, but got %q", tt.args, tt.expected, output)
+		}
+	}
+}
+
+func TestPrintf(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{[]string{"printf", "hello\n"}, "hello\n", 0},
+		{[]string{"printf", "%s-%s\n", "a", "b", "c"}, "a-b\nc-\n", 0},
+		{[]string{"printf", "%s\n"}, "\n", 0},
+		{[]string{"printf", "x%%y\n", "unused"}, "x%y\n", 0},
+		{[]string{"printf", "[%5s][%-5s][%.2s]", "ab", "ab", "abc"}, "[   ab][ab   ][ab]", 0},
+		{[]string{"printf", "[%*s][%.*s]", "3", "a", "1", "xyz"}, "[  a][x]", 0},
+		{[]string{"printf", "%d %i %d %d\n", "42", "-7", "010", "0x1f"}, "42 -7 8 31\n", 0},
+		{[]string{"printf", "[%05d][%+d][% d][%-4d]", "42", "1", "2", "3"},
+			"[00042][+1][ 2][3   ]", 0},
+		{[]string{"printf", "%o %x %X %#x %u\n", "8", "255", "255", "255", "-1"},
+			"10 ff FF 0xff 18446744073709551615\n", 0},
+		{[]string{"printf", "%d %d\n", "'A", "\"é"}, "65 233\n", 0},
+		{[]string{"printf", "%c%c\n", "hello", "é!"}, "hé\n", 0},
+		{[]string{"printf", "%f %.2f %e %g %g\n", "1.5", "3.14159", "1234.5", "0.0001", "1234567"},
+			"1.500000 3.14 1.234500e+03 0.0001 1.23457e+06\n", 0},
+		{[]string{"printf", "%.0f %.3g %G\n", "2.5", "3.14159", "1e-10"}, "2 3.14 1E-10\n", 0},
+		{[]string{"printf", "%a\n", "1"}, "0x1p+0\n", 0},
+		{[]string{"printf", `\101\tB\\\n`}, "A\tB\\\n", 0},
+		{[]string{"printf", `%b|%s`, `a\tb\0101`, `a\tb`}, "a\tbA|a\\tb", 0},
+		{[]string{"printf", `%b %s\n`, `stop\chere`, "x"}, "stop", 0},
+		{[]string{"printf", `a\cb`}, "a", 0},
+		{[]string{"printf", "%d\n", "12abc"}, "0\n", 1},
+		{[]string{"printf", "%d %d\n", "x", "3"}, "0 3\n", 1},
+		{[]string{"printf", "a%zb"}, "a", 1},
+		{[]string{"printf", "--", "%s\n", "a"}, "a\n", 0},
+		{[]string{"printf"}, "", 2},
+	}
+
+	for _, tt := range tests {
+		output, _, status := run(newFakeShell("/"), tt.args...)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%q: Expected output %q, but got %q", tt.args, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		args           []string
+		input          string
+		ifs            *string
+		expectedStatus int
+		expectedVars   map[string]string
+	}{
+		{[]string{"read"}, "  a line  \n", nil, 0, map[string]string{"REPLY": "a line"}},
+		{[]string{"read", "a", "b"}, "one two three\n", nil, 0,
+			map[string]string{"a": "one", "b": "two three"}},
+		{[]string{"read", "a", "b", "c"}, "one\n", nil, 0,
+			map[string]string{"a": "one", "b": "", "c": ""}},
+		{[]string{"read", "a", "b"}, `x\ y z\\w` + "\n", nil, 0,
+			map[string]string{"a": "x y", "b": `z\w`}},
+		{[]string{"read", "-r", "a", "b"}, `x\ y z` + "\n", nil, 0,
+			map[string]string{"a": `x\`, "b": "y z"}},
+		{[]string{"read", "a"}, "first \\\nsecond\nthird\n", nil, 0,
+			map[string]string{"a": "first second"}},
+		{[]string{"read", "-r", "a"}, "first \\\nsecond\n", nil, 0,
+			map[string]string{"a": `first \`}},
+		{[]string{"read", "a", "b"}, "a:b::c\n", ptr(":"), 0,
+			map[string]string{"a": "a", "b": "b::c"}},
+		{[]string{"read", "a", "b", "c"}, " a : : c\n", ptr(" :"), 0,
+			map[string]string{"a": "a", "b": "", "c": "c"}},
+		{[]string{"read", "a"}, "  kept  \n", ptr(""), 0, map[string]string{"a": "  kept  "}},
+		{[]string{"read", "a"}, "no newline", nil, 1, map[string]string{"a": "no newline"}},
+		{[]string{"read", "a"}, "", nil, 1, map[string]string{"a": ""}},
+		{[]string{"read", "-p", "prompt: ", "a"}, "x\n", nil, 0, map[string]string{"a": "x"}},
+		{[]string{"read", "1a"}, "x\n", nil, 2, map[string]string{}},
+		{[]string{"read", "-z"}, "x\n", nil, 2, map[string]string{}},
+		{[]string{"read", "-p"}, "x\n", nil, 2, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+
+		if tt.ifs != nil {
+			shell.env.Set("IFS", *tt.ifs)
+		}
+
+		builtin, _ := New().Lookup("read")
+		streams := Streams{
+			Stdin:  strings.NewReader(tt.input),
+			Stdout: &bytes.Buffer{},
+			Stderr: &bytes.Buffer{},
+		}
+
+		if status := builtin(shell, streams, tt.args); status != tt.expectedStatus {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		for name, expected := range tt.expectedVars {
+			if value, _ := shell.env.Get(name); value != expected {
+				t.Errorf("%q: Expected %s to be %q, but got %q", tt.args, name, expected, value)
+			}
+		}
+	}
+}
+
+func TestReadLeavesTheRest(t *testing.T) {
+	file, _ := os.CreateTemp(t.TempDir(), "input")
+	file.WriteString("first\nsecond\nthird")
+	file.Seek(0, io.SeekStart)
+	reader, writer, _ := os.Pipe()
+	writer.WriteString("first\nsecond\nthird")
+	writer.Close()
+
+	for _, input := range []*os.File{file, reader} {
+		lines := []string{}
+
+		for {
+			line, err := ReadLine(input)
+			lines = append(lines, line)
+
+			if err != nil {
+				break
+			}
+		}
+
+		if expected := []string{"first", "second", "third"}; !reflect.DeepEqual(lines, expected) {
+			t.Errorf("%s: Expected %q, but got %q", input.Name(), expected, lines)
+		}
+	}
+}
+
+func TestReadWithTheEditor(t *testing.T) {
+	shell := newFakeShell("/")
+	shell.lineReader = func(prompt string) (string, error) {
+		return "", errors.New("the editor shouldn't be used without a terminal")
+	}
+
+	builtin, _ := New().Lookup("read")
+	streams := Streams{Stdin: strings.NewReader("x\n"), Stderr: &bytes.Buffer{}}
+
+	if status := builtin(shell, streams, []string{"read", "a"}); status != 0 {
+		t.Errorf("Expected status 0, but got %d", status)
+	}
+}
+
+func ptr(str string) *string {
+	return &str
+}
+
+func TestJobs(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expectedOutput string
+		expectedStatus int
+		expectedJobs   int
+	}{
+		{[]string{"jobs"}, "[1]   Running                 sleep 10 &\n" +
+			"[2]   Stopped                 vim\n" +
+			"[3]+  Done(1)                 false\n", 0, 2},
+		{[]string{"jobs", "%2"}, "[2]   Stopped                 vim\n", 0, 3},
+		{[]string{"jobs", "-p", "%1", "%2"}, "100\n200\n", 0, 3},
+		{[]string{"jobs", "-l", "%1"}, "[1]  100 Running                 sleep 10 &\n", 0, 3},
+		{[]string{"jobs", "%4"}, "", 1, 3},
+		{[]string{"jobs", "-x"}, "", 2, 3},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		output, _, status := run(shell, tt.args...)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%q: Expected output %q, but got %q", tt.args, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if len(shell.jobs.jobs) != tt.expectedJobs {
+			t.Errorf("%q: Expected %d jobs, but got %d", tt.args, tt.expectedJobs,
+				len(shell.jobs.jobs))
+		}
+	}
+}
+
+func TestFgAndBg(t *testing.T) {
+	tests := []struct {
+		args           []string
+		monitor        bool
+		expectedOutput string
+		expectedStatus int
+		expectedState  string
+	}{
+		{[]string{"fg", "%2"}, true, "vim\n", 2, "Done"},
+		{[]string{"fg", "%3"}, true, "", 1, "Stopped"},
+		{[]string{"fg", "%4"}, true, "", 1, "Stopped"},
+		{[]string{"fg", "%2"}, false, "", 1, "Stopped"},
+		{[]string{"bg", "%2"}, true, "[2]  vim &\n", 0, "Running"},
+		{[]string{"bg", "%1"}, true, "", 0, "Stopped"},
+		{[]string{"bg", "%2", "%4"}, true, "[2]  vim &\n", 1, "Running"},
+		{[]string{"bg", "%2"}, false, "", 1, "Stopped"},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.options["monitor"] = tt.monitor
+		output, _, status := run(shell, tt.args...)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%q: Expected output %q, but got %q", tt.args, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if state := shell.jobs.jobs[1].state; state != tt.expectedState {
+			t.Errorf("%q: Expected %%2 to be %q, but got %q", tt.args, tt.expectedState, state)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expectedStatus int
+		expectedJobs   int
+	}{
+		{[]string{"wait"}, 0, 0},
+		{[]string{"wait", "%2"}, 2, 2},
+		{[]string{"wait", "100"}, 1, 2},
+		{[]string{"wait", "%1", "300"}, 3, 1},
+		{[]string{"wait", "%4"}, 127, 3},
+		{[]string{"wait", "12345"}, 127, 3},
+		{[]string{"wait", "x"}, 127, 3},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		_, _, status := run(shell, tt.args...)
+
+		if status != tt.expectedStatus {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if len(shell.jobs.jobs) != tt.expectedJobs {
+			t.Errorf("%q: Expected %d jobs, but got %d", tt.args, tt.expectedJobs,
+				len(shell.jobs.jobs))
+		}
+	}
+}
+
+func TestKill(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+
+	tests := []struct {
+		args           []string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{[]string{"kill", "-l", "15", "143", "KILL", "sigint"}, "TERM\nTERM\n9\n2\n", 0},
+		{[]string{"kill", "-l", "bogus"}, "", 1},
+		{[]string{"kill", "-0", pid}, "", 0},
+		{[]string{"kill", "-s", "0", pid}, "", 0},
+		{[]string{"kill", "-s", "bogus", pid}, "", 1},
+		{[]string{"kill", "-s"}, "", 2},
+		{[]string{"kill"}, "", 2},
+		{[]string{"kill", "-0", "x"}, "", 1},
+		{[]string{"kill", "-0", "%4"}, "", 1},
+		{[]string{"kill", "%1", "200"}, "", 0},
+	}
+
+	for _, tt := range tests {
+		output, _, status := run(newFakeShell("/"), tt.args...)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%q: Expected output %q, but got %q", tt.args, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestKillRaises(t *testing.T) {
+	shell := newFakeShell("/")
+	_, _, status := run(shell, "kill", "-USR1", strconv.Itoa(os.Getpid()))
+
+	if status != 0 || !slices.Equal(shell.raised, []syscall.Signal{syscall.SIGUSR1}) {
+		t.Errorf("Expected SIGUSR1 to be raised, but got %v with status %d", shell.raised, status)
+	}
+}
+
+func TestTrap(t *testing.T) {
+	tests := []struct {
+		args           []string
+		expectedOutput string
+		expectedStatus int
+		expectedTraps  map[string]string
+	}{
+		{[]string{"trap"}, "trap -- 'echo bye' EXIT\ntrap -- '' INT\ntrap -- : ERR\n", 0,
+			map[string]string{"EXIT": "echo bye", "INT": "", "ERR": ":"}},
+		{[]string{"trap", "-p", "int", "TERM"}, "trap -- '' INT\n", 0,
+			map[string]string{"EXIT": "echo bye", "INT": "", "ERR": ":"}},
+		{[]string{"trap", "echo hi", "SIGTERM", "hup", "15", "0", "debug"}, "", 0,
+			map[string]string{"EXIT": "echo hi", "INT": "", "ERR": ":", "TERM": "echo hi",
+				"HUP": "echo hi", "DEBUG": "echo hi"}},
+		{[]string{"trap", "-", "INT", "EXIT"}, "", 0, map[string]string{"ERR": ":"}},
+		{[]string{"trap", "2", "ERR"}, "", 0, map[string]string{"EXIT": "echo bye"}},
+		{[]string{"trap", "INT"}, "", 0, map[string]string{"EXIT": "echo bye", "ERR": ":"}},
+		{[]string{"trap", "--", "", "QUIT"}, "", 0,
+			map[string]string{"EXIT": "echo bye", "INT": "", "ERR": ":", "QUIT": ""}},
+		{[]string{"trap", "x", "BOGUS", "USR1"}, "", 1,
+			map[string]string{"EXIT": "echo bye", "INT": "", "ERR": ":", "USR1": "x"}},
+		{[]string{"trap", "-l", "9"}, "KILL\n", 0,
+			map[string]string{"EXIT": "echo bye", "INT": "", "ERR": ":"}},
+		{[]string{"trap", "-x"}, "", 2,
+			map[string]string{"EXIT": "echo bye", "INT": "", "ERR": ":"}},
+	}
+
+	for _, tt := range tests {
+		shell := newFakeShell("/")
+		shell.traps = map[string]string{"EXIT": "echo bye", "INT": "", "ERR": ":"}
+		output, _, status := run(shell, tt.args...)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%q: Expected output %q, but got %q", tt.args, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%q: Expected status %d, but got %d", tt.args, tt.expectedStatus, status)
+		}
+
+		if !reflect.DeepEqual(shell.traps, tt.expectedTraps) {
+			t.Errorf("%q: Expected traps %v, but got %v", tt.args, tt.expectedTraps, shell.traps)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package builtins
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cd changes the working directory. Without -P the path is followed
+// logically: ".." goes back to where a symbolic link was entered from.
+// Relative paths are searched in CDPATH, and "-" is the previous
+// directory. PWD and OLDPWD are kept up to date.
+func cd(shell Shell, streams Streams, args []string) int {
+	letters, args, err := options(args[1:], "LP")
+
+	if err != nil {
+		errorf(streams, "cd: %s", err)
+		return 2
+	}
+
+	if len(args) > 1 {
+		errorf(streams, "cd: too many arguments")
+		return 1
+	}
+
+	environment := shell.Env()
+	physical := strings.HasSuffix(letters, "P")
+	show := false
+	target := ""
+
+	switch {
+	case len(args) == 0:
+		home, _ := environment.Get("HOME")
+
+		if home == "" {
+			errorf(streams, "cd: HOME not set")
+			return 1
+		}
+
+		target = home
+	case args[0] == "-":
+		previous, _ := environment.Get("OLDPWD")
+
+		if previous == "" {
+			errorf(streams, "cd: OLDPWD not set")
+			return 1
+		}
+
+		target, show = previous, true
+	default:
+		target = args[0]
+	}
+
+	if candidate, found := searchCdpath(shell, target); found {
+		target, show = candidate, true
+	}
+
+	dir := target
+
+	if !filepath.IsAbs(dir) {
+		dir = shell.Dir() + string(filepath.Separator) + dir
+	}
+
+	// Logically, ".." removes what comes before it, links included.
+	// Physically, it's the parent of where the links lead.
+	if physical {
+		if dir, err = filepath.EvalSymlinks(dir); err != nil {
+			errorf(streams, "cd: %s: %s", target, describe(err))
+			return 1
+		}
+	} else {
+		dir = filepath.Clean(dir)
+	}
+
+	if info, err := os.Stat(dir); err != nil {
+		errorf(streams, "cd: %s: %s", target, describe(err))
+		return 1
+	} else if !info.IsDir() {
+		errorf(streams, "cd: %s: not a directory", target)
+		return 1
+	}
+
+	if err := environment.Set("OLDPWD", shell.Dir()); err != nil {
+		errorf(streams, "cd: %s", err)
+		return 1
+	}
+
+	if err := environment.Set("PWD", dir); err != nil {
+		errorf(streams, "cd: %s", err)
+		return 1
+	}
+
+	shell.SetDir(dir)
+
+	if show {
+		return writeln(streams, "cd", dir)
+	}
+
+	return 0
+}
+
+// searchCdpath looks for target in the directories listed in CDPATH. Paths
+// that are absolute or start with "." or ".." aren't searched. It tells if
+// the directory was found through an entry that isn't empty, since cd
+// prints where it went in that case.
+func searchCdpath(shell Shell, target string) (string, bool) {
+	cdpath, _ := shell.Env().Get("CDPATH")
+
+	if cdpath == "" || filepath.IsAbs(target) || target == "." || target == ".." ||
+		strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") {
+		return target, false
+	}
+
+	for _, entry := range filepath.SplitList(cdpath) {
+		candidate := filepath.Join(entry, target)
+
+		if !filepath.IsAbs(candidate) {
+			candidate = filepath.Join(shell.Dir(), candidate)
+		}
+
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, entry != ""
+		}
+	}
+
+	return target, false
+}
+
+// pwd prints the working directory. With -P, symbolic links are resolved.
+func pwd(shell Shell, streams Streams, args []string) int {
+	letters, args, err := options(args[1:], "LP")
+
+	if err != nil {
+		errorf(streams, "pwd: %s", err)
+		return 2
+	}
+
+	if len(args) > 0 {
+		errorf(streams, "pwd: too many arguments")
+		return 1
+	}
+
+	dir := shell.Dir()
+
+	if strings.HasSuffix(letters, "P") {
+		if dir, err = filepath.EvalSymlinks(dir); err != nil {
+			errorf(streams, "pwd: %s", describe(err))
+			return 1
+		}
+	}
+
+	return writeln(streams, "pwd", dir)
+}
+
+// describe returns the message of a path error without the operation and
+// the path, which the caller already shows.
+func describe(err error) string {
+	var pathError *fs.PathError
+
+	if errors.As(err, &pathError) {
+		return pathError.Err.Error()
+	}
+
+	return err.Error()
+}
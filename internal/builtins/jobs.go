@@ -0,0 +1,438 @@
+package builtins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Job is a pipeline or list the shell runs as a unit, in the background
+// or, with job control, in the foreground.
+type Job interface {
+	ID() int
+	Command() string
+	// Pgid is the process group of the job, 0 without job control.
+	Pgid() int
+	// Pids are the programs the job started. The first one is what $!
+	// gives, which is made up for a job that first runs something else.
+	// There's always at least that one.
+	Pids() []int
+	// State is "Running", "Stopped" or, once it's done, how the job ended.
+	State() string
+}
+
+// Jobs is the job table of the shell.
+type Jobs interface {
+	List() []Job
+	// Find returns the job a spec like %1, %+ or %name refers to.
+	Find(spec string) (Job, error)
+	// Marker is "+" for the current job, "-" for the previous one and " "
+	// for the others.
+	Marker(job Job) string
+	// Foreground continues job in the foreground, waiting until it's done
+	// or stopped again. It returns its status.
+	Foreground(job Job) int
+	// Background continues job in the background.
+	Background(job Job)
+	// Wait waits until job is done or stopped, returning its status.
+	Wait(job Job) int
+	// Signal sends signal to job, its programs and the commands the shell
+	// runs for it, which stop at signals that kill processes.
+	Signal(job Job, signal syscall.Signal) error
+	// Remove forgets about a job.
+	Remove(job Job)
+}
+
+// JobLine is how a job is shown by jobs and when the shell notices it
+// stopped or ended, like "[1]+  Stopped                 vim".
+func JobLine(id int, marker string, state string, command string) string {
+	return fmt.Sprintf("[%d]%s  %s", id, marker, jobStatus(state, command))
+}
+
+func jobStatus(state string, command string) string {
+	if state == "Running" {
+		command += " &"
+	}
+
+	return fmt.Sprintf("%-24s%s", state, command)
+}
+
+func isDone(job Job) bool {
+	state := job.State()
+
+	return state != "Running" && state != "Stopped"
+}
+
+// jobs lists the jobs given, or all of them. -l shows their process ids
+// too and -p only their process groups. The ones that are done are shown
+// a last time and forgotten.
+func jobs(shell Shell, streams Streams, args []string) int {
+	letters, args, err := options(args[1:], "lp")
+
+	if err != nil {
+		errorf(streams, "jobs: %s", err)
+		return 2
+	}
+
+	table := shell.Jobs()
+	list, status := table.List(), 0
+
+	if len(args) > 0 {
+		list = []Job{}
+
+		for _, spec := range args {
+			job, err := table.Find(spec)
+
+			if err != nil {
+				errorf(streams, "jobs: %s", err)
+				status = 1
+				continue
+			}
+
+			list = append(list, job)
+		}
+	}
+
+	for _, job := range list {
+		marker, state := table.Marker(job), job.State()
+		line := JobLine(job.ID(), marker, state, job.Command())
+
+		switch {
+		case strings.ContainsRune(letters, 'p'):
+			line = strconv.Itoa(jobPid(job))
+		case strings.ContainsRune(letters, 'l'):
+			line = fmt.Sprintf("[%d]%s %d %s", job.ID(), marker, jobPid(job),
+				jobStatus(state, job.Command()))
+		}
+
+		if writeln(streams, "jobs", line) != 0 {
+			return 1
+		}
+
+		if isDone(job) {
+			table.Remove(job)
+		}
+	}
+
+	return status
+}
+
+// jobPid is the process group of job, or its first process without one.
+func jobPid(job Job) int {
+	if pgid := job.Pgid(); pgid != 0 {
+		return pgid
+	}
+
+	return job.Pids()[0]
+}
+
+// findJob returns the job an operand of fg and bg refers to, the current
+// one by default.
+func findJob(shell Shell, streams Streams, name string, spec string) (Job, bool) {
+	if !shell.ListOptions()["monitor"] {
+		errorf(streams, "%s: no job control", name)
+		return nil, false
+	}
+
+	job, err := shell.Jobs().Find(spec)
+
+	if err != nil {
+		errorf(streams, "%s: %s", name, err)
+		return nil, false
+	}
+
+	return job, true
+}
+
+// fg continues a job in the foreground and waits for it.
+func fg(shell Shell, streams Streams, args []string) int {
+	spec := "%+"
+
+	if len(args) > 1 {
+		spec = args[1]
+	}
+
+	job, ok := findJob(shell, streams, "fg", spec)
+
+	if !ok {
+		return 1
+	}
+
+	if isDone(job) {
+		errorf(streams, "fg: job has terminated")
+		shell.Jobs().Remove(job)
+
+		return 1
+	}
+
+	if status := writeln(streams, "fg", job.Command()); status != 0 {
+		return status
+	}
+
+	return shell.Jobs().Foreground(job)
+}
+
+// bg continues stopped jobs in the background.
+func bg(shell Shell, streams Streams, args []string) int {
+	specs, status := args[1:], 0
+
+	if len(specs) == 0 {
+		specs = []string{"%+"}
+	}
+
+	for _, spec := range specs {
+		job, ok := findJob(shell, streams, "bg", spec)
+
+		switch {
+		case !ok:
+			status = 1
+			continue
+		case job.State() == "Running":
+			errorf(streams, "bg: job %d already in background", job.ID())
+			continue
+		case isDone(job):
+			errorf(streams, "bg: job has terminated")
+			status = 1
+
+			continue
+		}
+
+		shell.Jobs().Background(job)
+
+		if writeln(streams, "bg", fmt.Sprintf("[%d]%s %s &", job.ID(),
+			shell.Jobs().Marker(job), job.Command())) != 0 {
+			return 1
+		}
+	}
+
+	return status
+}
+
+// wait waits for the jobs given by process id or job spec, or for every
+// job. The status is the one of the last operand, 127 if it's unknown.
+func wait(shell Shell, streams Streams, args []string) int {
+	table := shell.Jobs()
+
+	if len(args) == 1 {
+		for _, job := range table.List() {
+			table.Wait(job)
+
+			if isDone(job) {
+				table.Remove(job)
+			}
+		}
+
+		return 0
+	}
+
+	status := 0
+
+	for _, operand := range args[1:] {
+		job, err := waitOperand(table, operand)
+
+		if err != nil {
+			errorf(streams, "wait: %s", err)
+			status = 127
+
+			continue
+		}
+
+		status = table.Wait(job)
+
+		if isDone(job) {
+			table.Remove(job)
+		}
+	}
+
+	return status
+}
+
+func waitOperand(table Jobs, operand string) (Job, error) {
+	if strings.HasPrefix(operand, "%") {
+		return table.Find(operand)
+	}
+
+	pid, err := strconv.Atoi(operand)
+
+	if err != nil {
+		return nil, fmt.Errorf("`%s': not a pid or valid job spec", operand)
+	}
+
+	for _, job := range table.List() {
+		for _, other := range job.Pids() {
+			if other == pid {
+				return job, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("pid %d is not a child of this shell", pid)
+}
+
+// kill sends a signal, SIGTERM by default, to processes given by process
+// id or job spec. The signal is given with -s name, -name or -number. -l
+// lists the signal names, or tells the name of the signal that made a
+// command exit with the status given.
+func kill(shell Shell, streams Streams, args []string) int {
+	args = args[1:]
+
+	if len(args) > 0 && args[0] == "-l" {
+		return listSignals(streams, args[1:])
+	}
+
+	signal := syscall.SIGTERM
+
+	if len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && args[0] != "--" {
+		name := args[0][1:]
+		args = args[1:]
+
+		if name == "s" || name == "n" {
+			if len(args) == 0 {
+				errorf(streams, "kill: -%s: option requires an argument", name)
+				return 2
+			}
+
+			name, args = args[0], args[1:]
+		}
+
+		var ok bool
+
+		if signal, ok = signalNumber(name); !ok {
+			errorf(streams, "kill: %s: invalid signal specification", name)
+			return 1
+		}
+	}
+
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		errorf(streams, "kill: usage: kill [-s sigspec | -signum | -sigspec] pid | jobspec ...")
+		return 2
+	}
+
+	status := 0
+
+	for _, operand := range args {
+		if err := signalOperand(shell, operand, signal); err != nil {
+			errorf(streams, "kill: %s", err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// signalOperand sends signal to a process id or to a job. The process id
+// $! gives stands for its whole job, like a job spec, and $$ for the shell.
+func signalOperand(shell Shell, operand string, signal syscall.Signal) error {
+	table := shell.Jobs()
+
+	if !strings.HasPrefix(operand, "%") {
+		pid, err := strconv.Atoi(operand)
+
+		if err != nil {
+			return fmt.Errorf("%s: arguments must be process or job IDs", operand)
+		}
+
+		if job := jobWithPid(table, pid); job != nil {
+			err = table.Signal(job, signal)
+		} else if pid == unix.Getpid() {
+			err = shell.Raise(signal)
+		} else {
+			err = unix.Kill(pid, signal)
+		}
+
+		if err != nil {
+			return fmt.Errorf("(%d) - %s", pid, err)
+		}
+
+		return nil
+	}
+
+	job, err := table.Find(operand)
+
+	if err != nil {
+		return err
+	}
+
+	if err := table.Signal(job, signal); err != nil {
+		return fmt.Errorf("%s: %s", operand, err)
+	}
+
+	return nil
+}
+
+// jobWithPid returns the job whose process id, the one $! gives, is pid.
+func jobWithPid(table Jobs, pid int) Job {
+	for _, job := range table.List() {
+		if pids := job.Pids(); len(pids) > 0 && pids[0] == pid {
+			return job
+		}
+	}
+
+	return nil
+}
+
+// signalNumber takes a signal by its number or by its name, with or
+// without the SIG prefix.
+func signalNumber(name string) (syscall.Signal, bool) {
+	if n, err := strconv.Atoi(name); err == nil {
+		return syscall.Signal(n), n >= 0 && (n == 0 || unix.SignalName(syscall.Signal(n)) != "")
+	}
+
+	name = strings.ToUpper(name)
+
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+
+	signal := unix.SignalNum(name)
+
+	return signal, signal != 0
+}
+
+// listSignals writes the names of the signals given by number or exit
+// status, or of every signal. Names given are turned into numbers.
+func listSignals(streams Streams, args []string) int {
+	if len(args) == 0 {
+		for n := 1; n < 32; n++ {
+			if name := unix.SignalName(syscall.Signal(n)); name != "" {
+				if writeln(streams, "kill", strings.TrimPrefix(name, "SIG")) != 0 {
+					return 1
+				}
+			}
+		}
+
+		return 0
+	}
+
+	status := 0
+
+	for _, arg := range args {
+		line := ""
+
+		if n, err := strconv.Atoi(arg); err == nil {
+			line = strings.TrimPrefix(unix.SignalName(syscall.Signal(n&127)), "SIG")
+		} else if signal, ok := signalNumber(arg); ok {
+			line = strconv.Itoa(int(signal))
+		}
+
+		if line == "" {
+			errorf(streams, "kill: %s: invalid signal specification", arg)
+			status = 1
+
+			continue
+		}
+
+		if writeln(streams, "kill", line) != 0 {
+			return 1
+		}
+	}
+
+	return status
+}
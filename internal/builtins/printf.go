@@ -0,0 +1,353 @@
+package builtins
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// echo writes its arguments separated by spaces and followed by a newline.
+// Options are only taken before the first operand: -n leaves the newline
+// out, -e interprets escape sequences and -E doesn't, which is the default.
+func echo(shell Shell, streams Streams, args []string) int {
+	args = args[1:]
+	newline, escapes := true, false
+
+	for len(args) > 0 && isEchoOption(args[0]) {
+		for _, letter := range args[0][1:] {
+			switch letter {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+
+		args = args[1:]
+	}
+
+	out := &strings.Builder{}
+
+	for i, arg := range args {
+		if i > 0 {
+			out.WriteByte(' ')
+		}
+
+		if !escapes {
+			out.WriteString(arg)
+			continue
+		}
+
+		if stop := unescape(out, arg, true); stop {
+			newline = false
+			break
+		}
+	}
+
+	if newline {
+		out.WriteByte('\n')
+	}
+
+	return write(streams, "echo", out.String())
+}
+
+func isEchoOption(arg string) bool {
+	return len(arg) > 1 && arg[0] == '-' && strings.Trim(arg[1:], "neE") == ""
+}
+
+// unescape writes str to out with its escape sequences interpreted. It
+// tells if \c was found, which means nothing else should be written.
+func unescape(out *strings.Builder, str string, zeroOctal bool) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] != '\\' {
+			out.WriteByte(str[i])
+			continue
+		}
+
+		length, stop := escape(out, str[i+1:], zeroOctal)
+
+		if stop {
+			return true
+		}
+
+		i += length
+	}
+
+	return false
+}
+
+// escape writes what the escape sequence at the start of str means, str
+// being what comes after the backslash. It returns the length of the
+// sequence and if it's \c. With zeroOctal, octal numbers are written like
+// \0ooo, as in echo and %b, and like \ooo otherwise.
+func escape(out *strings.Builder, str string, zeroOctal bool) (int, bool) {
+	if str == "" {
+		out.WriteByte('\\')
+		return 0, false
+	}
+
+	if char, ok := escapes[str[0]]; ok {
+		out.WriteByte(char)
+		return 1, false
+	}
+
+	switch {
+	case str[0] == 'c':
+		return 1, true
+	case zeroOctal && str[0] == '0':
+		n, length := octal(str[1:], 3)
+		out.WriteByte(n)
+
+		return length + 1, false
+	case !zeroOctal && '0' <= str[0] && str[0] <= '7':
+		n, length := octal(str, 3)
+		out.WriteByte(n)
+
+		return length, false
+	}
+
+	out.WriteByte('\\')
+	out.WriteByte(str[0])
+
+	return 1, false
+}
+
+var escapes = map[byte]byte{
+	'\\': '\\',
+	'a':  '\a',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+}
+
+// octal reads up to max octal digits from the start of str, returning the
+// byte they make and how many there were.
+func octal(str string, max int) (byte, int) {
+	n, length := 0, 0
+
+	for length < max && length < len(str) && '0' <= str[length] && str[length] <= '7' {
+		n = n*8 + int(str[length]-'0')
+		length++
+	}
+
+	return byte(n), length
+}
+
+// printf writes its arguments as the format tells, like printf(3). The
+// format is used again while there are arguments left, and the arguments
+// that are missing are taken as empty strings or zero.
+func printf(shell Shell, streams Streams, args []string) int {
+	if len(args) > 1 && args[1] == "--" {
+		args = args[1:]
+	}
+
+	if len(args) < 2 {
+		errorf(streams, "printf: usage: printf format [arguments]")
+		return 2
+	}
+
+	printer := &printer{streams: streams, args: args[2:]}
+
+	for {
+		remaining := len(printer.args)
+
+		if stop := printer.print(args[1]); stop || len(printer.args) == 0 ||
+			len(printer.args) == remaining {
+			break
+		}
+	}
+
+	if status := write(streams, "printf", printer.out.String()); status != 0 {
+		return status
+	}
+
+	return printer.status
+}
+
+type printer struct {
+	streams Streams
+	args    []string
+	out     strings.Builder
+	status  int
+}
+
+// directive matches a conversion specification, without the "%". The
+// precision is matched with its dot, since ".d" isn't the same as "d".
+var directive = regexp.MustCompile(`^([-+ #0]*)(\*|[0-9]*)(\.\*|\.[0-9]*)?(.?)`)
+
+// print writes format once. It tells if the output should stop there,
+// because of \c or an invalid directive.
+func (p *printer) print(format string) bool {
+	for i := 0; i < len(format); i++ {
+		switch {
+		case format[i] == '\\':
+			length, stop := escape(&p.out, format[i+1:], false)
+
+			if stop {
+				return true
+			}
+
+			i += length
+		case format[i] == '%' && i+1 < len(format) && format[i+1] == '%':
+			p.out.WriteByte('%')
+			i++
+		case format[i] == '%':
+			match := directive.FindStringSubmatch(format[i+1:])
+			i += len(match[0])
+
+			if stop := p.convert(match[1], match[2], match[3], match[4]); stop {
+				return true
+			}
+		default:
+			p.out.WriteByte(format[i])
+		}
+	}
+
+	return false
+}
+
+// convert writes the next argument as told by a directive. Width and
+// precision given as "*" are taken from the arguments too.
+func (p *printer) convert(flags, width, precision, verb string) bool {
+	if width == "*" {
+		width = strconv.FormatInt(p.integer(p.next()), 10)
+	}
+
+	if precision == ".*" {
+		precision = "." + strconv.FormatInt(p.integer(p.next()), 10)
+	}
+
+	spec := "%" + flags + width + precision
+
+	switch verb {
+	case "s":
+		fmt.Fprintf(&p.out, spec+"s", p.next())
+	case "b":
+		arg := &strings.Builder{}
+		stop := unescape(arg, p.next(), true)
+		fmt.Fprintf(&p.out, spec+"s", arg.String())
+
+		return stop
+	case "c":
+		arg := p.next()
+		_, size := utf8.DecodeRuneInString(arg)
+		fmt.Fprintf(&p.out, "%"+flags+width+"s", arg[:size])
+	case "d", "i":
+		fmt.Fprintf(&p.out, spec+"d", p.integer(p.next()))
+	case "u":
+		fmt.Fprintf(&p.out, spec+"d", uint64(p.integer(p.next())))
+	case "o", "x", "X":
+		fmt.Fprintf(&p.out, spec+verb, uint64(p.integer(p.next())))
+	case "e", "E", "f", "F":
+		fmt.Fprintf(&p.out, spec+verb, p.float(p.next()))
+	case "g", "G":
+		if precision == "" {
+			spec += ".6"
+		}
+
+		fmt.Fprintf(&p.out, spec+verb, p.float(p.next()))
+	case "a":
+		p.hexFloat(spec + "x")
+	case "A":
+		p.hexFloat(spec + "X")
+	default:
+		errorf(p.streams, "printf: %%%s: invalid directive", flags+width+precision+verb)
+		p.status = 1
+
+		return true
+	}
+
+	return false
+}
+
+// hexFloat writes the next argument as a hexadecimal floating point
+// number. Go writes the exponent with at least two digits, C with one.
+func (p *printer) hexFloat(spec string) {
+	hex := fmt.Sprintf(spec, p.float(p.next()))
+	p.out.WriteString(exponentZeros.ReplaceAllString(hex, "$1$2"))
+}
+
+var exponentZeros = regexp.MustCompile(`([pP][-+])0(\d)`)
+
+func (p *printer) next() string {
+	if len(p.args) == 0 {
+		return ""
+	}
+
+	arg := p.args[0]
+	p.args = p.args[1:]
+
+	return arg
+}
+
+// integer converts arg like a C integer constant: decimal, octal with a
+// leading 0 or hexadecimal with a leading 0x. A leading quote gives the
+// value of the character after it.
+func (p *printer) integer(arg string) int64 {
+	if value, ok := character(arg); ok {
+		return value
+	}
+
+	str := strings.TrimLeft(arg, " \t\n")
+	sign, digits := "", str
+
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		sign, digits = digits[:1], digits[1:]
+	}
+
+	base := 10
+
+	switch {
+	case strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X"):
+		base, digits = 16, digits[2:]
+	case strings.HasPrefix(digits, "0") && len(digits) > 1:
+		base = 8
+	}
+
+	n, err := strconv.ParseInt(sign+digits, base, 64)
+
+	if err != nil && str != "" {
+		errorf(p.streams, "printf: %s: invalid number", arg)
+		p.status = 1
+	}
+
+	return n
+}
+
+func (p *printer) float(arg string) float64 {
+	if value, ok := character(arg); ok {
+		return float64(value)
+	}
+
+	str := strings.TrimSpace(arg)
+	n, err := strconv.ParseFloat(str, 64)
+
+	if err != nil && str != "" {
+		errorf(p.streams, "printf: %s: invalid number", arg)
+		p.status = 1
+	}
+
+	return n
+}
+
+// character returns the value of the character after a leading quote.
+func character(arg string) (int64, bool) {
+	if arg == "" || arg[0] != '\'' && arg[0] != '"' {
+		return 0, false
+	}
+
+	char, _ := utf8.DecodeRuneInString(arg[1:])
+
+	if char == utf8.RuneError {
+		return 0, true
+	}
+
+	return int64(char), true
+}
@@ -0,0 +1,240 @@
+package builtins
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// read reads a line and splits it into fields at the characters of IFS,
+// assigning them to the variables named, REPLY if there's none. The last
+// one gets what's left of the line. Without -r, a backslash quotes the
+// character after it and one at the end of the line continues it on the
+// next. If the standard input is a terminal the line is read with the line
+// editor, showing the prompt given by -p.
+func read(shell Shell, streams Streams, args []string) int {
+	raw, prompt, args, err := readOptions(args[1:])
+
+	if err != nil {
+		errorf(streams, "read: %s", err)
+		return 2
+	}
+
+	if len(args) == 0 {
+		args = []string{"REPLY"}
+	}
+
+	for _, name := range args {
+		if !isName(name) {
+			errorf(streams, "read: `%s': not a valid identifier", name)
+			return 2
+		}
+	}
+
+	next := func() (string, error) {
+		return ReadLine(streams.Stdin)
+	}
+
+	if editor := shell.LineReader(); editor != nil && isTerminal(streams.Stdin) {
+		next = func() (string, error) {
+			line, err := editor(prompt)
+			prompt = ""
+
+			return line, err
+		}
+	}
+
+	text, quoted := []rune{}, []bool{}
+	status := 0
+
+	for {
+		line, err := next()
+
+		if err != nil && err != io.EOF {
+			errorf(streams, "read: %s", describe(err))
+			return 2
+		}
+
+		continued := false
+		runes := []rune(line)
+
+		for i := 0; i < len(runes); i++ {
+			if !raw && runes[i] == '\\' {
+				if i++; i == len(runes) {
+					continued = err == nil
+					break
+				}
+
+				text, quoted = append(text, runes[i]), append(quoted, true)
+				continue
+			}
+
+			text, quoted = append(text, runes[i]), append(quoted, false)
+		}
+
+		if err == io.EOF {
+			status = 1
+			break
+		}
+
+		if !continued {
+			break
+		}
+	}
+
+	ifs, set := shell.Env().Get("IFS")
+
+	if !set {
+		ifs = " \t\n"
+	}
+
+	fields := splitFields(text, quoted, ifs, len(args))
+
+	for i, name := range args {
+		value := ""
+
+		if i < len(fields) {
+			value = fields[i]
+		}
+
+		if err := shell.Env().Set(name, value); err != nil {
+			errorf(streams, "read: %s", err)
+			status = 2
+		}
+	}
+
+	return status
+}
+
+// readOptions takes -r and -p, which is followed by the prompt, from the
+// start of args.
+func readOptions(args []string) (bool, string, []string, error) {
+	raw, prompt := false, ""
+
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+
+		if arg == "--" {
+			break
+		}
+
+		for i, letter := range arg[1:] {
+			switch letter {
+			case 'r':
+				raw = true
+				continue
+			case 'p':
+			default:
+				return false, "", nil, fmt.Errorf("-%c: invalid option", letter)
+			}
+
+			if prompt = arg[i+2:]; prompt != "" {
+				break
+			}
+
+			if len(args) == 0 {
+				return false, "", nil, errors.New("-p: option requires an argument")
+			}
+
+			prompt, args = args[0], args[1:]
+
+			break
+		}
+	}
+
+	return raw, prompt, args, nil
+}
+
+// ReadLine reads up to a newline, which isn't returned, or to the end of
+// the input, returning io.EOF with what was read. Files that can seek are
+// read a block at a time and then put back right after the newline.
+// Anything else is read a byte at a time. Either way, what comes after the
+// line is left for whoever reads the input next.
+func ReadLine(input io.Reader) (string, error) {
+	seeker, seekable := input.(io.Seeker)
+
+	if seekable {
+		if _, err := seeker.Seek(0, io.SeekCurrent); err != nil {
+			seekable = false
+		}
+	}
+
+	buffer := make([]byte, 1)
+
+	if seekable {
+		buffer = make([]byte, 512)
+	}
+
+	line := []byte{}
+
+	for {
+		n, err := input.Read(buffer)
+
+		if end := bytes.IndexByte(buffer[:n], '\n'); end != -1 {
+			line = append(line, buffer[:end]...)
+
+			if seekable {
+				_, err = seeker.Seek(int64(end+1-n), io.SeekCurrent)
+				return string(line), err
+			}
+
+			return string(line), nil
+		}
+
+		line = append(line, buffer[:n]...)
+
+		if err != nil {
+			return string(line), err
+		}
+	}
+}
+
+// splitFields splits text into n fields at the characters of ifs that
+// weren't quoted. Spaces, tabs and newlines in ifs around the fields are
+// dropped, and the last field is the rest of the text.
+func splitFields(text []rune, quoted []bool, ifs string, n int) []string {
+	separator := func(i int) bool {
+		return !quoted[i] && strings.ContainsRune(ifs, text[i])
+	}
+
+	blank := func(i int) bool {
+		return separator(i) && strings.ContainsRune(" \t\n", text[i])
+	}
+
+	i := 0
+	skipBlanks := func() {
+		for i < len(text) && blank(i) {
+			i++
+		}
+	}
+
+	skipBlanks()
+	fields := []string{}
+
+	for len(fields) < n-1 && i < len(text) {
+		start := i
+
+		for i < len(text) && !separator(i) {
+			i++
+		}
+
+		fields = append(fields, string(text[start:i]))
+		skipBlanks()
+
+		if i < len(text) && separator(i) {
+			i++
+			skipBlanks()
+		}
+	}
+
+	end := len(text)
+
+	for end > i && blank(end-1) {
+		end--
+	}
+
+	return append(fields, string(text[i:end]))
+}
@@ -0,0 +1,184 @@
+package builtins
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// set turns options on with "-" and off with "+", by their letters or by
+// their names after -o. What comes after the options, or after "--",
+// replaces the positional parameters. Without arguments the variables are
+// printed, and -o or +o alone print the options.
+func set(shell Shell, streams Streams, args []string) int {
+	args = args[1:]
+
+	if len(args) == 0 {
+		return printVariables(shell, streams)
+	}
+
+	replace := false
+
+	for len(args) > 0 {
+		arg := args[0]
+
+		if arg == "--" {
+			args, replace = args[1:], true
+			break
+		}
+
+		if arg == "-" {
+			args = args[1:]
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' && arg[0] != '+' {
+			break
+		}
+
+		args = args[1:]
+		on := arg[0] == '-'
+
+		for _, flag := range arg[1:] {
+			if flag != 'o' {
+				if err := shell.SetFlag(flag, on); err != nil {
+					errorf(streams, "set: %s", err)
+					return 2
+				}
+
+				continue
+			}
+
+			if len(args) == 0 {
+				if status := printOptions(shell, streams, on); status != 0 {
+					return status
+				}
+
+				continue
+			}
+
+			if err := shell.SetOption(args[0], on); err != nil {
+				errorf(streams, "set: %s", err)
+				return 2
+			}
+
+			args = args[1:]
+		}
+	}
+
+	if replace || len(args) > 0 {
+		shell.SetPositional(args)
+	}
+
+	return 0
+}
+
+func printVariables(shell Shell, streams Streams) int {
+	environment := shell.Env()
+
+	for _, name := range environment.Names() {
+		variable := environment.Lookup(name)
+
+		if !variable.IsSet {
+			continue
+		}
+
+		if status := writeln(streams, "set", name+"="+Quote(variable.Value)); status != 0 {
+			return status
+		}
+	}
+
+	return 0
+}
+
+// printOptions prints whether each option is on. With "+o" it's printed as
+// the commands that would set them the same way.
+func printOptions(shell Shell, streams Streams, human bool) int {
+	options := shell.ListOptions()
+	names := []string{}
+
+	for name := range options {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		line := ""
+
+		switch {
+		case human && options[name]:
+			line = fmt.Sprintf("%-15s on", name)
+		case human:
+			line = fmt.Sprintf("%-15s off", name)
+		case options[name]:
+			line = "set -o " + name
+		default:
+			line = "set +o " + name
+		}
+
+		if status := writeln(streams, "set", line); status != 0 {
+			return status
+		}
+	}
+
+	return 0
+}
+
+// shift drops the first n positional parameters, 1 if n isn't given.
+func shift(shell Shell, streams Streams, args []string) int {
+	if len(args) > 2 {
+		errorf(streams, "shift: too many arguments")
+		return 1
+	}
+
+	n := 1
+
+	if len(args) == 2 {
+		count, err := strconv.Atoi(args[1])
+
+		if err != nil {
+			errorf(streams, "shift: %s: numeric argument required", args[1])
+			return 1
+		}
+
+		n = count
+	}
+
+	params := shell.Positional()
+
+	if n < 0 || n > len(params) {
+		errorf(streams, "shift: %d: shift count out of range", n)
+		return 1
+	}
+
+	shell.SetPositional(params[n:])
+
+	return 0
+}
+
+// exit makes the shell exit with the status given, or with the status of
+// the last command.
+func exit(shell Shell, streams Streams, args []string) int {
+	if len(args) > 2 {
+		errorf(streams, "exit: too many arguments")
+		return 1
+	}
+
+	status := shell.Status()
+
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+
+		if err != nil {
+			errorf(streams, "exit: %s: numeric argument required", args[1])
+			n = 2
+		}
+
+		status = n & 0xff
+	}
+
+	shell.Exit(status)
+
+	return status
+}
@@ -0,0 +1,357 @@
+package builtins
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// test evaluates the expression in its arguments, the status is 0 if it's
+// true and 1 if it's false. Called as "[", the last argument must be "]".
+func test(shell Shell, streams Streams, args []string) int {
+	name := args[0]
+	args = args[1:]
+
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			errorf(streams, "[: missing `]'")
+			return 2
+		}
+
+		args = args[:len(args)-1]
+	}
+
+	tester := &tester{shell: shell, streams: streams}
+	result, err := tester.evaluate(args)
+
+	if err != nil {
+		errorf(streams, "%s: %s", name, err)
+		return 2
+	}
+
+	if !result {
+		return 1
+	}
+
+	return 0
+}
+
+var errMissingArgument = errors.New("argument expected")
+
+var unaryOperators = map[string]bool{
+	"-b": true, "-c": true, "-d": true, "-e": true, "-f": true, "-g": true, "-h": true,
+	"-L": true, "-n": true, "-p": true, "-r": true, "-S": true, "-s": true, "-t": true,
+	"-u": true, "-w": true, "-x": true, "-z": true,
+}
+
+var binaryOperators = map[string]bool{
+	"=": true, "!=": true, "-eq": true, "-ne": true, "-gt": true, "-ge": true, "-lt": true,
+	"-le": true, "-ef": true, "-nt": true, "-ot": true,
+}
+
+type tester struct {
+	shell   Shell
+	streams Streams
+	args    []string
+	pos     int
+}
+
+// evaluate follows the POSIX rules that decide what the arguments mean by
+// how many there are. Only with more than four the expression is parsed,
+// -a binding tighter than -o.
+func (t *tester) evaluate(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		if args[0] == "!" {
+			result, err := t.evaluate(args[1:])
+			return !result, err
+		}
+
+		if unaryOperators[args[0]] {
+			return t.unary(args[0], args[1])
+		}
+
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		if binaryOperators[args[1]] {
+			return t.binary(args[0], args[1], args[2])
+		}
+
+		if args[1] == "-a" || args[1] == "-o" {
+			break
+		}
+
+		if args[0] == "!" {
+			result, err := t.evaluate(args[1:])
+			return !result, err
+		}
+
+		if args[0] == "(" && args[2] == ")" {
+			return t.evaluate(args[1:2])
+		}
+
+		return false, fmt.Errorf("%s: binary operator expected", args[1])
+	case 4:
+		if args[0] == "!" {
+			result, err := t.evaluate(args[1:])
+			return !result, err
+		}
+
+		if args[0] == "(" && args[3] == ")" {
+			return t.evaluate(args[1:3])
+		}
+	}
+
+	t.args, t.pos = args, 0
+	result, err := t.or()
+
+	if err == nil && t.pos < len(t.args) {
+		err = fmt.Errorf("%s: unexpected argument", t.args[t.pos])
+	}
+
+	return result, err
+}
+
+func (t *tester) or() (bool, error) {
+	result, err := t.and()
+
+	for err == nil && t.peek() == "-o" {
+		t.pos++
+
+		var right bool
+
+		right, err = t.and()
+		result = result || right
+	}
+
+	return result, err
+}
+
+func (t *tester) and() (bool, error) {
+	result, err := t.not()
+
+	for err == nil && t.peek() == "-a" {
+		t.pos++
+
+		var right bool
+
+		right, err = t.not()
+		result = result && right
+	}
+
+	return result, err
+}
+
+// not reads a negation. A "!" followed by a binary operator is the left
+// operand instead.
+func (t *tester) not() (bool, error) {
+	if t.peek() == "!" && t.pos+1 < len(t.args) && !t.isBinary(t.pos+1) {
+		t.pos++
+		result, err := t.not()
+
+		return !result, err
+	}
+
+	return t.primary()
+}
+
+// primary reads a parenthesized expression, an operator with its operands
+// or a string alone, which is true when it isn't empty.
+func (t *tester) primary() (bool, error) {
+	if t.pos >= len(t.args) {
+		return false, errMissingArgument
+	}
+
+	arg := t.args[t.pos]
+	rest := len(t.args) - t.pos
+
+	switch {
+	case rest >= 3 && binaryOperators[t.args[t.pos+1]]:
+		t.pos += 3
+		return t.binary(arg, t.args[t.pos-2], t.args[t.pos-1])
+	case arg == "(":
+		t.pos++
+		result, err := t.or()
+
+		if err != nil {
+			return false, err
+		}
+
+		if t.peek() != ")" {
+			return false, errors.New("`)' expected")
+		}
+
+		t.pos++
+
+		return result, nil
+	case rest >= 2 && unaryOperators[arg]:
+		t.pos += 2
+		return t.unary(arg, t.args[t.pos-1])
+	}
+
+	t.pos++
+
+	return arg != "", nil
+}
+
+func (t *tester) isBinary(pos int) bool {
+	operator := t.args[pos]
+
+	return pos+1 < len(t.args) &&
+		(binaryOperators[operator] || operator == "-a" || operator == "-o")
+}
+
+func (t *tester) peek() string {
+	if t.pos >= len(t.args) {
+		return ""
+	}
+
+	return t.args[t.pos]
+}
+
+func (t *tester) unary(operator, operand string) (bool, error) {
+	switch operator {
+	case "-n":
+		return operand != "", nil
+	case "-z":
+		return operand == "", nil
+	case "-t":
+		fd, err := t.integer(operand)
+		return err == nil && t.isTerminal(fd), err
+	case "-r":
+		return unix.Access(t.path(operand), unix.R_OK) == nil, nil
+	case "-w":
+		return unix.Access(t.path(operand), unix.W_OK) == nil, nil
+	case "-x":
+		return unix.Access(t.path(operand), unix.X_OK) == nil, nil
+	}
+
+	stat := os.Stat
+
+	if operator == "-h" || operator == "-L" {
+		stat = os.Lstat
+	}
+
+	info, err := stat(t.path(operand))
+
+	if err != nil {
+		return false, nil
+	}
+
+	mode := info.Mode()
+
+	switch operator {
+	case "-b":
+		return mode&fs.ModeDevice != 0 && mode&fs.ModeCharDevice == 0, nil
+	case "-c":
+		return mode&fs.ModeCharDevice != 0, nil
+	case "-d":
+		return mode.IsDir(), nil
+	case "-f":
+		return mode.IsRegular(), nil
+	case "-g":
+		return mode&fs.ModeSetgid != 0, nil
+	case "-h", "-L":
+		return mode&fs.ModeSymlink != 0, nil
+	case "-p":
+		return mode&fs.ModeNamedPipe != 0, nil
+	case "-S":
+		return mode&fs.ModeSocket != 0, nil
+	case "-s":
+		return info.Size() > 0, nil
+	case "-u":
+		return mode&fs.ModeSetuid != 0, nil
+	}
+
+	return true, nil
+}
+
+func (t *tester) binary(left, operator, right string) (bool, error) {
+	switch operator {
+	case "=":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "-ef", "-nt", "-ot":
+		return t.compareFiles(left, operator, right), nil
+	}
+
+	a, err := t.integer(left)
+
+	if err != nil {
+		return false, err
+	}
+
+	b, err := t.integer(right)
+
+	if err != nil {
+		return false, err
+	}
+
+	switch operator {
+	case "-eq":
+		return a == b, nil
+	case "-ne":
+		return a != b, nil
+	case "-gt":
+		return a > b, nil
+	case "-ge":
+		return a >= b, nil
+	case "-lt":
+		return a < b, nil
+	}
+
+	return a <= b, nil
+}
+
+// compareFiles tells if two paths are the same file, or which of them was
+// modified last. A file that doesn't exist is older than any other.
+func (t *tester) compareFiles(left, operator, right string) bool {
+	a, errA := os.Stat(t.path(left))
+	b, errB := os.Stat(t.path(right))
+
+	switch operator {
+	case "-ef":
+		return errA == nil && errB == nil && os.SameFile(a, b)
+	case "-nt":
+		return errA == nil && (errB != nil || a.ModTime().After(b.ModTime()))
+	}
+
+	return errB == nil && (errA != nil || a.ModTime().Before(b.ModTime()))
+}
+
+func (t *tester) integer(str string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(str), 10, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", str)
+	}
+
+	return n, nil
+}
+
+// isTerminal tells if fd is a terminal. Only the standard streams are
+// known.
+func (t *tester) isTerminal(fd int64) bool {
+	streams := []any{t.streams.Stdin, t.streams.Stdout, t.streams.Stderr}
+
+	return 0 <= fd && fd < int64(len(streams)) && isTerminal(streams[fd])
+}
+
+func (t *tester) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+
+	return filepath.Join(t.shell.Dir(), name)
+}
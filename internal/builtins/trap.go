@@ -0,0 +1,139 @@
+package builtins
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// conditions are what trap takes besides signals. EXIT is signal 0.
+var conditions = []string{"EXIT", "DEBUG", "ERR", "RETURN"}
+
+// trap sets the action run when the shell gets a signal, or on one of the
+// conditions. An empty action ignores the signal and "-" resets it. So does
+// a first operand that's a number, which makes every operand a condition,
+// or one that's alone. Without operands, or with -p, the traps are listed
+// in a way the shell can read back. -l lists the signal names.
+func trap(shell Shell, streams Streams, args []string) int {
+	args = args[1:]
+
+	switch {
+	case len(args) > 0 && args[0] == "-l":
+		return listSignals(streams, args[1:])
+	case len(args) > 0 && args[0] == "-p":
+		return listTraps(shell, streams, args[1:])
+	case len(args) > 0 && args[0] == "--":
+		args = args[1:]
+	case len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-':
+		errorf(streams, "trap: %s: invalid option", args[0])
+		return 2
+	}
+
+	if len(args) == 0 {
+		return listTraps(shell, streams, nil)
+	}
+
+	action, operands := args[0], args[1:]
+	_, err := strconv.ParseUint(action, 10, 8)
+	reset := action == "-"
+
+	if err == nil || len(operands) == 0 {
+		reset, operands = true, args
+	}
+
+	status := 0
+
+	for _, operand := range operands {
+		condition, ok := trapCondition(operand)
+
+		if !ok {
+			errorf(streams, "trap: %s: invalid signal specification", operand)
+			status = 1
+
+			continue
+		}
+
+		if reset {
+			shell.ResetTrap(condition)
+			continue
+		}
+
+		shell.SetTrap(condition, action)
+	}
+
+	return status
+}
+
+// listTraps writes the traps of the conditions given, or all of them, as
+// the trap commands that set them.
+func listTraps(shell Shell, streams Streams, operands []string) int {
+	traps := shell.Traps()
+	names := []string{}
+
+	for name := range traps {
+		names = append(names, name)
+	}
+
+	slices.SortFunc(names, func(a, b string) int { return conditionOrder(a) - conditionOrder(b) })
+
+	if len(operands) > 0 {
+		names = []string{}
+
+		for _, operand := range operands {
+			condition, ok := trapCondition(operand)
+
+			if !ok {
+				errorf(streams, "trap: %s: invalid signal specification", operand)
+				return 1
+			}
+
+			if _, ok := traps[condition]; ok {
+				names = append(names, condition)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if writeln(streams, "trap", fmt.Sprintf("trap -- %s %s", Quote(traps[name]), name)) != 0 {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// trapCondition takes a signal by its number or name, or a condition, and
+// returns the name trap knows it by: the signal name without SIG or the
+// condition in upper case.
+func trapCondition(arg string) (string, bool) {
+	name := strings.ToUpper(arg)
+
+	if name == "0" {
+		return "EXIT", true
+	}
+
+	if slices.Contains(conditions, name) {
+		return name, true
+	}
+
+	signal, ok := signalNumber(arg)
+
+	if !ok || signal == 0 {
+		return "", false
+	}
+
+	return strings.TrimPrefix(unix.SignalName(signal), "SIG"), true
+}
+
+// conditionOrder puts EXIT first, then the signals by number and then the
+// other conditions.
+func conditionOrder(name string) int {
+	if index := slices.Index(conditions, name); index != -1 {
+		return index * 100
+	}
+
+	return int(unix.SignalNum("SIG" + name))
+}
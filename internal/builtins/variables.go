@@ -0,0 +1,168 @@
+package builtins
+
+import (
+	"strings"
+
+	"github.com/marcos-brito/arosh/internal/env"
+)
+
+// export marks variables to be passed on to the programs the shell runs.
+func export(shell Shell, streams Streams, args []string) int {
+	return declare(shell, streams, args, (*env.Env).Export, func(variable *env.Variable) bool {
+		return variable.Exported
+	})
+}
+
+// readonly makes variables impossible to change.
+func readonly(shell Shell, streams Streams, args []string) int {
+	return declare(shell, streams, args, (*env.Env).SetReadonly, func(variable *env.Variable) bool {
+		return variable.Readonly
+	})
+}
+
+// declare is what export and readonly have in common. Each operand is a
+// name, given the attribute, or a "name=value" assigned before that.
+// Without operands, or with -p, the variables with the attribute are
+// printed in a way the shell can read back.
+func declare(
+	shell Shell,
+	streams Streams,
+	args []string,
+	give func(*env.Env, string),
+	has func(*env.Variable) bool,
+) int {
+	name := args[0]
+	letters, args, err := options(args[1:], "p")
+
+	if err != nil {
+		errorf(streams, "%s: %s", name, err)
+		return 2
+	}
+
+	environment := shell.Env()
+
+	if len(args) == 0 || letters != "" {
+		for _, variable := range environment.Names() {
+			attributes := environment.Lookup(variable)
+
+			if !has(attributes) {
+				continue
+			}
+
+			line := name + " " + variable
+
+			if attributes.IsSet {
+				line += "=" + Quote(attributes.Value)
+			}
+
+			if status := writeln(streams, name, line); status != 0 {
+				return status
+			}
+		}
+
+		return 0
+	}
+
+	status := 0
+
+	for _, arg := range args {
+		variable, value, assign := strings.Cut(arg, "=")
+
+		if !isName(variable) {
+			errorf(streams, "%s: `%s': not a valid identifier", name, arg)
+			status = 1
+			continue
+		}
+
+		if assign {
+			if err := environment.Set(variable, value); err != nil {
+				errorf(streams, "%s: %s", name, err)
+				status = 1
+				continue
+			}
+		}
+
+		give(environment, variable)
+	}
+
+	return status
+}
+
+// local declares variables in the scope of the function being run, so
+// they go away when it returns. A "name=value" operand also assigns them.
+func local(shell Shell, streams Streams, args []string) int {
+	if !shell.InFunction() {
+		errorf(streams, "local: can only be used in a function")
+		return 1
+	}
+
+	environment := shell.Env()
+	status := 0
+
+	for _, arg := range args[1:] {
+		variable, value, assign := strings.Cut(arg, "=")
+
+		if !isName(variable) {
+			errorf(streams, "local: `%s': not a valid identifier", arg)
+			status = 1
+			continue
+		}
+
+		if err := environment.Declare(variable); err != nil {
+			errorf(streams, "local: %s", err)
+			status = 1
+			continue
+		}
+
+		if !assign {
+			continue
+		}
+
+		if err := environment.Set(variable, value); err != nil {
+			errorf(streams, "local: %s", err)
+			status = 1
+		}
+	}
+
+	return status
+}
+
+// unset removes variables, or functions with -f. Without -v, a name that
+// isn't a variable is taken as a function.
+func unset(shell Shell, streams Streams, args []string) int {
+	letters, args, err := options(args[1:], "fv")
+
+	if err != nil {
+		errorf(streams, "unset: %s", err)
+		return 2
+	}
+
+	if strings.Contains(letters, "f") && strings.Contains(letters, "v") {
+		errorf(streams, "unset: cannot unset a function and a variable at once")
+		return 1
+	}
+
+	environment := shell.Env()
+	status := 0
+
+	for _, name := range args {
+		if strings.Contains(letters, "f") ||
+			!strings.Contains(letters, "v") && environment.Lookup(name) == nil {
+			shell.UnsetFunction(name)
+			continue
+		}
+
+		if !isName(name) {
+			errorf(streams, "unset: `%s': not a valid identifier", name)
+			status = 1
+			continue
+		}
+
+		if err := environment.Unset(name); err != nil {
+			errorf(streams, "unset: %s", err)
+			status = 1
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,213 @@
+// Package env holds the variables of the shell. Variables live in scopes:
+// the global one, plus one for each function call and for the assignments
+// written before a command.
+package env
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+var ErrReadonly = errors.New("readonly variable")
+
+type Variable struct {
+	Value string
+	// IsSet is false for variables that were declared, like with
+	// "export NAME", but never given a value.
+	IsSet    bool
+	Exported bool
+	Readonly bool
+}
+
+type Env struct {
+	// scopes go from the global scope to the innermost one.
+	scopes []map[string]*Variable
+}
+
+func New() *Env {
+	return &Env{scopes: []map[string]*Variable{{}}}
+}
+
+// FromEnviron creates an Env holding environ, which is in the form of
+// os.Environ. Every variable in it is exported.
+func FromEnviron(environ []string) *Env {
+	env := New()
+
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+
+		if !ok || name == "" {
+			continue
+		}
+
+		env.scopes[0][name] = &Variable{Value: value, IsSet: true, Exported: true}
+	}
+
+	return env
+}
+
+// Lookup returns the variable visible as name, or nil if there's none.
+func (e *Env) Lookup(name string) *Variable {
+	for n := len(e.scopes) - 1; n >= 0; n-- {
+		if variable, ok := e.scopes[n][name]; ok {
+			return variable
+		}
+	}
+
+	return nil
+}
+
+// Get returns the value of a variable and tells if it's set.
+func (e *Env) Get(name string) (string, bool) {
+	variable := e.Lookup(name)
+
+	if variable == nil || !variable.IsSet {
+		return "", false
+	}
+
+	return variable.Value, true
+}
+
+// Set assigns a value to the variable visible as name, keeping its
+// attributes. Variables that don't exist yet are created in the global
+// scope.
+func (e *Env) Set(name, value string) error {
+	variable := e.variable(name)
+
+	if variable.Readonly {
+		return fmt.Errorf("%s: %w", name, ErrReadonly)
+	}
+
+	variable.Value = value
+	variable.IsSet = true
+
+	return nil
+}
+
+// Unset removes the variable visible as name. A variable in an outer
+// scope with the same name becomes visible again.
+func (e *Env) Unset(name string) error {
+	for n := len(e.scopes) - 1; n >= 0; n-- {
+		variable, ok := e.scopes[n][name]
+
+		if !ok {
+			continue
+		}
+
+		if variable.Readonly {
+			return fmt.Errorf("%s: %w", name, ErrReadonly)
+		}
+
+		delete(e.scopes[n], name)
+
+		return nil
+	}
+
+	return nil
+}
+
+// Export marks a variable to be passed on to child processes.
+func (e *Env) Export(name string) {
+	e.variable(name).Exported = true
+}
+
+// SetReadonly makes a variable impossible to assign or unset from now on.
+func (e *Env) SetReadonly(name string) {
+	e.variable(name).Readonly = true
+}
+
+// Declare creates a variable in the innermost scope, hiding any other with
+// the same name. It's unset until a value is assigned to it. Readonly
+// variables can't be hidden.
+func (e *Env) Declare(name string) error {
+	if variable := e.Lookup(name); variable != nil && variable.Readonly {
+		return fmt.Errorf("%s: %w", name, ErrReadonly)
+	}
+
+	scope := e.scopes[len(e.scopes)-1]
+
+	if _, ok := scope[name]; !ok {
+		scope[name] = &Variable{}
+	}
+
+	return nil
+}
+
+// Push opens a new innermost scope.
+func (e *Env) Push() {
+	e.scopes = append(e.scopes, map[string]*Variable{})
+}
+
+// Pop closes the innermost scope, dropping the variables declared in it.
+// The global scope is never closed.
+func (e *Env) Pop() {
+	if len(e.scopes) > 1 {
+		e.scopes = e.scopes[:len(e.scopes)-1]
+	}
+}
+
+// Names returns the names of every visible variable, sorted.
+func (e *Env) Names() []string {
+	names := []string{}
+
+	for _, scope := range e.scopes {
+		for name := range scope {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Environ returns the exported variables that are set, in the form child
+// processes expect: "NAME=value".
+func (e *Env) Environ() []string {
+	environ := []string{}
+
+	for _, name := range e.Names() {
+		variable := e.Lookup(name)
+
+		if variable.Exported && variable.IsSet {
+			environ = append(environ, name+"="+variable.Value)
+		}
+	}
+
+	return environ
+}
+
+// Clone returns a copy of e. Changing one of them doesn't affect
+// the other.
+func (e *Env) Clone() *Env {
+	clone := &Env{scopes: make([]map[string]*Variable, len(e.scopes))}
+
+	for n, scope := range e.scopes {
+		clone.scopes[n] = maps.Clone(scope)
+
+		for name, variable := range scope {
+			copied := *variable
+			clone.scopes[n][name] = &copied
+		}
+	}
+
+	return clone
+}
+
+// variable returns the variable visible as name, creating an unset one in
+// the global scope if there's none.
+func (e *Env) variable(name string) *Variable {
+	variable := e.Lookup(name)
+
+	if variable == nil {
+		variable = &Variable{}
+		e.scopes[0][name] = variable
+	}
+
+	return variable
+}
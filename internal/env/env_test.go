@@ -0,0 +1,147 @@
+package env
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFromEnviron(t *testing.T) {
+	env := FromEnviron([]string{"HOME=/home/user", "EMPTY=", "EQUALS=a=b", "broken"})
+
+	tests := []struct {
+		name          string
+		expectedValue string
+		expectedSet   bool
+	}{
+		{"HOME", "/home/user", true},
+		{"EMPTY", "", true},
+		{"EQUALS", "a=b", true},
+		{"broken", "", false},
+	}
+
+	for _, tt := range tests {
+		value, ok := env.Get(tt.name)
+
+		if value != tt.expectedValue || ok != tt.expectedSet {
+			t.Errorf(
+				"%s: Expected (%q, %t), but got (%q, %t)",
+				tt.name,
+				tt.expectedValue,
+				tt.expectedSet,
+				value,
+				ok,
+			)
+		}
+	}
+}
+
+func TestScopes(t *testing.T) {
+	env := New()
+	env.Set("global", "1")
+	env.Set("shadowed", "outer")
+
+	env.Push()
+	env.Declare("shadowed")
+	env.Declare("local")
+
+	if _, ok := env.Get("shadowed"); ok {
+		t.Errorf("Expected a declared variable to be unset")
+	}
+
+	env.Set("shadowed", "inner")
+	env.Set("local", "2")
+	env.Set("global", "changed")
+	env.Set("created", "3")
+
+	if value, _ := env.Get("shadowed"); value != "inner" {
+		t.Errorf("Expected shadowed to be %q, but got %q", "inner", value)
+	}
+
+	env.Pop()
+
+	expected := map[string]string{
+		"global":   "changed",
+		"shadowed": "outer",
+		"local":    "",
+		"created":  "3",
+	}
+
+	for name, expectedValue := range expected {
+		if value, _ := env.Get(name); value != expectedValue {
+			t.Errorf("%s: Expected %q, but got %q", name, expectedValue, value)
+		}
+	}
+
+	env.Pop()
+
+	if value, _ := env.Get("global"); value != "changed" {
+		t.Errorf("Expected the global scope to never be popped")
+	}
+}
+
+func TestReadonly(t *testing.T) {
+	env := New()
+	env.Set("CONSTANT", "1")
+	env.SetReadonly("CONSTANT")
+
+	if err := env.Set("CONSTANT", "2"); !errors.Is(err, ErrReadonly) {
+		t.Errorf("Expected setting to fail with ErrReadonly, but got %v", err)
+	}
+
+	if err := env.Unset("CONSTANT"); !errors.Is(err, ErrReadonly) {
+		t.Errorf("Expected unsetting to fail with ErrReadonly, but got %v", err)
+	}
+
+	env.Push()
+
+	if err := env.Declare("CONSTANT"); !errors.Is(err, ErrReadonly) {
+		t.Errorf("Expected declaring to fail with ErrReadonly, but got %v", err)
+	}
+
+	if value, _ := env.Get("CONSTANT"); value != "1" {
+		t.Errorf("Expected CONSTANT to still be %q, but got %q", "1", value)
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	env := FromEnviron([]string{"PATH=/bin", "HOME=/root"})
+	env.Set("unexported", "1")
+	env.Export("declared")
+	env.Set("later", "2")
+	env.Export("later")
+	env.Unset("HOME")
+
+	env.Push()
+	env.Declare("PATH")
+	env.Set("PATH", "/usr/bin")
+	env.Export("PATH")
+
+	expected := []string{"PATH=/usr/bin", "later=2"}
+
+	if got := env.Environ(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestClone(t *testing.T) {
+	env := New()
+	env.Set("shared", "original")
+
+	clone := env.Clone()
+	clone.Set("shared", "changed")
+	clone.Export("shared")
+	clone.Set("new", "1")
+
+	if value, _ := env.Get("shared"); value != "original" {
+		t.Errorf("Expected shared to be %q, but got %q", "original", value)
+	}
+
+	if env.Lookup("shared").Exported {
+		t.Errorf("Expected attributes to not be shared")
+	}
+
+	if _, ok := env.Get("new"); ok {
+		t.Errorf("Expected new to not be set")
+	}
+}
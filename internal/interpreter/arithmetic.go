@@ -0,0 +1,542 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/marcos-brito/arosh/internal/env"
+)
+
+var (
+	ErrDivisionByZero = errors.New("division by zero")
+	ErrOverflow       = errors.New("integer overflow")
+)
+
+// arithmeticOperators are sorted so the longest operators are matched first.
+var arithmeticOperators []string = []string{
+	"<<=", ">>=",
+	"<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"*=", "/=", "%=", "+=", "-=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "|", "^", "!", "~", "=", "?", ":", "(", ")",
+}
+
+var assignmentOperators []string = []string{
+	"=", "*=", "/=", "%=", "+=", "-=", "<<=", ">>=", "&=", "^=", "|=",
+}
+
+// expression is a node of an arithmetic expression. Numbers only have a
+// value and variables only have a name, everything else is an operator
+// applied to its operands.
+type expression struct {
+	operator string
+	value    int64
+	name     string
+	operands []*expression
+}
+
+func (e *expression) String() string {
+	switch {
+	case e.name != "":
+		return e.name
+	case e.operator == "":
+		return strconv.FormatInt(e.value, 10)
+	}
+
+	return fmt.Sprintf("(%s %v)", e.operator, e.operands)
+}
+
+// arithmeticToken is a number, a name or an operator. The end of the
+// expression is an empty token.
+type arithmeticToken struct {
+	value  string
+	number bool
+	name   bool
+}
+
+// evalArithmetic parses and evaluates an arithmetic expression. Variables
+// are read from and assigned in environment.
+func evalArithmetic(source string, environment *env.Env) (int64, error) {
+	tokens, err := arithmeticTokens(source)
+
+	if err != nil {
+		return 0, err
+	}
+
+	parser := arithmeticParser{tokens: tokens}
+	expression, err := parser.expression(0)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if token := parser.current(); token.value != "" {
+		return 0, fmt.Errorf("syntax error: unexpected %q", token.value)
+	}
+
+	evaluator := arithmeticEvaluator{env: environment}
+
+	return evaluator.eval(expression)
+}
+
+func arithmeticTokens(source string) ([]arithmeticToken, error) {
+	tokens := []arithmeticToken{}
+
+	for source = strings.TrimLeft(source, " \t\n"); source != ""; {
+		length := 0
+
+		switch char := rune(source[0]); {
+		case isDigit(char):
+			for length < len(source) && isNameChar(rune(source[length])) {
+				length++
+			}
+
+			tokens = append(tokens, arithmeticToken{value: source[:length], number: true})
+		case isNameStart(char):
+			for length < len(source) && isNameChar(rune(source[length])) {
+				length++
+			}
+
+			tokens = append(tokens, arithmeticToken{value: source[:length], name: true})
+		default:
+			for _, operator := range arithmeticOperators {
+				if strings.HasPrefix(source, operator) {
+					length = len(operator)
+					break
+				}
+			}
+
+			if length == 0 {
+				return nil, fmt.Errorf("syntax error: invalid character %q", char)
+			}
+
+			tokens = append(tokens, arithmeticToken{value: source[:length]})
+		}
+
+		source = strings.TrimLeft(source[length:], " \t\n")
+	}
+
+	return tokens, nil
+}
+
+// arithmeticParser is a Pratt parser. Every infix operator binds its
+// operands with a power, an operator only takes the operands that bind
+// tighter than the operator before it.
+type arithmeticParser struct {
+	tokens   []arithmeticToken
+	position int
+}
+
+func (p *arithmeticParser) current() arithmeticToken {
+	if p.position >= len(p.tokens) {
+		return arithmeticToken{}
+	}
+
+	return p.tokens[p.position]
+}
+
+func (p *arithmeticParser) next() arithmeticToken {
+	token := p.current()
+	p.position++
+
+	return token
+}
+
+func (p *arithmeticParser) expect(operator string) error {
+	if token := p.next(); token.value != operator || token.number || token.name {
+		return p.unexpected(token)
+	}
+
+	return nil
+}
+
+func (p *arithmeticParser) unexpected(token arithmeticToken) error {
+	if token.value == "" {
+		return errors.New("syntax error: operand expected")
+	}
+
+	return fmt.Errorf("syntax error: unexpected %q", token.value)
+}
+
+// expression parses operators whose left binding power is at least power.
+func (p *arithmeticParser) expression(power int) (*expression, error) {
+	lhs, err := p.prefix()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token := p.current()
+		left, right, ok := infixPower(token)
+
+		if !ok || left < power {
+			return lhs, nil
+		}
+
+		p.next()
+
+		switch {
+		case token.value == "?":
+			consequent, err := p.expression(0)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+
+			alternative, err := p.expression(right)
+
+			if err != nil {
+				return nil, err
+			}
+
+			lhs = &expression{operator: "?", operands: []*expression{lhs, consequent, alternative}}
+		default:
+			if slices.Contains(assignmentOperators, token.value) && lhs.name == "" {
+				return nil, fmt.Errorf("%s: attempted assignment to non-variable", token.value)
+			}
+
+			rhs, err := p.expression(right)
+
+			if err != nil {
+				return nil, err
+			}
+
+			lhs = &expression{operator: token.value, operands: []*expression{lhs, rhs}}
+		}
+	}
+}
+
+// prefix parses an operand: a number, a variable, an expression in
+// parentheses or an unary operator applied to an operand.
+func (p *arithmeticParser) prefix() (*expression, error) {
+	token := p.next()
+
+	switch {
+	case token.number:
+		value, err := parseNumber(token.value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &expression{value: value}, nil
+	case token.name:
+		return &expression{name: token.value}, nil
+	case token.value == "(":
+		inner, err := p.expression(0)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return inner, p.expect(")")
+	case slices.Contains([]string{"+", "-", "~", "!"}, token.value):
+		operand, err := p.expression(unaryPower)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &expression{operator: token.value, operands: []*expression{operand}}, nil
+	}
+
+	return nil, p.unexpected(token)
+}
+
+const unaryPower = 25
+
+// infixPower returns the binding powers of an infix operator. Operators
+// that bind to the right, like assignments, bind less on the right so the
+// next operator of the same kind takes the operand.
+func infixPower(token arithmeticToken) (int, int, bool) {
+	if token.number || token.name {
+		return 0, 0, false
+	}
+
+	switch token.value {
+	case "=", "*=", "/=", "%=", "+=", "-=", "<<=", ">>=", "&=", "^=", "|=":
+		return 2, 1, true
+	case "?":
+		return 4, 3, true
+	case "||":
+		return 5, 6, true
+	case "&&":
+		return 7, 8, true
+	case "|":
+		return 9, 10, true
+	case "^":
+		return 11, 12, true
+	case "&":
+		return 13, 14, true
+	case "==", "!=":
+		return 15, 16, true
+	case "<", "<=", ">", ">=":
+		return 17, 18, true
+	case "<<", ">>":
+		return 19, 20, true
+	case "+", "-":
+		return 21, 22, true
+	case "*", "/", "%":
+		return 23, 24, true
+	}
+
+	return 0, 0, false
+}
+
+// parseNumber parses a decimal, an octal (0 prefixed) or an hexadecimal
+// (0x prefixed) integer constant.
+func parseNumber(str string) (int64, error) {
+	digits, base := str, 10
+
+	switch {
+	case strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X"):
+		digits, base = str[2:], 16
+	case len(str) > 1 && str[0] == '0':
+		digits, base = str[1:], 8
+	}
+
+	value, err := strconv.ParseUint(digits, base, 64)
+
+	if err != nil && errors.Is(err, strconv.ErrRange) || value > math.MaxInt64 {
+		return 0, fmt.Errorf("%s: %w", str, ErrOverflow)
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid number", str)
+	}
+
+	return int64(value), nil
+}
+
+type arithmeticEvaluator struct {
+	env *env.Env
+}
+
+func (e *arithmeticEvaluator) eval(node *expression) (int64, error) {
+	switch {
+	case node.name != "":
+		return e.variable(node.name)
+	case node.operator == "":
+		return node.value, nil
+	case len(node.operands) == 1:
+		operand, err := e.eval(node.operands[0])
+
+		if err != nil {
+			return 0, err
+		}
+
+		return unary(node.operator, operand)
+	}
+
+	switch node.operator {
+	case "&&", "||", "?":
+		return e.conditional(node)
+	}
+
+	if slices.Contains(assignmentOperators, node.operator) {
+		return e.assign(node)
+	}
+
+	lhs, err := e.eval(node.operands[0])
+
+	if err != nil {
+		return 0, err
+	}
+
+	rhs, err := e.eval(node.operands[1])
+
+	if err != nil {
+		return 0, err
+	}
+
+	return binary(node.operator, lhs, rhs)
+}
+
+// conditional evaluates the operators that don't always evaluate all of
+// their operands.
+func (e *arithmeticEvaluator) conditional(node *expression) (int64, error) {
+	condition, err := e.eval(node.operands[0])
+
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case node.operator == "?" && condition != 0:
+		return e.eval(node.operands[1])
+	case node.operator == "?":
+		return e.eval(node.operands[2])
+	case node.operator == "&&" && condition == 0, node.operator == "||" && condition != 0:
+		return boolean(condition != 0), nil
+	}
+
+	rhs, err := e.eval(node.operands[1])
+
+	return boolean(rhs != 0), err
+}
+
+func (e *arithmeticEvaluator) assign(node *expression) (int64, error) {
+	name := node.operands[0].name
+	value, err := e.eval(node.operands[1])
+
+	if err != nil {
+		return 0, err
+	}
+
+	if node.operator != "=" {
+		current, err := e.variable(name)
+
+		if err != nil {
+			return 0, err
+		}
+
+		value, err = binary(strings.TrimSuffix(node.operator, "="), current, value)
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := e.env.Set(name, strconv.FormatInt(value, 10)); err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// variable returns the value of a variable as a number. Unset and null
+// variables are 0.
+func (e *arithmeticEvaluator) variable(name string) (int64, error) {
+	value, _ := e.env.Get(name)
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return 0, nil
+	}
+
+	negative := strings.HasPrefix(value, "-")
+
+	if negative || strings.HasPrefix(value, "+") {
+		value = value[1:]
+	}
+
+	number, err := parseNumber(value)
+
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if negative {
+		return -number, nil
+	}
+
+	return number, nil
+}
+
+func unary(operator string, operand int64) (int64, error) {
+	switch operator {
+	case "-":
+		if operand == math.MinInt64 {
+			return 0, ErrOverflow
+		}
+
+		return -operand, nil
+	case "~":
+		return ^operand, nil
+	case "!":
+		return boolean(operand == 0), nil
+	}
+
+	return operand, nil
+}
+
+// binary applies an operator that always evaluates both operands. Results
+// that don't fit in 64 bits are an error instead of wrapping around.
+func binary(operator string, lhs int64, rhs int64) (int64, error) {
+	switch operator {
+	case "+":
+		if result := lhs + rhs; (result > lhs) == (rhs > 0) {
+			return result, nil
+		}
+
+		return 0, ErrOverflow
+	case "-":
+		if result := lhs - rhs; (result < lhs) == (rhs > 0) {
+			return result, nil
+		}
+
+		return 0, ErrOverflow
+	case "*":
+		result := lhs * rhs
+
+		if lhs != 0 && (result/lhs != rhs || lhs == -1 && rhs == math.MinInt64) {
+			return 0, ErrOverflow
+		}
+
+		return result, nil
+	case "/", "%":
+		if rhs == 0 {
+			return 0, ErrDivisionByZero
+		}
+
+		if lhs == math.MinInt64 && rhs == -1 {
+			return 0, ErrOverflow
+		}
+
+		if operator == "/" {
+			return lhs / rhs, nil
+		}
+
+		return lhs % rhs, nil
+	case "<<", ">>":
+		if rhs < 0 || rhs > 63 {
+			return 0, fmt.Errorf("%d: shift count out of range", rhs)
+		}
+
+		if operator == ">>" {
+			return lhs >> rhs, nil
+		}
+
+		if result := lhs << rhs; result>>rhs == lhs {
+			return result, nil
+		}
+
+		return 0, ErrOverflow
+	case "&":
+		return lhs & rhs, nil
+	case "|":
+		return lhs | rhs, nil
+	case "^":
+		return lhs ^ rhs, nil
+	case "==":
+		return boolean(lhs == rhs), nil
+	case "!=":
+		return boolean(lhs != rhs), nil
+	case "<":
+		return boolean(lhs < rhs), nil
+	case "<=":
+		return boolean(lhs <= rhs), nil
+	case ">":
+		return boolean(lhs > rhs), nil
+	case ">=":
+		return boolean(lhs >= rhs), nil
+	}
+
+	return 0, fmt.Errorf("syntax error: unknown operator %q", operator)
+}
+
+func boolean(b bool) int64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
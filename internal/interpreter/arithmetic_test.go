@@ -0,0 +1,133 @@
+package interpreter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/marcos-brito/arosh/internal/env"
+)
+
+func TestParseArithmetic(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"1 + 2 * 3", "(+ [1 (* [2 3])])"},
+		{"(1 + 2) * 3", "(* [(+ [1 2]) 3])"},
+		{"1 - 2 - 3", "(- [(- [1 2]) 3])"},
+		{"a = b += 1", "(= [a (+= [b 1])])"},
+		{"a ? b : c ? d : e", "(? [a b (? [c d e])])"},
+		{"-x ** 2", ""},
+		{"!a && b || c", "(|| [(&& [(! [a]) b]) c])"},
+		{"a | b ^ c & d", "(| [a (^ [b (& [c d])])])"},
+		{"1 << 2 < 3 == 1", "(== [(< [(<< [1 2]) 3]) 1])"},
+		{"0x1f + 017 - ~0", "(- [(+ [31 15]) (~ [0])])"},
+	}
+
+	for _, tt := range tests {
+		tokens, err := arithmeticTokens(tt.source)
+
+		if err != nil {
+			t.Errorf("%s: %s", tt.source, err)
+			continue
+		}
+
+		parser := arithmeticParser{tokens: tokens}
+		expression, err := parser.expression(0)
+
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("%s: Expected a syntax error, but got %s", tt.source, expression)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", tt.source, err)
+			continue
+		}
+
+		if expression.String() != tt.expected {
+			t.Errorf("%s: Expected %s, but got %s", tt.source, tt.expected, expression)
+		}
+	}
+}
+
+func TestEvalArithmetic(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected int64
+		err      error
+	}{
+		{"1 + 2 * 3 - 4 / 2 % 3", 5, nil},
+		{"-7 / 2 + -7 % 2", -4, nil},
+		{"x", 5, nil},
+		{"unset + empty", 0, nil},
+		{"x += 2", 7, nil},
+		{"y = x *= 2", 10, nil},
+		{"(x > 4) + (x >= 6) + (x < 5) + (x <= 5) + (x == 5) + (x != 5)", 3, nil},
+		{"x > 1 ? 10 : 20", 10, nil},
+		{"0 && 1 / 0 || 2", 1, nil},
+		{"1 || 1 / 0", 1, nil},
+		{"0 ? 1 / 0 : 3", 3, nil},
+		{"~5 & 0xff ^ 1 | 8", 251, nil},
+		{"!0 + !7", 1, nil},
+		{"1 << 62 >> 61", 2, nil},
+		{"negative * 2", -6, nil},
+		{"1 / 0", 0, ErrDivisionByZero},
+		{"5 % (x - 5)", 0, ErrDivisionByZero},
+		{"9223372036854775807 + 1", 0, ErrOverflow},
+		{"-9223372036854775807 - 2", 0, ErrOverflow},
+		{"4611686018427387904 * 2", 0, ErrOverflow},
+		{"(-9223372036854775807 - 1) / -1", 0, ErrOverflow},
+		{"- (-9223372036854775807 - 1)", 0, ErrOverflow},
+		{"1 << 63", 0, ErrOverflow},
+		{"99999999999999999999", 0, ErrOverflow},
+		{"1 << 64", 0, errors.New("")},
+		{"09", 0, errors.New("")},
+		{"1 +", 0, errors.New("")},
+		{"(1", 0, errors.New("")},
+		{"1 = 2", 0, errors.New("")},
+		{"word + 1", 0, errors.New("")},
+		{"ro = 1", 0, env.ErrReadonly},
+	}
+
+	for _, tt := range tests {
+		environment := env.New()
+		environment.Set("x", "5")
+		environment.Set("empty", "")
+		environment.Set("negative", " -3 ")
+		environment.Set("word", "abc")
+		environment.Set("ro", "1")
+		environment.SetReadonly("ro")
+
+		result, err := evalArithmetic(tt.source, environment)
+
+		switch {
+		case tt.err == nil && err != nil:
+			t.Errorf("%s: Expected %d, but got %s", tt.source, tt.expected, err)
+		case tt.err == nil && result != tt.expected:
+			t.Errorf("%s: Expected %d, but got %d", tt.source, tt.expected, result)
+		case tt.err != nil && err == nil:
+			t.Errorf("%s: Expected an error, but got %d", tt.source, result)
+		case tt.err != nil && tt.err.Error() != "" && !errors.Is(err, tt.err):
+			t.Errorf("%s: Expected %s, but got %s", tt.source, tt.err, err)
+		}
+	}
+}
+
+func TestArithmeticAssignment(t *testing.T) {
+	environment := env.New()
+	environment.Set("x", "5")
+
+	if _, err := evalArithmetic("y = x <<= 2", environment); err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for name, expected := range map[string]string{"x": "20", "y": "20"} {
+		if value, _ := environment.Get(name); value != expected {
+			t.Errorf("Expected %s to be %s, but got %s", name, expected, value)
+		}
+	}
+}
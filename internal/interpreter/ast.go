@@ -9,6 +9,8 @@ type Node interface {
 
 type Program struct {
 	nodes []Node
+	// sources has the text of the pipelines and lists in the program.
+	sources map[Node]string
 }
 
 func (*Program) Node() {}
@@ -22,19 +24,15 @@ func (s *Program) String() string {
 	return str
 }
 
+// Subshell runs its body in a copy of the shell, so nothing it changes is
+// seen after it's done.
 type Subshell struct {
-	nodes []Node
+	body Node
 }
 
 func (*Subshell) Node() {}
 func (s *Subshell) String() string {
-	str := ""
-
-	for _, node := range s.nodes {
-		str += node.String()
-	}
-
-	return str
+	return fmt.Sprintf("(subshell %s)", s.body)
 }
 
 type Sequence struct {
@@ -69,24 +67,325 @@ func (p *Pipe) String() string {
 	return fmt.Sprintf("(pipe %s %s)", p.lhs, p.rhs)
 }
 
+// Not runs a pipeline and inverts its status, 0 becomes 1 and anything
+// else 0.
+type Not struct {
+	pipeline Node
+}
+
+func (*Not) Node() {}
+func (n *Not) String() string {
+	return fmt.Sprintf("(not %s)", n.pipeline)
+}
+
+// SimpleCommand runs name with params as arguments. The assignments only
+// apply to the command, unless there's no name, then they're made in the
+// shell itself.
 type SimpleCommand struct {
-	name        string
-	params      []string
-	redirection Node
+	assignments  []*Assignment
+	name         *Word
+	params       []*Word
+	redirections []*Redirection
 }
 
 func (*SimpleCommand) Node() {}
 func (s *SimpleCommand) String() string {
-	return fmt.Sprintf("(simpleCommand %s %v %s)", s.name, s.params, s.redirection)
+	return fmt.Sprintf(
+		"(simpleCommand %v %s %v %v)",
+		s.assignments,
+		s.name,
+		s.params,
+		s.redirections,
+	)
+}
+
+// Assignment is a word like "NAME=value" at the start of a simple command.
+type Assignment struct {
+	name  string
+	value *Word
+}
+
+func (*Assignment) Node() {}
+func (a *Assignment) String() string {
+	return fmt.Sprintf("(assignment %s %s)", a.name, a.value)
+}
+
+// RedirectedCommand holds a compound command and the redirections
+// that follow it. They apply to the command as a whole.
+type RedirectedCommand struct {
+	command      Node
+	redirections []*Redirection
+}
+
+func (*RedirectedCommand) Node() {}
+func (r *RedirectedCommand) String() string {
+	return fmt.Sprintf("(redirectedCommand %s %v)", r.command, r.redirections)
+}
+
+// BraceGroup runs its body in the current shell, it only groups commands
+// together.
+type BraceGroup struct {
+	body Node
+}
+
+func (*BraceGroup) Node() {}
+func (b *BraceGroup) String() string {
+	return fmt.Sprintf("(braceGroup %s)", b.body)
+}
+
+// FunctionDef defines a function. body is a compound command, with the
+// redirections that were written after it.
+type FunctionDef struct {
+	name string
+	body Node
+}
+
+func (*FunctionDef) Node() {}
+func (f *FunctionDef) String() string {
+	return fmt.Sprintf("(function %s %s)", f.name, f.body)
+}
+
+// IfClause runs consequent if condition succeeds and alternative
+// otherwise. An elif is an IfClause as the alternative.
+type IfClause struct {
+	condition   Node
+	consequent  Node
+	alternative Node
+}
+
+func (*IfClause) Node() {}
+func (i *IfClause) String() string {
+	return fmt.Sprintf("(if %s %s %s)", i.condition, i.consequent, i.alternative)
+}
+
+// WhileClause runs body while condition succeeds. An until loop is a
+// WhileClause that runs while condition fails.
+type WhileClause struct {
+	condition Node
+	body      Node
+	until     bool
+}
+
+func (*WhileClause) Node() {}
+func (w *WhileClause) String() string {
+	if w.until {
+		return fmt.Sprintf("(until %s %s)", w.condition, w.body)
+	}
+
+	return fmt.Sprintf("(while %s %s)", w.condition, w.body)
+}
+
+// ForClause runs body once for each field of words. When words is nil,
+// there was no "in" and the positional parameters are used instead.
+type ForClause struct {
+	name  string
+	words []*Word
+	body  Node
+}
+
+func (*ForClause) Node() {}
+func (f *ForClause) String() string {
+	return fmt.Sprintf("(for %s %v %s)", f.name, f.words, f.body)
+}
+
+type CaseClause struct {
+	word  *Word
+	items []*CaseItem
+}
+
+func (*CaseClause) Node() {}
+func (c *CaseClause) String() string {
+	return fmt.Sprintf("(case %s %v)", c.word, c.items)
+}
+
+// CaseItem is a list of patterns and the commands to run if any of them
+// matches. body is nil if there are none.
+type CaseItem struct {
+	patterns []*Word
+	body     Node
+}
+
+func (*CaseItem) Node() {}
+func (c *CaseItem) String() string {
+	return fmt.Sprintf("(caseItem %v %s)", c.patterns, c.body)
 }
 
 type Redirection struct {
 	ioNumber        int
 	redirectionType string
-	file            string
+	file            *Word
+	hereDoc         *HereDoc
 }
 
 func (*Redirection) Node() {}
 func (r *Redirection) String() string {
+	if r.hereDoc != nil {
+		return fmt.Sprintf("(redirection %d %s %s)", r.ioNumber, r.redirectionType, r.hereDoc)
+	}
+
 	return fmt.Sprintf("(redirection %d %s %s)", r.ioNumber, r.redirectionType, r.file)
 }
+
+// HereDoc is the body of a "<<" or "<<-" redirection. It's filled by the
+// lexer once it reaches the end of the line where the redirection is.
+type HereDoc struct {
+	delimiter string
+	quoted    bool
+	stripTabs bool
+	body      string
+	// parts is the body broken down for expansion. It's only filled for
+	// unquoted here-documents.
+	parts []WordPart
+}
+
+func (*HereDoc) Node() {}
+func (h *HereDoc) String() string {
+	return fmt.Sprintf("(hereDoc %s %t %q)", h.delimiter, h.quoted, h.body)
+}
+
+// Word is a WORD token broken down by how each part of it was quoted. Quote
+// removal and expansions work on the parts.
+type Word struct {
+	parts []WordPart
+}
+
+func (*Word) Node() {}
+func (w *Word) String() string {
+	str := ""
+
+	for _, part := range w.parts {
+		str += part.String()
+	}
+
+	return str
+}
+
+type WordPart interface {
+	Node
+	wordPart()
+}
+
+// Literal is an unquoted part of a word.
+type Literal struct {
+	value string
+}
+
+func (*Literal) Node()     {}
+func (*Literal) wordPart() {}
+func (l *Literal) String() string {
+	return l.value
+}
+
+type SingleQuoted struct {
+	value string
+}
+
+func (*SingleQuoted) Node()     {}
+func (*SingleQuoted) wordPart() {}
+func (s *SingleQuoted) String() string {
+	return "'" + s.value + "'"
+}
+
+type DoubleQuoted struct {
+	parts []WordPart
+}
+
+func (*DoubleQuoted) Node()     {}
+func (*DoubleQuoted) wordPart() {}
+func (d *DoubleQuoted) String() string {
+	str := ""
+
+	for _, part := range d.parts {
+		str += part.String()
+	}
+
+	return "\"" + str + "\""
+}
+
+// Parameter is a parameter expansion: $name, ${name} or ${name op word}.
+// length is set for ${#name}. A parameter that couldn't be read has no
+// name, it's a bad substitution. index is the subscript of ${name[index]},
+// a number, "@" or "*".
+type Parameter struct {
+	name     string
+	braced   bool
+	length   bool
+	index    string
+	operator string
+	word     *Word
+}
+
+func (*Parameter) Node()     {}
+func (*Parameter) wordPart() {}
+func (p *Parameter) String() string {
+	if !p.braced {
+		return "$" + p.name
+	}
+
+	str := "${"
+
+	if p.length {
+		str += "#"
+	}
+
+	str += p.name
+
+	if p.index != "" {
+		str += "[" + p.index + "]"
+	}
+
+	str += p.operator
+
+	if p.word != nil {
+		str += p.word.String()
+	}
+
+	return str + "}"
+}
+
+// CommandSubstitution is $(program) or `program`, it expands to what the
+// program writes. source is the text between the delimiters.
+type CommandSubstitution struct {
+	program    *Program
+	source     string
+	backquoted bool
+}
+
+func (*CommandSubstitution) Node()     {}
+func (*CommandSubstitution) wordPart() {}
+func (c *CommandSubstitution) String() string {
+	if c.backquoted {
+		return "`" + c.source + "`"
+	}
+
+	return "$(" + c.source + ")"
+}
+
+// Arithmetic is an arithmetic expansion, $((expression)). The expression
+// is expanded like the inside of double quotes before it's evaluated.
+type Arithmetic struct {
+	parts []WordPart
+}
+
+func (*Arithmetic) Node()     {}
+func (*Arithmetic) wordPart() {}
+func (a *Arithmetic) String() string {
+	str := ""
+
+	for _, part := range a.parts {
+		str += part.String()
+	}
+
+	return "$((" + str + "))"
+}
+
+// Escaped is a character preceded by a backslash.
+type Escaped struct {
+	value string
+}
+
+func (*Escaped) Node()     {}
+func (*Escaped) wordPart() {}
+func (e *Escaped) String() string {
+	return "\\" + e.value
+}
@@ -0,0 +1,223 @@
+package interpreter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// braceItem is an unquoted character of a word, or any other part of it,
+// which brace expansion carries along untouched.
+type braceItem struct {
+	char rune
+	part WordPart
+}
+
+// expandBraces does brace expansion, a bash extension that comes before
+// every other expansion. "a{b,c}d" is "abd" and "acd", "{1..3}" is "1", "2"
+// and "3". Only unquoted braces and commas count. The result is the parts
+// of each word it makes.
+func expandBraces(parts []WordPart) [][]WordPart {
+	items := []braceItem{}
+
+	for _, part := range parts {
+		literal, ok := part.(*Literal)
+
+		if !ok {
+			items = append(items, braceItem{part: part})
+			continue
+		}
+
+		for _, char := range literal.value {
+			items = append(items, braceItem{char: char})
+		}
+	}
+
+	words := [][]WordPart{}
+
+	for _, expanded := range braceItems(items) {
+		words = append(words, braceParts(expanded))
+	}
+
+	return words
+}
+
+// braceItems expands the first brace expression found and then whatever is
+// left in each word it made. Braces that don't make an expression, like
+// "{a}" or a "{" that is never closed, are kept as they are.
+func braceItems(items []braceItem) [][]braceItem {
+	for open := range items {
+		if items[open].part != nil || items[open].char != '{' {
+			continue
+		}
+
+		end, alternatives := braceAlternatives(items, open)
+
+		if end == -1 || alternatives == nil {
+			continue
+		}
+
+		words := [][]braceItem{}
+
+		for _, alternative := range alternatives {
+			word := append([]braceItem{}, items[:open]...)
+			word = append(word, alternative...)
+			word = append(word, items[end+1:]...)
+			words = append(words, braceItems(word)...)
+		}
+
+		return words
+	}
+
+	return [][]braceItem{items}
+}
+
+// braceAlternatives finds the brace closing the one at open and what's
+// between them: the text between commas, or the words of a sequence. The
+// alternatives are nil when the braces don't make an expression, the end is
+// -1 when they are never closed.
+func braceAlternatives(items []braceItem, open int) (int, [][]braceItem) {
+	depth := 0
+	alternatives := [][]braceItem{}
+	start := open + 1
+
+	for n := open + 1; n < len(items); n++ {
+		if items[n].part != nil {
+			continue
+		}
+
+		switch {
+		case items[n].char == '{':
+			depth++
+		case items[n].char == '}' && depth > 0:
+			depth--
+		case items[n].char == ',' && depth == 0:
+			alternatives = append(alternatives, items[start:n])
+			start = n + 1
+		case items[n].char == '}':
+			if len(alternatives) > 0 {
+				return n, append(alternatives, items[start:n])
+			}
+
+			return n, braceSequence(items[open+1 : n])
+		}
+	}
+
+	return -1, nil
+}
+
+// braceSequence expands a sequence like "1..10", "a..z" or "1..10..2". Numbers
+// with leading zeros make every number as wide. Anything else isn't a
+// sequence.
+func braceSequence(items []braceItem) [][]braceItem {
+	text := ""
+
+	for _, item := range items {
+		if item.part != nil {
+			return nil
+		}
+
+		text += string(item.char)
+	}
+
+	bounds := strings.Split(text, "..")
+	step := 1
+
+	if len(bounds) == 3 {
+		n, err := strconv.Atoi(bounds[2])
+
+		if err != nil {
+			return nil
+		}
+
+		step = max(n, -n, 1)
+	} else if len(bounds) != 2 {
+		return nil
+	}
+
+	words := []string{}
+	start, startErr := strconv.Atoi(bounds[0])
+	end, endErr := strconv.Atoi(bounds[1])
+
+	switch {
+	case startErr == nil && endErr == nil:
+		width := 0
+
+		for _, bound := range bounds[:2] {
+			if digits := strings.TrimPrefix(bound, "-"); len(digits) > 1 && digits[0] == '0' {
+				width = max(width, len(bound))
+			}
+		}
+
+		for _, n := range sequence(start, end, step) {
+			words = append(words, fmt.Sprintf("%0*d", width, n))
+		}
+	case isBraceLetter(bounds[0]) && isBraceLetter(bounds[1]):
+		for _, n := range sequence(int(bounds[0][0]), int(bounds[1][0]), step) {
+			words = append(words, string(rune(n)))
+		}
+	default:
+		return nil
+	}
+
+	alternatives := [][]braceItem{}
+
+	for _, word := range words {
+		alternative := []braceItem{}
+
+		for _, char := range word {
+			alternative = append(alternative, braceItem{char: char})
+		}
+
+		alternatives = append(alternatives, alternative)
+	}
+
+	return alternatives
+}
+
+// sequence counts from start to end, going down if end is smaller.
+func sequence(start, end, step int) []int {
+	numbers := []int{}
+
+	if start <= end {
+		for n := start; n <= end; n += step {
+			numbers = append(numbers, n)
+		}
+	} else {
+		for n := start; n >= end; n -= step {
+			numbers = append(numbers, n)
+		}
+	}
+
+	return numbers
+}
+
+func isBraceLetter(str string) bool {
+	return len(str) == 1 && ('a' <= str[0] && str[0] <= 'z' || 'A' <= str[0] && str[0] <= 'Z')
+}
+
+// braceParts joins the characters back into literals.
+func braceParts(items []braceItem) []WordPart {
+	parts := []WordPart{}
+	literal := ""
+
+	for _, item := range items {
+		if item.part == nil {
+			literal += string(item.char)
+			continue
+		}
+
+		if literal != "" {
+			parts = append(parts, &Literal{value: literal})
+			literal = ""
+		}
+
+		parts = append(parts, item.part)
+	}
+
+	if literal != "" {
+		parts = append(parts, &Literal{value: literal})
+	}
+
+	return parts
+}
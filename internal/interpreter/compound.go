@@ -0,0 +1,155 @@
+package interpreter
+
+// An if without any branch taken, like a loop that never runs, has a zero
+// exit status.
+func (i *Interpreter) evalIf(clause *IfClause) error {
+	if err := i.evalCondition(clause.condition); err != nil {
+		return err
+	}
+
+	if i.status == 0 {
+		return i.eval(clause.consequent)
+	}
+
+	if clause.alternative != nil {
+		return i.eval(clause.alternative)
+	}
+
+	i.status = 0
+
+	return nil
+}
+
+func (i *Interpreter) evalWhile(clause *WhileClause) error {
+	i.loops++
+	defer func() { i.loops-- }()
+
+	status := 0
+
+	for {
+		if stop, err := loopControl(i.evalCondition(clause.condition)); stop {
+			return err
+		}
+
+		if (i.status == 0) == clause.until {
+			break
+		}
+
+		stop, err := loopControl(i.eval(clause.body))
+		status = i.status
+
+		if stop {
+			return err
+		}
+	}
+
+	i.status = status
+
+	return nil
+}
+
+func (i *Interpreter) evalFor(clause *ForClause) error {
+	fields := i.positional
+
+	if clause.words != nil {
+		expanded, err := i.expandWords(clause.words)
+
+		if err != nil {
+			return i.commandError(err)
+		}
+
+		fields = expanded
+	}
+
+	i.loops++
+	defer func() { i.loops-- }()
+
+	i.status = 0
+
+	for _, field := range fields {
+		if err := i.env.Set(clause.name, field); err != nil {
+			i.errorf("%s", err)
+			i.status = 1
+			return nil
+		}
+
+		if stop, err := loopControl(i.eval(clause.body)); stop {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// evalCase runs the first item with a pattern matching the word. Patterns
+// are tried in order, and only until one matches.
+func (i *Interpreter) evalCase(clause *CaseClause) error {
+	word, err := i.expandWord(clause.word)
+
+	if err != nil {
+		return i.commandError(err)
+	}
+
+	for _, item := range clause.items {
+		for _, pattern := range item.patterns {
+			pattern, err := i.expandPattern(pattern)
+
+			if err != nil {
+				return i.commandError(err)
+			}
+
+			if !matchPattern(pattern, word) {
+				continue
+			}
+
+			if item.body == nil {
+				i.status = 0
+				return nil
+			}
+
+			return i.eval(item.body)
+		}
+	}
+
+	i.status = 0
+
+	return nil
+}
+
+// call runs a function with args as its positional parameters. The call
+// gets a scope for its local variables. Loops around the call can't be
+// left from inside the function.
+func (i *Interpreter) call(function *FunctionDef, args []string) error {
+	positional, loops := i.positional, i.loops
+	i.positional, i.loops = args[1:], 0
+	i.calls++
+	i.env.Push()
+
+	defer func() {
+		i.positional, i.loops = positional, loops
+		i.calls--
+		i.env.Pop()
+	}()
+
+	err := i.eval(function.body)
+
+	if ret, ok := err.(*returnError); ok {
+		i.status = ret.status
+		err = nil
+	}
+
+	if err == nil {
+		err = i.trap("RETURN")
+	}
+
+	return err
+}
+
+// evalCondition evaluates the condition of an if or a loop, or the left
+// hand side of && and ||, whose failing doesn't count as an error.
+func (i *Interpreter) evalCondition(node Node) error {
+	i.conditions++
+	defer func() { i.conditions-- }()
+
+	return i.eval(node)
+}
@@ -0,0 +1,149 @@
+package interpreter
+
+import (
+	"errors"
+	"strconv"
+)
+
+// errExit unwinds evaluation once the exit builtin runs, all the way up to
+// Run or to the subshell it was run in.
+var errExit = errors.New("exit")
+
+// breakError and continueError unwind evaluation up to the loop they
+// apply to. levels is how many enclosing loops are left to go through.
+type breakError struct {
+	levels int
+}
+
+func (*breakError) Error() string {
+	return "break"
+}
+
+type continueError struct {
+	levels int
+}
+
+func (*continueError) Error() string {
+	return "continue"
+}
+
+// returnError unwinds evaluation up to the function call it returns from.
+type returnError struct {
+	status int
+}
+
+func (*returnError) Error() string {
+	return "return"
+}
+
+// controlBuiltins are the builtins that change the flow of execution. They
+// live in the interpreter since they need to unwind it.
+var controlBuiltins = map[string]func(*Interpreter, []string) error{
+	"break":    (*Interpreter).breakBuiltin,
+	"continue": (*Interpreter).continueBuiltin,
+	"return":   (*Interpreter).returnBuiltin,
+}
+
+// The . builtin runs commands, which looks control builtins up, so it can't
+// be in the literal.
+func init() {
+	controlBuiltins["."] = (*Interpreter).dotBuiltin
+}
+
+func (i *Interpreter) breakBuiltin(args []string) error {
+	levels, ok := i.loopLevels(args)
+
+	if !ok {
+		return nil
+	}
+
+	return &breakError{levels: levels}
+}
+
+func (i *Interpreter) continueBuiltin(args []string) error {
+	levels, ok := i.loopLevels(args)
+
+	if !ok {
+		return nil
+	}
+
+	return &continueError{levels: levels}
+}
+
+// returnBuiltin leaves the current function, or the file the . builtin is
+// running. The status of the call is the one given, or the status of the
+// last command if there's none.
+func (i *Interpreter) returnBuiltin(args []string) error {
+	if i.calls == 0 && i.sourcing == 0 {
+		i.errorf("return: can only return from a function or sourced script")
+		i.status = 1
+		return nil
+	}
+
+	status := i.status
+
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+
+		if err != nil {
+			i.errorf("return: %s: numeric argument required", args[1])
+			n = 2
+		}
+
+		status = n & 0xff
+	}
+
+	return &returnError{status: status}
+}
+
+// loopLevels reads how many loops break or continue apply to. Asking for
+// more loops than there are means all of them.
+func (i *Interpreter) loopLevels(args []string) (int, bool) {
+	if i.loops == 0 {
+		i.errorf("%s: only meaningful in a loop", args[0])
+		i.status = 0
+		return 0, false
+	}
+
+	levels := 1
+
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+
+		if err != nil || n < 1 {
+			i.errorf("%s: %s: loop count out of range", args[0], args[1])
+			i.status = 1
+			return 0, false
+		}
+
+		levels = n
+	}
+
+	i.status = 0
+
+	return min(levels, i.loops), true
+}
+
+// loopControl handles what comes out of evaluating part of a loop. It tells
+// if the loop has to stop, and returns what still has to unwind the
+// loops around it.
+func loopControl(err error) (bool, error) {
+	switch err := err.(type) {
+	case nil:
+		return false, nil
+	case *breakError:
+		if err.levels > 1 {
+			return true, &breakError{levels: err.levels - 1}
+		}
+
+		return true, nil
+	case *continueError:
+		if err.levels > 1 {
+			return true, &continueError{levels: err.levels - 1}
+		}
+
+		return false, nil
+	}
+
+	return true, err
+}
@@ -0,0 +1,151 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is a syntax error. It points at the token that was found where
+// one of Expected should be.
+type ParseError struct {
+	Span     Span
+	Expected []tokenType
+	Found    Token
+	source   string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf(
+		"%d:%d: unexpected %s, expected %s",
+		e.Span.Start.Line+1,
+		e.Span.Start.Column+1,
+		describeToken(e.Found),
+		describeExpected(e.Expected),
+	)
+}
+
+// Diagnostic renders the error with the line where it happened and the
+// offending token underlined:
+//
+//	unexpected "|", expected word
+//	 --> 1:7
+//	  |
+//	1 | cat & | grep
+//	  |       ^
+func (e *ParseError) Diagnostic() string {
+	start := e.Span.Start
+	line := strings.Split(e.source, "\n")[start.Line]
+	number := fmt.Sprint(start.Line + 1)
+	gutter := strings.Repeat(" ", len(number))
+
+	// Tabs are kept, so the caret lines up with the source however they're
+	// shown.
+	padding := []rune(line)[:min(start.Column, utf8.RuneCountInString(line))]
+	for n, char := range padding {
+		if char != '\t' {
+			padding[n] = ' '
+		}
+	}
+
+	width := e.Span.End.Rune - e.Span.Start.Rune
+
+	if e.Span.End.Line != start.Line {
+		width = utf8.RuneCountInString(line) - start.Column
+	}
+
+	return fmt.Sprintf(
+		"unexpected %s, expected %s\n%s--> %d:%d\n%s |\n%s | %s\n%s | %s%s",
+		describeToken(e.Found),
+		describeExpected(e.Expected),
+		gutter,
+		start.Line+1,
+		start.Column+1,
+		gutter,
+		number,
+		line,
+		gutter,
+		string(padding),
+		strings.Repeat("^", max(width, 1)),
+	)
+}
+
+func describeToken(token Token) string {
+	switch token.T {
+	case EOF:
+		return "end of input"
+	case NEWLINE:
+		return "newline"
+	case WORD:
+		return fmt.Sprintf("word %q", token.Lexeme)
+	}
+
+	return fmt.Sprintf("%q", token.Lexeme)
+}
+
+func describeExpected(expected []tokenType) string {
+	descriptions := []string{}
+
+	for _, t := range expected {
+		switch t {
+		case WORD, NEWLINE, COMMAND, NAME, PATTERN:
+			descriptions = append(descriptions, strings.ToLower(t))
+		default:
+			// Reserved words are written in lower case.
+			descriptions = append(descriptions, fmt.Sprintf("%q", strings.ToLower(t)))
+		}
+	}
+
+	return strings.Join(descriptions, " or ")
+}
+
+// ParseErrors holds every syntax error found in a source.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	messages := []string{}
+
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e ParseErrors) Unwrap() []error {
+	errs := []error{}
+
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// Is makes ParseErrors match ErrIncomplete when every error is about the
+// input ending too early, like an if without its fi. More input could fix
+// them.
+func (e ParseErrors) Is(target error) bool {
+	if target != ErrIncomplete || len(e) == 0 {
+		return false
+	}
+
+	for _, err := range e {
+		if err.Found.T != EOF {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Diagnostic renders every error, one after the other.
+func (e ParseErrors) Diagnostic() string {
+	diagnostics := []string{}
+
+	for _, err := range e {
+		diagnostics = append(diagnostics, err.Diagnostic())
+	}
+
+	return strings.Join(diagnostics, "\n\n")
+}
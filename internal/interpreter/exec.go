@@ -0,0 +1,102 @@
+package interpreter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+var errNotFound = errors.New("command not found")
+
+// execute starts an external program and waits for it. The returned value
+// is the exit status as seen by the shell: 127 for commands that can't be
+// found, 126 for the ones that can't be executed and 128+n for commands
+// killed by signal n.
+func (i *Interpreter) execute(args []string) int {
+	path, _ := i.env.Get("PATH")
+	path, err := lookPath(args[0], path, i.dir)
+
+	if err != nil {
+		i.errorf("%s: %s", args[0], err)
+		return 127
+	}
+
+	// Programs of a job are started by the table it belongs to, which may
+	// be the one of the shell the job was started from.
+	table := i.jobs
+
+	if i.job != nil {
+		table = i.job.table
+	}
+
+	process, err := table.start(i.job, path, args, &os.ProcAttr{
+		Dir:   i.dir,
+		Env:   i.env.Environ(),
+		Files: i.files(),
+	})
+
+	if err != nil {
+		i.errorf("%s: %s", args[0], unwrapPathError(err))
+		return 126
+	}
+
+	return table.wait(process)
+}
+
+// lookPath searches for an executable named file in the directories listed
+// in path. Names containing a slash are used as they are. Relative paths are
+// resolved against cwd.
+func lookPath(file string, path string, cwd string) (string, error) {
+	if strings.Contains(file, "/") {
+		file = resolve(cwd, file)
+		return file, isExecutable(file)
+	}
+
+	for _, dir := range filepath.SplitList(path) {
+		candidate := resolve(cwd, filepath.Join(dir, file))
+
+		if isExecutable(candidate) == nil {
+			return candidate, nil
+		}
+	}
+
+	return "", errNotFound
+}
+
+func resolve(cwd string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(cwd, path)
+}
+
+func isExecutable(file string) error {
+	info, err := os.Stat(file)
+
+	if err != nil {
+		return unwrapPathError(err)
+	}
+
+	if info.IsDir() {
+		return syscall.EISDIR
+	}
+
+	if info.Mode()&0111 == 0 {
+		return os.ErrPermission
+	}
+
+	return nil
+}
+
+func unwrapPathError(err error) error {
+	var pathError *os.PathError
+
+	if errors.As(err, &pathError) {
+		return pathError.Err
+	}
+
+	return err
+}
@@ -0,0 +1,674 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// errUnset is the error of expanding an unset parameter with nounset on.
+var errUnset = errors.New("unbound variable")
+
+// errParameter is the error of ${name?word} on an unset parameter, or of
+// ${name:?word} on a null one.
+var errParameter = errors.New("parameter null or not set")
+
+// errArithmetic is the error of an arithmetic expansion that can't be
+// evaluated, like one dividing by zero.
+var errArithmetic = errors.New("arithmetic error")
+
+// segment is a piece of an expanded word. Quoted segments come from quoted
+// text, so their characters have no special meaning. Split segments are the
+// result of unquoted expansions, which are split into fields at the
+// characters of IFS.
+type segment struct {
+	value  string
+	quoted bool
+	split  bool
+}
+
+// field is one of the words an expanded word results in, made of the
+// segments it was built from.
+type field []segment
+
+func (f field) String() string {
+	str := ""
+
+	for _, segment := range f {
+		str += segment.value
+	}
+
+	return str
+}
+
+// pattern returns the field as a pattern, with quoted characters escaped
+// so they only match themselves.
+func (f field) pattern() string {
+	str := ""
+
+	for _, segment := range f {
+		if segment.quoted {
+			str += escapePattern(segment.value)
+		} else {
+			str += segment.value
+		}
+	}
+
+	return str
+}
+
+func (f field) isQuoted() bool {
+	for _, segment := range f {
+		if segment.quoted {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeQuotes joins the parts of a word, leaving behind the quotes and
+// backslashes used to write them. Expansions are kept as they were written.
+func removeQuotes(parts []WordPart) string {
+	str := ""
+
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *Literal:
+			str += part.value
+		case *SingleQuoted:
+			str += part.value
+		case *Escaped:
+			str += part.value
+		case *DoubleQuoted:
+			str += removeQuotes(part.parts)
+		default:
+			str += part.String()
+		}
+	}
+
+	return str
+}
+
+// isQuoted tells if any part of a word is quoted or escaped.
+func isQuoted(parts []WordPart) bool {
+	for _, part := range parts {
+		switch part.(type) {
+		case *SingleQuoted, *DoubleQuoted, *Escaped:
+			return true
+		}
+	}
+
+	return false
+}
+
+// expandWords turns the words of a command into the fields used as
+// arguments. Each word goes through the expansions in the order POSIX
+// defines:
+//
+//  1. Brace expansion, unless the shell sticks to POSIX.
+//  2. Tilde expansion, parameter expansion, command substitution and
+//     arithmetic expansion.
+//  3. Field splitting of what the unquoted expansions resulted in.
+//  4. Pathname expansion of the fields with unquoted pattern characters.
+//  5. Quote removal.
+//
+// Words that expand to nothing are removed, unless something in them was
+// quoted. Empty fields left by splitting are kept.
+func (i *Interpreter) expandWords(words []*Word) ([]string, error) {
+	args := []string{}
+	ifs := i.ifs()
+
+	for _, word := range words {
+		braced := [][]WordPart{word.parts}
+
+		if !i.Options.Posix {
+			braced = expandBraces(word.parts)
+		}
+
+		for _, parts := range braced {
+			expanded, err := i.expandParts(parts, false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, expanded := range expanded {
+				if expanded.String() == "" && !expanded.isQuoted() {
+					continue
+				}
+
+				for _, field := range splitField(expanded, ifs) {
+					args = append(args, i.expandPathname(field)...)
+				}
+			}
+		}
+	}
+
+	return args, nil
+}
+
+// ifs returns the characters fields are split at. Unset, IFS is a space, a
+// tab and a newline.
+func (i *Interpreter) ifs() string {
+	if ifs, ok := i.env.Get("IFS"); ok {
+		return ifs
+	}
+
+	return " \t\n"
+}
+
+// splitField splits a field at the characters of IFS in its split segments.
+// Whitespace in IFS is ignored at the edges and counts once however much of
+// it there is. Any other character of IFS ends a field on its own, even an
+// empty one, along with the whitespace around it.
+func splitField(f field, ifs string) []field {
+	fields := []field{}
+	current := field{}
+	// started tells if something, even empty quotes, was added to current.
+	// blank tells if the last field was ended by whitespace.
+	started, blank := false, false
+
+	for _, part := range f {
+		if !part.split || ifs == "" {
+			current = append(current, part)
+			started, blank = true, false
+			continue
+		}
+
+		value := ""
+
+		for _, char := range part.value {
+			if !strings.ContainsRune(ifs, char) {
+				value += string(char)
+				started, blank = true, false
+				continue
+			}
+
+			whitespace := strings.ContainsRune(" \t\n", char)
+
+			if !started && (whitespace || blank) {
+				blank = blank && whitespace
+				continue
+			}
+
+			if value != "" {
+				current = append(current, segment{value: value, split: true})
+			}
+
+			fields = append(fields, current)
+			current, value, started, blank = field{}, "", false, whitespace
+		}
+
+		if value != "" {
+			current = append(current, segment{value: value, split: true})
+		}
+	}
+
+	if started {
+		fields = append(fields, current)
+	}
+
+	return fields
+}
+
+// expandWord expands a word that must result in a single field, like the
+// target of a redirection.
+func (i *Interpreter) expandWord(word *Word) (string, error) {
+	fields, err := i.expandParts(word.parts, false)
+
+	if err != nil {
+		return "", err
+	}
+
+	return joinFields(fields, " ", field.String), nil
+}
+
+// expandPattern expands a word used as a pattern. Quoted characters lose
+// their special meaning, so they are escaped with a backslash.
+func (i *Interpreter) expandPattern(word *Word) (string, error) {
+	fields, err := i.expandParts(word.parts, false)
+
+	if err != nil {
+		return "", err
+	}
+
+	return joinFields(fields, " ", field.pattern), nil
+}
+
+// expandParts expands the parts of a word into fields. quoted tells if
+// they are inside double quotes.
+func (i *Interpreter) expandParts(parts []WordPart, quoted bool) ([]field, error) {
+	fields := []field{}
+
+	if !quoted {
+		parts = i.expandTilde(parts, false)
+	}
+
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *Literal:
+			fields = appendFields(fields, []field{{{value: part.value, quoted: quoted}}})
+		case *SingleQuoted:
+			fields = appendFields(fields, []field{{{value: part.value, quoted: true}}})
+		case *Escaped:
+			fields = appendFields(fields, []field{{{value: part.value, quoted: true}}})
+		case *DoubleQuoted:
+			expanded, err := i.expandParts(part.parts, true)
+
+			if err != nil {
+				return nil, err
+			}
+
+			// Empty quotes still make a field. "$@" without positional
+			// parameters is the only thing inside quotes that makes none.
+			if len(part.parts) == 0 || len(expanded) > 0 {
+				expanded = appendFields([]field{{{quoted: true}}}, expanded)
+			}
+
+			fields = appendFields(fields, expanded)
+		case *Parameter:
+			expanded, err := i.expandParameter(part, quoted)
+
+			if err != nil {
+				return nil, err
+			}
+
+			fields = appendFields(fields, splittable(expanded))
+		case *CommandSubstitution:
+			output, err := i.substitute(part)
+
+			if err != nil {
+				return nil, err
+			}
+
+			expanded := []field{{{value: output, quoted: quoted}}}
+			fields = appendFields(fields, splittable(expanded))
+		case *Arithmetic:
+			value, err := i.expandArithmetic(part)
+
+			if err != nil {
+				return nil, err
+			}
+
+			expanded := []field{{{value: value, quoted: quoted}}}
+			fields = appendFields(fields, splittable(expanded))
+		}
+	}
+
+	return fields, nil
+}
+
+// splittable marks the unquoted segments of fields as split, since they
+// are the result of an expansion.
+func splittable(fields []field) []field {
+	for _, field := range fields {
+		for n := range field {
+			field[n].split = !field[n].quoted
+		}
+	}
+
+	return fields
+}
+
+// substitute runs the program of a command substitution in a subshell and
+// returns what it wrote, without the trailing newlines.
+func (i *Interpreter) substitute(substitution *CommandSubstitution) (string, error) {
+	reader, writer, err := os.Pipe()
+
+	if err != nil {
+		return "", err
+	}
+
+	output := make(chan []byte)
+
+	go func() {
+		bytes, _ := io.ReadAll(reader)
+		reader.Close()
+		output <- bytes
+	}()
+
+	shell := i.subshell()
+	shell.Stdout = writer
+	shell.Run(substitution.program)
+	shell.RunExitTrap()
+	writer.Close()
+
+	i.status = shell.status
+	i.substituted = true
+
+	return strings.TrimRight(string(<-output), "\n"), nil
+}
+
+// expandArithmetic expands the expression of an arithmetic expansion like
+// the inside of double quotes and evaluates it.
+func (i *Interpreter) expandArithmetic(arithmetic *Arithmetic) (string, error) {
+	fields, err := i.expandParts(arithmetic.parts, true)
+
+	if err != nil {
+		return "", err
+	}
+
+	expression := joinFields(fields, " ", field.String)
+	value, err := evalArithmetic(expression, i.env)
+
+	if err != nil {
+		message, expression := err.Error(), strings.TrimSpace(expression)
+
+		// Errors about a number or variable already start with it, which
+		// may be the whole expression.
+		if !strings.HasPrefix(message, expression+": ") {
+			message = expression + ": " + message
+		}
+
+		return "", &messageError{message: message, err: errArithmetic}
+	}
+
+	return strconv.FormatInt(value, 10), nil
+}
+
+// appendFields joins the first field of next to the last one of fields, the
+// others are added after it.
+func appendFields(fields []field, next []field) []field {
+	if len(fields) == 0 || len(next) == 0 {
+		return append(fields, next...)
+	}
+
+	last := len(fields) - 1
+	fields[last] = append(fields[last], next[0]...)
+
+	return append(fields, next[1:]...)
+}
+
+func joinFields(fields []field, separator string, join func(field) string) string {
+	strs := []string{}
+
+	for _, field := range fields {
+		strs = append(strs, join(field))
+	}
+
+	return strings.Join(strs, separator)
+}
+
+func (i *Interpreter) expandParameter(parameter *Parameter, quoted bool) ([]field, error) {
+	if parameter.name == "" {
+		return nil, fmt.Errorf("%s: bad substitution", parameter)
+	}
+
+	value, set := i.parameter(parameter.name)
+	all := parameter.name == "@" || parameter.name == "*"
+	list, joined := i.positional, parameter.name == "*"
+	operator := strings.TrimPrefix(parameter.operator, ":")
+
+	if parameter.index != "" {
+		list = i.array(parameter.name)
+		all, joined = parameter.index == "@" || parameter.index == "*", parameter.index == "*"
+		value, set = element(list, parameter.index)
+	}
+
+	// Operators that test if the parameter is set are fine with nounset.
+	tested := slices.Contains([]string{"-", "=", "?", "+"}, operator)
+
+	if !set && !all && !tested && i.Options.Nounset {
+		return nil, fmt.Errorf("%s: %w", parameter.name, errUnset)
+	}
+
+	if parameter.length {
+		if all {
+			value = strconv.Itoa(len(list))
+		} else {
+			value = strconv.Itoa(utf8.RuneCountInString(value))
+		}
+
+		return []field{{{value: value, quoted: quoted}}}, nil
+	}
+
+	// With a colon, null parameters are treated like unset ones.
+	unset := !set || strings.HasPrefix(parameter.operator, ":") && value == ""
+
+	switch operator {
+	case "-":
+		if unset {
+			return i.expandParts(parameter.word.parts, quoted)
+		}
+	case "=":
+		if unset {
+			return i.assignDefault(parameter, quoted)
+		}
+	case "?":
+		if unset {
+			return nil, i.parameterError(parameter)
+		}
+	case "+":
+		if !unset {
+			return i.expandParts(parameter.word.parts, quoted)
+		}
+
+		return []field{{{quoted: quoted}}}, nil
+	case "#", "##", "%", "%%":
+		return i.removePattern(parameter, value, quoted)
+	}
+
+	if all {
+		return i.listFields(list, joined, quoted), nil
+	}
+
+	return []field{{{value: value, quoted: quoted}}}, nil
+}
+
+// parameter returns the value of a parameter: a special parameter, a
+// positional parameter or a variable. It also tells if it's set.
+func (i *Interpreter) parameter(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(i.status), true
+	case "$":
+		return strconv.Itoa(i.pid), true
+	case "!":
+		if i.lastJob == nil {
+			return "", false
+		}
+
+		return strconv.Itoa(i.lastJob.table.pid(i.lastJob)), true
+	case "#":
+		return strconv.Itoa(len(i.positional)), true
+	case "-":
+		return i.Options.Flags(), true
+	case "0":
+		return i.name, true
+	case "PIPESTATUS":
+		if len(i.pipeStatus) == 0 {
+			return "", false
+		}
+
+		return strconv.Itoa(i.pipeStatus[0]), true
+	case "@", "*":
+		return strings.Join(i.positional, " "), len(i.positional) > 0
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > len(i.positional) {
+			return "", false
+		}
+
+		return i.positional[n-1], true
+	}
+
+	return i.env.Get(name)
+}
+
+// listFields expands $@ and $*, or ${name[@]} and ${name[*]}, from the
+// values in list. Each value is a field of its own, except in "$*", where
+// they are joined by the first character of IFS.
+func (i *Interpreter) listFields(list []string, joined bool, quoted bool) []field {
+	if joined && quoted {
+		separator := " "
+
+		if ifs, ok := i.env.Get("IFS"); ok {
+			separator = ""
+
+			if char, _ := utf8.DecodeRuneInString(ifs); ifs != "" {
+				separator = string(char)
+			}
+		}
+
+		return []field{{{value: strings.Join(list, separator), quoted: true}}}
+	}
+
+	fields := []field{}
+
+	for _, param := range list {
+		fields = append(fields, field{{value: param, quoted: quoted}})
+	}
+
+	return fields
+}
+
+// array returns the values of name used as an array. PIPESTATUS has the
+// status of each command of the last pipeline, other parameters are arrays
+// of one value, if they're set.
+func (i *Interpreter) array(name string) []string {
+	if name == "PIPESTATUS" {
+		list := []string{}
+
+		for _, status := range i.pipeStatus {
+			list = append(list, strconv.Itoa(status))
+		}
+
+		return list
+	}
+
+	if value, ok := i.parameter(name); ok {
+		return []string{value}
+	}
+
+	return nil
+}
+
+// element returns the value at index in list, or all of them for "@" and
+// "*". It also tells if it's set.
+func element(list []string, index string) (string, bool) {
+	if index == "@" || index == "*" {
+		return strings.Join(list, " "), len(list) > 0
+	}
+
+	n, _ := strconv.Atoi(index)
+
+	if n >= len(list) {
+		return "", false
+	}
+
+	return list[n], true
+}
+
+// assignDefault handles ${name=word} and ${name:=word}, assigning word to
+// name. Only variables can be assigned this way.
+func (i *Interpreter) assignDefault(parameter *Parameter, quoted bool) ([]field, error) {
+	if !isName(parameter.name) {
+		return nil, fmt.Errorf("$%s: cannot assign in this way", parameter.name)
+	}
+
+	value, err := i.expandWord(parameter.word)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := i.env.Set(parameter.name, value); err != nil {
+		return nil, err
+	}
+
+	return []field{{{value: value, quoted: quoted}}}, nil
+}
+
+// parameterError is the error of ${name?word} and ${name:?word}. word is
+// the message, if there's one.
+func (i *Interpreter) parameterError(parameter *Parameter) error {
+	message, err := i.expandWord(parameter.word)
+
+	if err != nil {
+		return err
+	}
+
+	if message == "" {
+		message = errParameter.Error()
+	}
+
+	return &messageError{message: parameter.name + ": " + message, err: errParameter}
+}
+
+// messageError is err with a message of its own, like the word of
+// ${name?word} or what went wrong in an arithmetic expansion.
+type messageError struct {
+	message string
+	err     error
+}
+
+func (e *messageError) Error() string {
+	return e.message
+}
+
+func (e *messageError) Unwrap() error {
+	return e.err
+}
+
+// removePattern handles ${name#word} and the like. "#" removes the shortest
+// prefix matching word and "##" the longest. "%" and "%%" do the same with
+// suffixes.
+func (i *Interpreter) removePattern(
+	parameter *Parameter,
+	value string,
+	quoted bool,
+) ([]field, error) {
+	pattern, err := i.expandPattern(parameter.word)
+
+	if err != nil {
+		return nil, err
+	}
+
+	chars := []rune(value)
+	longest := len(parameter.operator) == 2
+	result := value
+
+	for n := 0; n <= len(chars); n++ {
+		// Shortest matches are found going up from 0 characters, longest
+		// ones going down from all of them.
+		length := n
+
+		if longest {
+			length = len(chars) - n
+		}
+
+		if parameter.operator[0] == '#' && matchPattern(pattern, string(chars[:length])) {
+			result = string(chars[length:])
+			break
+		}
+
+		if parameter.operator[0] == '%' &&
+			matchPattern(pattern, string(chars[len(chars)-length:])) {
+			result = string(chars[:len(chars)-length])
+			break
+		}
+	}
+
+	return []field{{{value: result, quoted: quoted}}}, nil
+}
+
+func escapePattern(str string) string {
+	escaped := strings.Builder{}
+
+	for _, char := range str {
+		if strings.ContainsRune(`*?[]\`, char) {
+			escaped.WriteRune('\\')
+		}
+
+		escaped.WriteRune(char)
+	}
+
+	return escaped.String()
+}
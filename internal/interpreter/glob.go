@@ -0,0 +1,144 @@
+package interpreter
+
+import (
+	"os"
+	"slices"
+	"strings"
+)
+
+// expandPathname turns a field with unquoted pattern characters into the
+// pathnames it matches, sorted. A field that matches nothing, or any field
+// when globbing is off, is kept as it is.
+func (i *Interpreter) expandPathname(f field) []string {
+	pattern := f.pattern()
+
+	if i.Options.Noglob || !hasPattern(pattern) {
+		return []string{f.String()}
+	}
+
+	matches := i.glob(pattern)
+
+	if len(matches) == 0 {
+		return []string{f.String()}
+	}
+
+	slices.Sort(matches)
+
+	return matches
+}
+
+// glob finds the pathnames matching pattern one component at a time, since
+// "*" and the like never match a "/". They're relative to the working
+// directory unless the pattern is absolute.
+func (i *Interpreter) glob(pattern string) []string {
+	components := strings.Split(pattern, "/")
+	paths := []string{""}
+
+	for n, component := range components {
+		next := []string{}
+
+		for _, path := range paths {
+			if hasPattern(component) {
+				next = append(next, i.matchDirectory(path, component)...)
+			} else {
+				next = append(next, path+unescapePattern(component))
+			}
+		}
+
+		if n < len(components)-1 {
+			for k := range next {
+				next[k] += "/"
+			}
+		}
+
+		paths = next
+	}
+
+	matches := []string{}
+
+	// Components without pattern characters were taken as they are, the
+	// path may not exist.
+	for _, path := range paths {
+		name := resolve(i.dir, path)
+
+		// Joining drops the trailing "/", which only directories can have.
+		if strings.HasSuffix(path, "/") {
+			name += "/"
+		}
+
+		if _, err := os.Lstat(name); err == nil {
+			matches = append(matches, path)
+		}
+	}
+
+	return matches
+}
+
+// matchDirectory returns dir joined with each of its entries matching
+// pattern. Names starting with "." are hidden, only a pattern starting with
+// a "." matches them.
+func (i *Interpreter) matchDirectory(dir string, pattern string) []string {
+	name := dir
+
+	if name == "" {
+		name = "."
+	}
+
+	entries, err := os.ReadDir(resolve(i.dir, name))
+
+	if err != nil {
+		return nil
+	}
+
+	hidden := strings.HasPrefix(pattern, ".") || strings.HasPrefix(pattern, `\.`)
+	matches := []string{}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") && !hidden {
+			continue
+		}
+
+		if matchPattern(pattern, entry.Name()) {
+			matches = append(matches, dir+entry.Name())
+		}
+	}
+
+	return matches
+}
+
+// hasPattern tells if pattern has a "*", "?" or "[" that isn't escaped.
+func hasPattern(pattern string) bool {
+	escaped := false
+
+	for _, char := range pattern {
+		switch {
+		case escaped:
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == '*' || char == '?' || char == '[':
+			return true
+		}
+	}
+
+	return false
+}
+
+// unescapePattern removes the backslashes of a pattern, leaving the
+// characters they escape.
+func unescapePattern(pattern string) string {
+	unescaped := strings.Builder{}
+	escaped := false
+
+	for _, char := range pattern {
+		if char == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+
+		escaped = false
+		unescaped.WriteRune(char)
+	}
+
+	return unescaped.String()
+}
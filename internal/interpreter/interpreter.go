@@ -0,0 +1,676 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/marcos-brito/arosh/internal/builtins"
+	"github.com/marcos-brito/arosh/internal/env"
+)
+
+type Interpreter struct {
+	Stdin   *os.File
+	Stdout  *os.File
+	Stderr  *os.File
+	Options Options
+	// Builtins are looked up after functions and before PATH.
+	Builtins builtins.Registry
+	// Editor is what read uses to get lines from a terminal. Without one,
+	// they are read from the terminal like from any other file.
+	Editor     builtins.LineReader
+	extraFiles map[int]*os.File
+	dir        string
+	status     int
+	pipeStatus []int
+	positional []string
+	// name is what $0 expands to.
+	name      string
+	pid       int
+	env       *env.Env
+	functions map[string]*FunctionDef
+	// loops is how many loops are running, break and continue can only
+	// leave that many.
+	loops int
+	// calls is how many function calls are running and sourcing how many
+	// files the . builtin is running, return is only allowed inside one.
+	calls    int
+	sourcing int
+	// substituted tells if a command substitution ran while expanding the
+	// current command.
+	substituted bool
+	exited      bool
+	// jobs is the job table of the shell, job the job the commands run
+	// belong to and lastJob the last one sent to the background, which $!
+	// tells the process id of.
+	jobs    *jobTable
+	job     *Job
+	lastJob *Job
+	// sources has the text of the commands in the program being run.
+	sources map[Node]string
+	// traps has the actions set by trap, by condition. trapping tells if
+	// one is running.
+	traps    map[string]string
+	trapping bool
+	signals  *signals
+	// conditions is how many conditions, like the one of an if, are being
+	// evaluated. Commands failing in them don't set off the ERR trap.
+	conditions  int
+	interactive bool
+	// location is where the commands being run are in a script, which
+	// error messages tell.
+	location string
+}
+
+func New() *Interpreter {
+	dir, _ := os.Getwd()
+	environment := env.FromEnviron(os.Environ())
+	environment.Set("PWD", dir)
+	jobs := newJobTable()
+
+	return &Interpreter{
+		Stdin:      os.Stdin,
+		Stdout:     os.Stdout,
+		Stderr:     os.Stderr,
+		Builtins:   builtins.New(),
+		extraFiles: map[int]*os.File{},
+		dir:        dir,
+		positional: []string{},
+		name:       "arosh",
+		pid:        os.Getpid(),
+		env:        environment,
+		functions:  map[string]*FunctionDef{},
+		jobs:       jobs,
+		traps:      map[string]string{},
+		signals:    newSignals(jobs),
+	}
+}
+
+func (i *Interpreter) Status() int {
+	return i.status
+}
+
+// PipeStatus returns the exit status of every command in the last
+// foreground pipeline, like bash's PIPESTATUS.
+func (i *Interpreter) PipeStatus() []int {
+	return i.pipeStatus
+}
+
+// SetPositional sets the positional parameters, $1 and so on.
+func (i *Interpreter) SetPositional(params []string) {
+	i.positional = params
+}
+
+// SetName sets what $0 expands to, the name of the shell or script.
+func (i *Interpreter) SetName(name string) {
+	i.name = name
+}
+
+// Run runs every command of program, unless the exit builtin or an
+// interrupt stops it first. Traps of signals caught before and while it
+// runs are run too.
+func (i *Interpreter) Run(program *Program) int {
+	err := i.handleSignals(true)
+
+	if err == nil {
+		err = i.run(program)
+	}
+
+	if err == nil {
+		i.handleSignals(false)
+	}
+
+	return i.status
+}
+
+func (i *Interpreter) run(program *Program) error {
+	i.sources = program.sources
+
+	for _, node := range program.nodes {
+		if err := i.evalAsync(node, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sequences are left nested, so the separator of a sequence applies to the
+// last command of its left hand side. async tells if the trailing command of
+// node should be sent to the background.
+//
+// The error returned by evaluation is only used for control flow, like break
+// and continue, which have to unwind everything up to their loop. Other
+// errors are reported where they happen and turned into an exit status.
+func (i *Interpreter) evalAsync(node Node, async bool) error {
+	if sequence, ok := node.(*Sequence); ok {
+		return i.evalSequence(sequence, async)
+	}
+
+	if async {
+		i.background(node)
+		return nil
+	}
+
+	return i.eval(node)
+}
+
+// eval runs node once the traps of the signals caught are run, unless
+// noexec is on. Commands and pipelines that fail outside of conditions set
+// off the ERR trap, if they're not in a function either, and exit the
+// shell with errexit on.
+func (i *Interpreter) eval(node Node) error {
+	if i.Options.Noexec && !i.interactive {
+		return nil
+	}
+
+	if err := i.handleSignals(false); err != nil {
+		return err
+	}
+
+	err := i.evalNode(node)
+
+	switch node.(type) {
+	case *SimpleCommand, *Pipe, *Subshell:
+		if err != nil || i.status == 0 || i.conditions > 0 {
+			break
+		}
+
+		if i.calls == 0 {
+			err = i.trap("ERR")
+		}
+
+		if err == nil && i.Options.Errexit {
+			i.exited = true
+			err = errExit
+		}
+	}
+
+	return err
+}
+
+func (i *Interpreter) evalNode(node Node) error {
+	switch node := node.(type) {
+	case *Sequence:
+		return i.evalSequence(node, false)
+	case *Conditional:
+		return i.evalConditional(node)
+	case *Pipe:
+		i.evalPipe(node)
+	case *Not:
+		return i.evalNot(node)
+	case *SimpleCommand:
+		return i.evalSimpleCommand(node)
+	case *RedirectedCommand:
+		return i.evalRedirectedCommand(node)
+	case *IfClause:
+		return i.evalIf(node)
+	case *WhileClause:
+		return i.evalWhile(node)
+	case *ForClause:
+		return i.evalFor(node)
+	case *CaseClause:
+		return i.evalCase(node)
+	case *BraceGroup:
+		return i.eval(node.body)
+	case *Subshell:
+		i.evalSubshell(node)
+	case *FunctionDef:
+		i.functions[node.name] = node
+		i.status = 0
+	}
+
+	return nil
+}
+
+func (i *Interpreter) evalSequence(sequence *Sequence, async bool) error {
+	if err := i.evalAsync(sequence.lhs, sequence.separator == AND); err != nil {
+		return err
+	}
+
+	if sequence.rhs != nil {
+		return i.evalAsync(sequence.rhs, async)
+	}
+
+	return nil
+}
+
+func (i *Interpreter) evalConditional(conditional *Conditional) error {
+	if err := i.evalCondition(conditional.lhs); err != nil {
+		return err
+	}
+
+	if conditional.conditionalType == DAND && i.status != 0 {
+		return nil
+	}
+
+	if conditional.conditionalType == DPIPE && i.status == 0 {
+		return nil
+	}
+
+	return i.eval(conditional.rhs)
+}
+
+// evalNot runs a negated pipeline. Like a condition, its failing isn't an
+// error, whatever its status ends up being.
+func (i *Interpreter) evalNot(not *Not) error {
+	if err := i.evalCondition(not.pipeline); err != nil {
+		return err
+	}
+
+	if i.status == 0 {
+		i.status = 1
+	} else {
+		i.status = 0
+	}
+
+	return nil
+}
+
+// evalPipe runs a pipeline, which is a job of its own with job control on.
+func (i *Interpreter) evalPipe(pipe *Pipe) {
+	if i.Options.Monitor && i.job == nil {
+		i.foreground(pipe, func(shell *Interpreter) { shell.runPipe(pipe) })
+		return
+	}
+
+	i.runPipe(pipe)
+}
+
+// runPipe runs every stage of the pipeline at the same time, connecting
+// them with OS pipes. The status of the pipeline is the status of its last
+// stage, or with pipefail of the last one that failed. The status of each
+// stage is kept in pipeStatus.
+func (i *Interpreter) runPipe(pipe *Pipe) {
+	stages := pipeStages(pipe)
+	shells, err := i.pipeShells(len(stages))
+
+	if err != nil {
+		i.errorf("%s", err)
+		i.status = 1
+		return
+	}
+
+	statuses := make([]int, len(stages))
+	group := sync.WaitGroup{}
+
+	for n, stage := range stages {
+		group.Add(1)
+		go func(shell *Interpreter, n int, stage Node) {
+			defer group.Done()
+			defer shell.closePipeEnds(i)
+
+			shell.leave(shell.eval(stage))
+			statuses[n] = shell.status
+		}(shells[n], n, stage)
+	}
+
+	group.Wait()
+
+	i.status = statuses[len(statuses)-1]
+	i.pipeStatus = statuses
+
+	if i.Options.Pipefail {
+		for _, status := range statuses {
+			if status != 0 {
+				i.status = status
+			}
+		}
+	}
+}
+
+// pipeShells creates a subshell for each stage of a pipeline, connecting the
+// output of one to the input of the next.
+func (i *Interpreter) pipeShells(n int) ([]*Interpreter, error) {
+	shells := make([]*Interpreter, n)
+
+	for k := range shells {
+		shells[k] = i.subshell()
+	}
+
+	for k := 0; k < n-1; k++ {
+		reader, writer, err := os.Pipe()
+
+		if err != nil {
+			for _, shell := range shells {
+				shell.closePipeEnds(i)
+			}
+
+			return nil, err
+		}
+
+		shells[k].Stdout = writer
+		shells[k+1].Stdin = reader
+	}
+
+	return shells, nil
+}
+
+// pipeStages flattens the left nested pipes built by the parser.
+func pipeStages(node Node) []Node {
+	pipe, ok := node.(*Pipe)
+
+	if !ok {
+		return []Node{node}
+	}
+
+	return append(pipeStages(pipe.lhs), pipe.rhs)
+}
+
+// closePipeEnds closes the files that were created for a pipeline stage, the
+// ones it shares with parent are left alone.
+func (i *Interpreter) closePipeEnds(parent *Interpreter) {
+	if i.Stdin != parent.Stdin {
+		i.Stdin.Close()
+	}
+
+	if i.Stdout != parent.Stdout {
+		i.Stdout.Close()
+	}
+}
+
+func (i *Interpreter) evalSimpleCommand(command *SimpleCommand) error {
+	defer func() {
+		i.pipeStatus = []int{i.status}
+	}()
+
+	if err := i.trap("DEBUG"); err != nil {
+		return err
+	}
+
+	i.substituted = false
+	restore, err := i.redirect(command.redirections)
+
+	if err != nil {
+		return i.commandError(err)
+	}
+
+	defer restore()
+
+	args := []string{}
+
+	if command.name != nil {
+		args, err = i.expandWords(append([]*Word{command.name}, command.params...))
+
+		if err != nil {
+			return i.commandError(err)
+		}
+	}
+
+	// Without a command, which can also happen when every word expands to
+	// nothing, the assignments are made in the shell itself. The status is
+	// the one of the last command substitution, if there was any.
+	if len(args) == 0 {
+		if err := i.assign(command.assignments, false); err != nil {
+			return i.commandError(err)
+		}
+
+		if !i.substituted {
+			i.status = 0
+		}
+
+		i.settleJob()
+		i.trace(command.assignments, nil)
+
+		return nil
+	}
+
+	// Assignments before a command only apply to it, so they go in a scope
+	// of their own.
+	if len(command.assignments) > 0 {
+		i.env.Push()
+		defer i.env.Pop()
+
+		if err := i.assign(command.assignments, true); err != nil {
+			return i.commandError(err)
+		}
+	}
+
+	i.trace(command.assignments, args)
+
+	if i.inShell(args[0]) {
+		i.settleJob()
+	}
+
+	if builtin, ok := controlBuiltins[args[0]]; ok {
+		return builtin(i, args)
+	}
+
+	if function, ok := i.functions[args[0]]; ok {
+		return i.call(function, args)
+	}
+
+	if builtin, ok := i.Builtins.Lookup(args[0]); ok {
+		stdout := &pipeWriter{file: i.Stdout}
+		streams := builtins.Streams{
+			Stdin:    i.Stdin,
+			Stdout:   stdout,
+			Stderr:   i.Stderr,
+			Location: i.location,
+		}
+		i.status = builtin(i, streams, args)
+
+		// A program writing to a pipe nobody reads anymore is killed by
+		// SIGPIPE. The shell can't be, so it leaves like it was.
+		if stdout.broken {
+			i.status = 128 + int(syscall.SIGPIPE)
+			i.exited = true
+		}
+
+		if i.exited {
+			return errExit
+		}
+
+		return nil
+	}
+
+	if i.Options.Monitor && i.job == nil {
+		i.foreground(command, func(shell *Interpreter) { shell.status = shell.execute(args) })
+		return nil
+	}
+
+	i.status = i.execute(args)
+
+	return nil
+}
+
+// pipeWriter is the standard output of a builtin, which tells if it was
+// written to after the other end of a pipe was closed.
+type pipeWriter struct {
+	file   *os.File
+	broken bool
+}
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.broken = w.broken || errors.Is(err, syscall.EPIPE)
+
+	return n, err
+}
+
+// Fd lets builtins tell if the output is a terminal.
+func (w *pipeWriter) Fd() uintptr {
+	return w.file.Fd()
+}
+
+// assign makes the assignments of a simple command. Temporary assignments
+// are made in the innermost scope and exported, since they are meant for
+// the command they come before.
+func (i *Interpreter) assign(assignments []*Assignment, temporary bool) error {
+	for _, assignment := range assignments {
+		parts := i.expandTilde(assignment.value.parts, true)
+		value, err := i.expandWord(&Word{parts: parts})
+
+		if err != nil {
+			return err
+		}
+
+		if temporary {
+			if err := i.env.Declare(assignment.name); err != nil {
+				return err
+			}
+
+			i.env.Export(assignment.name)
+		}
+
+		if err := i.env.Set(assignment.name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inShell tells if name is run by the shell itself, as a builtin or a
+// function, instead of by a program.
+func (i *Interpreter) inShell(name string) bool {
+	_, control := controlBuiltins[name]
+	_, function := i.functions[name]
+	_, builtin := i.Builtins.Lookup(name)
+
+	return control || function || builtin
+}
+
+func (i *Interpreter) evalRedirectedCommand(command *RedirectedCommand) error {
+	restore, err := i.redirect(command.redirections)
+
+	if err != nil {
+		return i.commandError(err)
+	}
+
+	defer restore()
+
+	return i.eval(command.command)
+}
+
+// evalSubshell runs a subshell in a copy of the interpreter. Whatever it
+// does to leave, like break or return, only leaves the subshell. With job
+// control on, it's a job of its own.
+func (i *Interpreter) evalSubshell(subshell *Subshell) {
+	if i.Options.Monitor && i.job == nil {
+		i.foreground(subshell, func(shell *Interpreter) { shell.evalSubshell(subshell) })
+		return
+	}
+
+	shell := i.subshell()
+	shell.leave(shell.eval(subshell.body))
+	shell.RunExitTrap()
+	i.status = shell.status
+}
+
+// leave handles what unwinds a copy of the interpreter, which stops there.
+// return leaves it with the status given, break and continue with the one
+// they set.
+func (i *Interpreter) leave(err error) {
+	if ret, ok := err.(*returnError); ok {
+		i.status = ret.status
+	}
+}
+
+// subshell copies the interpreter. Variables, functions, the working
+// directory and open files are copied, so changing them in the copy doesn't
+// affect the original. Subshells start without jobs and job control.
+func (i *Interpreter) subshell() *Interpreter {
+	shell := *i
+	shell.extraFiles = maps.Clone(i.extraFiles)
+	shell.env = i.env.Clone()
+	shell.functions = maps.Clone(i.functions)
+	shell.jobs = newJobTable()
+	shell.Options.Monitor = false
+	shell.signals = nil
+	shell.traps = map[string]string{}
+
+	// Only the signals that are ignored stay that way in a subshell.
+	for condition, action := range i.traps {
+		if action == "" {
+			shell.traps[condition] = action
+		}
+	}
+
+	return &shell
+}
+
+// file returns the file open at the file descriptor fd, or nil if
+// it's closed.
+func (i *Interpreter) file(fd int) *os.File {
+	switch fd {
+	case 0:
+		return i.Stdin
+	case 1:
+		return i.Stdout
+	case 2:
+		return i.Stderr
+	}
+
+	return i.extraFiles[fd]
+}
+
+func (i *Interpreter) setFile(fd int, file *os.File) {
+	switch fd {
+	case 0:
+		i.Stdin = file
+	case 1:
+		i.Stdout = file
+	case 2:
+		i.Stderr = file
+	default:
+		if file == nil {
+			delete(i.extraFiles, fd)
+			return
+		}
+
+		i.extraFiles[fd] = file
+	}
+}
+
+// files returns the open files indexed by their file descriptors, which is
+// what child processes expect.
+func (i *Interpreter) files() []*os.File {
+	files := []*os.File{i.Stdin, i.Stdout, i.Stderr}
+
+	for fd, file := range i.extraFiles {
+		for len(files) <= fd {
+			files = append(files, nil)
+		}
+
+		files[fd] = file
+	}
+
+	return files
+}
+
+// source returns the text of node as it was written.
+func (i *Interpreter) source(node Node) string {
+	if text, ok := i.sources[node]; ok {
+		return text
+	}
+
+	return node.String()
+}
+
+// commandError reports an error that kept a command from running, like
+// one of its expansions failing, which makes its status 1. An unset
+// parameter with nounset on, one expanded with ${name?word} or an
+// arithmetic expansion that fails also exits a shell that isn't
+// interactive.
+func (i *Interpreter) commandError(err error) error {
+	i.errorf("%s", err)
+	i.status = 1
+
+	exits := errors.Is(err, errUnset) || errors.Is(err, errParameter) ||
+		errors.Is(err, errArithmetic)
+
+	if exits && !i.interactive {
+		i.exited = true
+		return errExit
+	}
+
+	return nil
+}
+
+func (i *Interpreter) errorf(format string, a ...any) {
+	fmt.Fprintf(i.Stderr, "arosh: "+i.location+format+"\n", a...)
+}
@@ -0,0 +1,1469 @@
+package interpreter
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, source string) (string, int) {
+	t.Helper()
+
+	stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+
+	if err != nil {
+		t.Fatalf("Error creating temporary files")
+	}
+
+	defer stdout.Close()
+
+	program, err := NewParser(NewLexer(source)).Parse()
+
+	if err != nil {
+		t.Fatalf("%s: %s", source, err)
+	}
+
+	interpreter := New()
+	interpreter.Stdout = stdout
+	status := interpreter.Run(program)
+
+	stdout.Seek(0, io.SeekStart)
+	output, _ := io.ReadAll(stdout)
+
+	return string(output), status
+}
+
+func TestRunningPrograms(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"echo hello",
+			"hello\n",
+			0,
+		},
+		{
+			"false",
+			"",
+			1,
+		},
+		{
+			"true && echo yes || echo no",
+			"yes\n",
+			0,
+		},
+		{
+			"false && echo yes || echo no",
+			"no\n",
+			0,
+		},
+		{
+			"echo first; echo second",
+			"first\nsecond\n",
+			0,
+		},
+		{
+			"echo piped | tr i I",
+			"pIped\n",
+			0,
+		},
+		{
+			"echo a | false",
+			"",
+			1,
+		},
+		{
+			"nonexistentcommand",
+			"",
+			127,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestPipeStatus(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected []int
+	}{
+		{
+			"true | false | true",
+			[]int{0, 1, 0},
+		},
+		{
+			"false | false",
+			[]int{1, 1},
+		},
+		{
+			"seq 1 1000000 | head -n 1",
+			[]int{141, 0},
+		},
+		{
+			"while true; do echo y; done | head -n 1",
+			[]int{141, 0},
+		},
+		{
+			"(while true; do printf 'y\\n'; done; echo no) | head -n 1",
+			[]int{141, 0},
+		},
+		{
+			"false",
+			[]int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		program, _ := NewParser(NewLexer(tt.source)).Parse()
+		interpreter := New()
+		interpreter.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		interpreter.Run(program)
+
+		if !reflect.DeepEqual(interpreter.PipeStatus(), tt.expected) {
+			t.Errorf(
+				"%s: Expected %v, but got %v",
+				tt.source,
+				tt.expected,
+				interpreter.PipeStatus(),
+			)
+		}
+	}
+}
+
+func TestRedirections(t *testing.T) {
+	tests := []struct {
+		source         string
+		noclobber      bool
+		file           string
+		expectedStatus int
+		expectedFile   string
+	}{
+		{
+			"echo hello > out",
+			false,
+			"out",
+			0,
+			"hello\n",
+		},
+		{
+			"echo hello > out; echo again >> out",
+			false,
+			"out",
+			0,
+			"hello\nagain\n",
+		},
+		{
+			"echo hello > out; tr h j < out > other",
+			false,
+			"other",
+			0,
+			"jello\n",
+		},
+		{
+			"ls nonexistent 2> err > out; cat err | wc -l > out",
+			false,
+			"out",
+			0,
+			"1\n",
+		},
+		{
+			"ls nonexistent 3> err 2>& 3",
+			false,
+			"out",
+			2,
+			"",
+		},
+		{
+			"cat < nonexistent > out",
+			false,
+			"out",
+			1,
+			"",
+		},
+		{
+			"echo first > out; echo second > out",
+			true,
+			"out",
+			1,
+			"first\n",
+		},
+		{
+			"echo first > out; echo second >| out",
+			true,
+			"out",
+			0,
+			"second\n",
+		},
+		{
+			"echo first > out; echo second 1>& - > out",
+			false,
+			"out",
+			0,
+			"second\n",
+		},
+		{
+			"f() { echo called; } >> out; f; f",
+			false,
+			"out",
+			0,
+			"called\ncalled\n",
+		},
+		{
+			"(echo inner; echo other >&2) 2> out > /dev/null",
+			false,
+			"out",
+			0,
+			"other\n",
+		},
+		{
+			"{ echo a; echo b; } > out",
+			false,
+			"out",
+			0,
+			"a\nb\n",
+		},
+		{
+			"printf 'a\\nb\\n' > in; { read x; read y; } < in; echo $x$y > out",
+			false,
+			"out",
+			0,
+			"ab\n",
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		program, err := NewParser(NewLexer(tt.source)).Parse()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.source, err)
+		}
+
+		interpreter := New()
+		interpreter.dir = dir
+		interpreter.Options.Noclobber = tt.noclobber
+		interpreter.Stderr, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		status := interpreter.Run(program)
+		content, _ := os.ReadFile(filepath.Join(dir, tt.file))
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+
+		if string(content) != tt.expectedFile {
+			t.Errorf(
+				"%s: Expected %s to be %q, but got %q",
+				tt.source,
+				tt.file,
+				tt.expectedFile,
+				content,
+			)
+		}
+	}
+}
+
+func TestHereDocs(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+	}{
+		{
+			"cat <<EOF\nhello\nworld\nEOF",
+			"hello\nworld\n",
+		},
+		{
+			"cat <<-EOF\n\tindented\n\t\tmore\n\tEOF",
+			"indented\nmore\n",
+		},
+		{
+			"cat <<EOF\nescaped \\$ and \\\\ but not \\n\njoined \\\nline\nEOF",
+			"escaped $ and \\ but not \\n\njoined line\n",
+		},
+		{
+			"cat <<\\EOF\nkept \\$ and \\\\\nEOF",
+			"kept \\$ and \\\\\n",
+		},
+		{
+			"cat <<EOF | tr o O\nshout\nEOF\necho done",
+			"shOut\ndone\n",
+		},
+	}
+
+	for _, tt := range tests {
+		output, _ := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%q: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestQuoteRemoval(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+	}{
+		{
+			"echo 'hello   world'",
+			"hello   world\n",
+		},
+		{
+			`echo "a \"quoted\" \word"`,
+			"a \"quoted\" \\word\n",
+		},
+		{
+			`echo a\ b\\c`,
+			"a b\\c\n",
+		},
+		{
+			`echo 'it'"'"'s' "" end`,
+			"it's  end\n",
+		},
+		{
+			"echo 'a | b' \"; c\"",
+			"a | b ; c\n",
+		},
+		{
+			"tr a-z A-Z <<'END'\nquoted $delimiter\nEND",
+			"QUOTED $DELIMITER\n",
+		},
+	}
+
+	for _, tt := range tests {
+		output, _ := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%q: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestCompoundCommands(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"if true; then echo yes; else echo no; fi",
+			"yes\n",
+			0,
+		},
+		{
+			"if false; then echo a; elif true; then echo b; else echo c; fi",
+			"b\n",
+			0,
+		},
+		{
+			"if ! false; then echo a; fi; ! true; echo $?; ! ! true",
+			"a\n1\n",
+			0,
+		},
+		{
+			"! echo a | grep -q b && echo none",
+			"none\n",
+			0,
+		},
+		{
+			"if false; then echo a; fi",
+			"",
+			0,
+		},
+		{
+			"if true; then false; fi",
+			"",
+			1,
+		},
+		{
+			"while true; do echo once; break; done",
+			"once\n",
+			0,
+		},
+		{
+			"until true; do echo never; done",
+			"",
+			0,
+		},
+		{
+			"for x in a b c; do echo loop; done",
+			"loop\nloop\nloop\n",
+			0,
+		},
+		{
+			"for x in a b; do echo outer; for y in c d; do continue 2; echo no; done; done",
+			"outer\nouter\n",
+			0,
+		},
+		{
+			"for x in a; do while true; do break 5; done; echo no; done; echo after",
+			"after\n",
+			0,
+		},
+		{
+			"for x in a; do false; done",
+			"",
+			1,
+		},
+		{
+			"case main.go in *.c) echo c;; *.go | *.rs) echo go;; *) echo other;; esac",
+			"go\n",
+			0,
+		},
+		{
+			"case '*' in a) echo a;; '*') echo star;; esac",
+			"star\n",
+			0,
+		},
+		{
+			"case abc in '*') echo star;; \"a\"*) echo prefix; esac",
+			"prefix\n",
+			0,
+		},
+		{
+			"case x in y) echo y;; esac",
+			"",
+			0,
+		},
+		{
+			"while true; do echo piped; break; done | tr d D",
+			"pipeD\n",
+			0,
+		},
+		{
+			"if true; then echo a; echo b; fi | wc -l",
+			"2\n",
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestForPositionalParameters(t *testing.T) {
+	program, _ := NewParser(NewLexer("for arg do true; done")).Parse()
+	interpreter := New()
+	interpreter.SetPositional([]string{"first", "second"})
+	interpreter.Run(program)
+
+	if value, _ := interpreter.env.Get("arg"); value != "second" {
+		t.Errorf("Expected arg to be %q, but got %q", "second", value)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"greet() { echo hello; }; greet; greet",
+			"hello\nhello\n",
+			0,
+		},
+		{
+			"function greet { echo hello; }\ngreet",
+			"hello\n",
+			0,
+		},
+		{
+			"function fail() { return 3; echo no; }; fail",
+			"",
+			3,
+		},
+		{
+			"f() { false; return; }; f",
+			"",
+			1,
+		},
+		{
+			"f() { for x in a b; do return 4; done; echo no; }; f",
+			"",
+			4,
+		},
+		{
+			"x=global; f() { local x=1 y; y=2; g; }; g() { echo $x $y; }; f; echo $x ${y-unset}",
+			"1 2\nglobal unset\n",
+			0,
+		},
+		{
+			"f() { local x; echo ${x-unset}; x=1; }; x=global; f; echo $x",
+			"unset\nglobal\n",
+			0,
+		},
+		{
+			"local x=1",
+			"",
+			1,
+		},
+		{
+			"f() { for arg do echo arg; done; }; f a b c",
+			"arg\narg\narg\n",
+			0,
+		},
+		{
+			"f() { break; }; for x in a b; do echo loop; f; done",
+			"loop\nloop\n",
+			0,
+		},
+		{
+			"f() if true; then echo compound; fi\nf | tr c C",
+			"Compound\n",
+			0,
+		},
+		{
+			"return 2",
+			"",
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestSubshellsAndGroups(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"(echo a; echo b) | wc -l",
+			"2\n",
+			0,
+		},
+		{
+			"(f() { echo defined; }; f); f",
+			"defined\n",
+			127,
+		},
+		{
+			"f() { (return 4); echo $?; return 3 | true; echo ${PIPESTATUS[0]}; }; f",
+			"4\n3\n",
+			0,
+		},
+		{
+			"for i in 1 2; do (break; echo no); echo $i; (continue); done; echo $?",
+			"1\n2\n0\n",
+			0,
+		},
+		{
+			"{ f() { echo defined; }; }; f",
+			"defined\n",
+			0,
+		},
+		{
+			"(false) || echo failed",
+			"failed\n",
+			0,
+		},
+		{
+			"for x in a b; do (break); echo loop; done",
+			"loop\nloop\n",
+			0,
+		},
+		{
+			"f() { (return 3); echo after; return 4; }; f",
+			"after\n",
+			4,
+		},
+		{
+			"( (echo nested) )",
+			"nested\n",
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"GREETING=hello printenv GREETING",
+			"hello\n",
+			0,
+		},
+		{
+			"GREETING=hello; printenv GREETING",
+			"",
+			1,
+		},
+		{
+			"A=1 B='two words' printenv B A",
+			"two words\n1\n",
+			0,
+		},
+		{
+			"PATH=/nonexistent ls",
+			"",
+			127,
+		},
+		{
+			"f() { printenv VALUE; }; VALUE=passed f; f",
+			"passed\n",
+			1,
+		},
+		{
+			"EMPTY= printenv EMPTY",
+			"\n",
+			0,
+		},
+		{
+			"HOME=/elsewhere env | grep ^HOME=",
+			"HOME=/elsewhere\n",
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestAssignmentScope(t *testing.T) {
+	program, _ := NewParser(NewLexer("A=1; B=2; A=3 true; (B=4); f() { C=5; }; f")).Parse()
+	interpreter := New()
+	interpreter.Run(program)
+
+	expected := map[string]string{"A": "1", "B": "2", "C": "5"}
+
+	for name, expectedValue := range expected {
+		if value, _ := interpreter.env.Get(name); value != expectedValue {
+			t.Errorf("%s: Expected %q, but got %q", name, expectedValue, value)
+		}
+	}
+}
+
+func TestParameterExpansion(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"x=hello; echo $x ${x} \"$x\"",
+			"hello hello hello\n",
+			0,
+		},
+		{
+			"echo $unset x; echo \"$unset\" x",
+			"x\n x\n",
+			0,
+		},
+		{
+			"x=1; echo '$x' \"\\$x\" \\$x $ \"$\"",
+			"$x $x $x $ $\n",
+			0,
+		},
+		{
+			"x=; echo ${x:-default} ${x-set} ${unset-unset}",
+			"default unset\n",
+			0,
+		},
+		{
+			"echo ${y:=assigned} $y; y=; echo ${y=kept}.",
+			"assigned assigned\n.\n",
+			0,
+		},
+		{
+			"echo ${y:?missing}; echo after",
+			"",
+			1,
+		},
+		{
+			"(echo ${y:?missing}; echo no); echo $? after",
+			"1 after\n",
+			0,
+		},
+		{
+			"echo ${y?}",
+			"",
+			1,
+		},
+		{
+			"x=set; echo ${x:+alt} ${unset:+alt}.",
+			"alt .\n",
+			0,
+		},
+		{
+			"x=héllo; echo ${#x} ${#unset}",
+			"5 0\n",
+			0,
+		},
+		{
+			"p=/usr/bin/file.tar.gz; echo ${p#*/} ${p##*/} ${p%.*} ${p%%.*}",
+			"usr/bin/file.tar.gz file.tar.gz /usr/bin/file.tar /usr/bin/file\n",
+			0,
+		},
+		{
+			"x='a*b'; echo ${x#'a*'} ${x#a\\*} ${x#\"a\"*}; x=aXb; echo ${x#'a*'}",
+			"b b *b\naXb\n",
+			0,
+		},
+		{
+			"f() { echo $# $1 $2; for a in \"$@\"; do echo \"[$a]\"; done; }; f 'one two' three",
+			"2 one two three\n[one two]\n[three]\n",
+			0,
+		},
+		{
+			"f() { echo \"$*\"; IFS=-; echo \"$*\"; IFS=; echo \"$*\"; }; f a b",
+			"a b\na-b\nab\n",
+			0,
+		},
+		{
+			"f() { for a in \"$@\"; do echo no; done; echo \"[$@]\" ${#@} ${#}; }; f",
+			"[] 0 0\n",
+			0,
+		},
+		{
+			"f() { echo ${10} $10; }; f 1 2 3 4 5 6 7 8 9 ten",
+			"ten 10\n",
+			0,
+		},
+		{
+			"echo $?; false; echo $? $0 \"$-\"",
+			"0\n1 arosh \n",
+			0,
+		},
+		{
+			"case $$ in [1-9]*) echo pid;; esac",
+			"pid\n",
+			0,
+		},
+		{
+			"x='a*'; case ab in \"$x\") echo quoted;; $x) echo unquoted;; esac",
+			"unquoted\n",
+			0,
+		},
+		{
+			"echo ${x:y}",
+			"",
+			1,
+		},
+		{
+			"false | true; echo ${PIPESTATUS[@]} ${PIPESTATUS[1]} ${#PIPESTATUS[*]} $PIPESTATUS",
+			"1 0 0 2 1\n",
+			0,
+		},
+		{
+			"x=a; echo ${x[0]} ${x[1]-unset} ${#x[@]} ${#unset[@]}",
+			"a unset 1 0\n",
+			0,
+		},
+		{
+			"echo ${PIPESTATUS[x]}",
+			"",
+			1,
+		},
+		{
+			"x=world; cat <<EOF\nhello $x \"${x}\" \\$x\nEOF",
+			"hello world \"world\" $x\n",
+			0,
+		},
+		{
+			"x=a; A$x=1 echo assigned",
+			"",
+			127,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestCommandSubstitution(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"echo $(echo hello) `echo world`",
+			"hello world\n",
+			0,
+		},
+		{
+			"x=$(printf 'a\\n\\n\\n'); echo \"[$x]\"",
+			"[a]\n",
+			0,
+		},
+		{
+			"echo \"$(echo \"a  b\")\" $(echo \"a  b\")",
+			"a  b a b\n",
+			0,
+		},
+		{
+			"echo $(echo $(echo nested) \"$(echo ')')\")",
+			"nested )\n",
+			0,
+		},
+		{
+			"echo $(case a in a) echo matched;; esac)",
+			"matched\n",
+			0,
+		},
+		{
+			"echo `echo \\`echo inner\\``; echo \"`echo \\\"quoted\\\"`\"",
+			"inner\nquoted\n",
+			0,
+		},
+		{
+			"x=1; y=$(x=2; echo $x); echo $x $y",
+			"1 2\n",
+			0,
+		},
+		{
+			"x=$(false); echo $?; x=$(true) $(false); echo $?",
+			"1\n0\n",
+			0,
+		},
+		{
+			"echo ${unset:-$(echo default)}",
+			"default\n",
+			0,
+		},
+		{
+			"cat <<EOF\n$(echo here) `echo doc`\nEOF",
+			"here doc\n",
+			0,
+		},
+		{
+			"$(echo false)",
+			"",
+			1,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestFieldSplitting(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+	}{
+		{
+			"x='  a  b  '; for f in $x; do echo \"[$f]\"; done",
+			"[a]\n[b]\n",
+		},
+		{
+			"x='a:b::c:'; IFS=:; for f in $x; do echo \"[$f]\"; done",
+			"[a]\n[b]\n[]\n[c]\n",
+		},
+		{
+			"x=' a , b ,, c '; IFS=' ,'; for f in $x; do echo \"[$f]\"; done",
+			"[a]\n[b]\n[]\n[c]\n",
+		},
+		{
+			"x='a b'; IFS=; for f in $x; do echo \"[$f]\"; done",
+			"[a b]\n",
+		},
+		{
+			"x=' b '; for f in a$x\"\"c; do echo \"[$f]\"; done",
+			"[a]\n[b]\n[c]\n",
+		},
+		{
+			"x=' '; for f in \"\"$x; do echo \"[$f]\"; done",
+			"[]\n",
+		},
+		{
+			"x='a b'; y=$x; echo \"$y\"; for f in ${unset:-$x}; do echo \"[$f]\"; done",
+			"a b\n[a]\n[b]\n",
+		},
+		{
+			"f() { for a in $*; do echo \"[$a]\"; done; }; f 'a b' c",
+			"[a]\n[b]\n[c]\n",
+		},
+		{
+			"for f in $(printf 'a\\nb c'); do echo \"[$f]\"; done",
+			"[a]\n[b]\n[c]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		output, _ := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestArithmeticExpansion(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			"x=4; echo $((x * (x + 1))) $(( $x / 3 )) $((-x))",
+			"20 1 -4\n",
+			0,
+		},
+		{
+			"i=0; while [ $i -lt 3 ]; do i=$((i + 1)); done; echo $i",
+			"3\n",
+			0,
+		},
+		{
+			"echo $((x = 2, 1))",
+			"",
+			1,
+		},
+		{
+			"echo $((y = 7)) $y; : $((y += 1)); echo $y",
+			"7 7\n8\n",
+			0,
+		},
+		{
+			"echo $(( $(echo 6) * \"2\" + ${unset:-1} ))",
+			"13\n",
+			0,
+		},
+		{
+			"echo $((1 / 0)); echo after",
+			"",
+			1,
+		},
+		{
+			"(echo $((08)); echo no) 2>&1; x=abc; (: $((x))) 2>&1; echo $?",
+			"arosh: 08: invalid number\narosh: x: abc: invalid number\n1\n",
+			0,
+		},
+		{
+			"(echo $((1 + 2 / 0))) 2>&1",
+			"arosh: 1 + 2 / 0: division by zero\n",
+			1,
+		},
+		{
+			"echo $((9223372036854775807 + 1))",
+			"",
+			1,
+		},
+		{
+			"echo $((echo a) | tr a b)",
+			"b\n",
+			0,
+		},
+		{
+			"echo \"$((1 + 1))\" $((2 > 1 ? 10 : 20))",
+			"2 10\n",
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestPathnameExpansion(t *testing.T) {
+	tests := []struct {
+		source         string
+		noglob         bool
+		expectedOutput string
+	}{
+		{"echo *", false, "a.go b.go c.txt dir\n"},
+		{"echo *.go ?.txt [ab].* [!a]*.go", false, "a.go b.go c.txt a.go b.go b.go\n"},
+		{"echo .* dir/.*", false, ".hidden dir/.secret\n"},
+		{"echo d*/* */", false, "dir/x.go dir/\n"},
+		{"echo '*'.go \"*\" \\* *.none", false, "*.go * * *.none\n"},
+		{"x='*.txt'; echo $x \"$x\"", false, "c.txt *.txt\n"},
+		{"for f in *.go; do echo $f; done", false, "a.go\nb.go\n"},
+		{"echo *.go", true, "*.go\n"},
+		{"echo $PWD/*.txt | sed \"s|$PWD|.|\"", false, "./c.txt\n"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		files := []string{"a.go", "b.go", "c.txt", ".hidden", "dir/x.go", "dir/.secret"}
+
+		for _, name := range files {
+			os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+			os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+		}
+
+		stdout, _ := os.CreateTemp(t.TempDir(), "stdout")
+		program, err := NewParser(NewLexer(tt.source)).Parse()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.source, err)
+		}
+
+		interpreter := New()
+		interpreter.dir = dir
+		interpreter.env.Set("PWD", dir)
+		interpreter.Stdout = stdout
+		interpreter.Options.Noglob = tt.noglob
+		interpreter.Run(program)
+
+		stdout.Seek(0, io.SeekStart)
+		output, _ := io.ReadAll(stdout)
+		stdout.Close()
+
+		if string(output) != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestTildeExpansion(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+	}{
+		{"echo ~ ~/bin \"~\" '~' \\~ a~", "/home/me /home/me/bin ~ ~ ~ a~\n"},
+		{"echo ~+ ~- ~+/x", "/work /old /work/x\n"},
+		{"echo ~nosuchuserreally/x", "~nosuchuserreally/x\n"},
+		{"echo ~\"/q\" ~$x", "~/q ~\n"},
+		{"p=~/a:~/b:x~; echo $p", "/home/me/a:/home/me/b:x~\n"},
+		{"echo a:~/b ${unset:-~/c}", "a:~/b /home/me/c\n"},
+		{"HOME='/with space'; for d in ~; do echo \"[$d]\"; done", "[/with space]\n"},
+	}
+
+	for _, tt := range tests {
+		program, err := NewParser(NewLexer(tt.source)).Parse()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.source, err)
+		}
+
+		stdout, _ := os.CreateTemp(t.TempDir(), "stdout")
+		interpreter := New()
+		interpreter.Stdout = stdout
+		interpreter.env.Set("HOME", "/home/me")
+		interpreter.env.Set("PWD", "/work")
+		interpreter.env.Set("OLDPWD", "/old")
+		interpreter.Run(program)
+
+		stdout.Seek(0, io.SeekStart)
+		output, _ := io.ReadAll(stdout)
+		stdout.Close()
+
+		if string(output) != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestBraceExpansion(t *testing.T) {
+	tests := []struct {
+		source         string
+		posix          bool
+		expectedOutput string
+	}{
+		{"echo file.{c,h} {a,b}{1,2}", false, "file.c file.h a1 a2 b1 b2\n"},
+		{"echo {1..5} {3..1} {a..e..2} {1..10..3}", false, "1 2 3 4 5 3 2 1 a c e 1 4 7 10\n"},
+		{"echo {01..3} {-2..2..2} {Z..X}", false, "01 02 03 -2 0 2 Z Y X\n"},
+		{"echo a{,b}c {a,{b,c}d}e", false, "ac abc ae bde cde\n"},
+		{"echo {a} {} {a..} { a{b,c}", false, "{a} {} {a..} { ab ac\n"},
+		{"echo '{a,b}' \\{a,b} \"{\"a,b} {a,\"b c\"}", false, "{a,b} {a,b} {a,b} a b c\n"},
+		{"x=1,2; echo {$x} {$x,3}", false, "{1,2} 1,2 3\n"},
+		{"echo ~/{a,b}", false, "/home/me/a /home/me/b\n"},
+		{"for f in {1..3}; do echo $f; done", false, "1\n2\n3\n"},
+		{"echo {a,b} {1..3}", true, "{a,b} {1..3}\n"},
+	}
+
+	for _, tt := range tests {
+		program, err := NewParser(NewLexer(tt.source)).Parse()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.source, err)
+		}
+
+		stdout, _ := os.CreateTemp(t.TempDir(), "stdout")
+		interpreter := New()
+		interpreter.Stdout = stdout
+		interpreter.Options.Posix = tt.posix
+		interpreter.env.Set("HOME", "/home/me")
+		interpreter.Run(program)
+
+		stdout.Seek(0, io.SeekStart)
+		output, _ := io.ReadAll(stdout)
+		stdout.Close()
+
+		if string(output) != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"cd /; pwd; cd /usr; pwd; cd - >/dev/null; pwd", "/\n/usr\n/\n", 0},
+		{"cd /usr; echo $PWD $OLDPWD", "/usr " + mustGetwd(t) + "\n", 0},
+		{"cd /usr && ls -d ../usr", "../usr\n", 0},
+		{"cd /; pwd | tr / x", "x\n", 0},
+		{"(cd /usr); pwd | wc -l", "1\n", 0},
+		{"cd /nonexistent 2>/dev/null || echo failed", "failed\n", 0},
+		{"pwd >&-", "", 1},
+		{"x=1; export x; sh -c 'echo $x'", "1\n", 0},
+		{"readonly x=1; x=2 || echo failed; echo $x", "failed\n1\n", 0},
+		{"x=1; unset x; echo \"[$x]\"", "[]\n", 0},
+		{"f() { echo f; }; unset -f f; f", "", 127},
+		{"set a b c; echo $# $2; shift 2; echo $#", "3 b\n1\n", 0},
+		{"set -f; echo *; set +f", "*\n", 0},
+		{"set -o noclobber; set -o | grep noclobber", "noclobber       on\n", 0},
+		{"echo before; exit 3; echo after", "before\n", 3},
+		{"(exit 4); echo $?", "4\n", 0},
+		{"echo $(exit 5; echo no) $?", "5\n", 0},
+		{"exit 6 | true; echo after", "after\n", 0},
+		{"f() { exit 7; }; f; echo after", "", 7},
+		{"for x in a b; do exit; done; echo after", "", 0},
+		{"false; exit", "", 1},
+		{"if [ -d / ] && test -n x; then echo yes; fi", "yes\n", 0},
+		{"i=0; while [ $i -lt 3 ]; do i=$((i + 1)); done; echo $i", "3\n", 0},
+		{"[ a = b ]", "", 1},
+		{"[ 1 -eq ] 2>/dev/null", "", 2},
+		{"echo -n a; echo b >&2 2>/dev/null; printf '%s,' x y | tr , ' '", "ax y ", 0},
+		{"read a b <<EOF\none two three\nEOF\necho \"$b\"", "two three\n", 0},
+		{"printf 'a\\nb\\n' | while read x; do echo \"[$x]\"; done", "[a]\n[b]\n", 0},
+		{"echo x | read y; echo \"[$y]\"", "[]\n", 0},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestJobs(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"sleep 0.1 & wait $!; echo $?", "0\n", 0},
+		{"sleep 1 & kill $!; wait $!; echo $?", "143\n", 0},
+		{"(exit 3) & wait %1; echo $?", "3\n", 0},
+		{"(exit 3) & p=$!; wait $p; echo $?; [ $p -gt 0 ] && echo pid", "3\npid\n", 0},
+		{"sleep 3 & kill %1; wait %1; echo $?", "143\n", 0},
+		{"(sleep 1; echo no) & sleep 0.1; kill %1; wait %1; echo $?", "143\n", 0},
+		{"while true; do sleep 0.1; done & kill $!; wait $!; echo $?", "143\n", 0},
+		{"(trap 'echo caught' TERM; sleep 1; echo after) & sleep 0.1; kill %1; wait %1",
+			"caught\nafter\n", 0},
+		{"false & wait; echo $?; jobs", "0\n", 0},
+		{"echo a & wait %%", "a\n", 0},
+		{"wait %1 2>/dev/null", "", 127},
+		{"x=$(sleep 0.1 & echo $!); [ -n \"$x\" ] && echo pid", "pid\n", 0},
+		{"sleep 1 & jobs; kill $!; wait", "[1]+  Running                 sleep 1 &\n", 0},
+		{"f=$(mktemp); sleep 1 & jobs -p >$f; jobs -l >>$f; { read p; read l; } <$f; rm $f; " +
+			"[ \"$p $l\" = \"$! [1]+ $! Running                 sleep 1 &\" ] && echo pid; kill $!",
+			"pid\n", 0},
+		{"while :; do :; done & jobs -p >/dev/null; [ $! -gt 0 ] && kill $!; wait $!; echo $?",
+			"143\n", 0},
+		{"sleep 1 & p=$!; sleep 1 & jobs %-; jobs %sl 2>/dev/null || echo ambiguous; " +
+			"kill $p $!; wait", "[1]-  Running                 sleep 1 &\nambiguous\n", 0},
+		{"sleep 1 & kill -STOP $!; wait $!; echo $?; jobs; kill %1; wait",
+			"147\n[1]+  Stopped                 sleep 1\n", 0},
+		{"(sleep 0.1 & jobs; wait); jobs", "[1]+  Running                 sleep 0.1 &\n", 0},
+		{"fg 2>/dev/null", "", 1},
+		{"set -m; sleep 0.1 | cat; (exit 2); echo $?", "2\n", 0},
+		{"set -m; (true; true) && echo ok", "ok\n", 0},
+		{"set -m; (true; true) & wait $!; echo $?", "0\n", 0},
+		{"set -m; sleep 1 & p=$!; [ $(cut -d' ' -f5 /proc/$p/stat) = $p ] && echo group; " +
+			"kill %1; wait", "group\n", 0},
+		{"set -m; sh -c 'kill -STOP $$'; echo $?; jobs; kill %1; wait",
+			"147\n[1]+  Stopped                 sh -c 'kill -STOP $$'\n", 0},
+		{"set -m; sh -c 'kill -STOP $$; exit 4'; fg >/dev/null; echo $?; jobs", "4\n", 0},
+		{"set -m; sh -c 'kill -STOP $$; exit 5'; bg %sh >/dev/null; wait %1; echo $?",
+			"5\n", 0},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestDot(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vars.sh":   "x=1\nf() { echo f $1; }\n",
+		"return.sh": "echo in $#\nreturn 4\necho no\n",
+		"error.sh":  "echo a\nnothing-here\necho )\necho no\n",
+	}
+
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{". DIR/vars.sh; echo $x; f a", "1\nf a\n", 0},
+		{"set -- a; . DIR/return.sh b c; echo $? $# $1", "in 2\n4 1 a\n", 0},
+		{"g() { . DIR/return.sh; echo $?; }; g x", "in 1\n4\n", 0},
+		{". DIR/error.sh; echo $?", "a\n2\n", 0},
+		{"PATH=DIR; . vars.sh; echo $x", "1\n", 0},
+		{"cd DIR; . ./vars.sh; echo $x", "1\n", 0},
+		{". DIR/nothing.sh", "", 1},
+		{".", "", 2},
+		{": a > /dev/null; echo $?", "0\n", 0},
+	}
+
+	for _, tt := range tests {
+		source := strings.ReplaceAll(tt.source, "DIR", dir)
+		output, status := run(t, source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestTraps(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"(trap 'echo bye' EXIT; echo hi)", "hi\nbye\n", 0},
+		{"x=$(trap 'echo bye' EXIT; echo hi); echo $x", "hi bye\n", 0},
+		{"(trap 'echo $?' EXIT; exit 4); echo $?", "4\n4\n", 0},
+		{"trap 'echo err $?' ERR; false; echo next", "err 1\nnext\n", 0},
+		{"trap 'echo err' ERR; if false; then true; fi; false || true; while false; do true; done",
+			"", 0},
+		{"trap 'echo err' ERR; f() { false; false; }; f", "err\n", 1},
+		{"trap 'echo err' ERR; false | true; true | false", "err\n", 1},
+		{"trap 'echo debug' DEBUG; echo a; echo b", "debug\na\ndebug\nb\n", 0},
+		{"trap 'echo ret' RETURN; f() { return 3; }; f; echo $?", "ret\n3\n", 0},
+		{"trap 'echo usr1' USR1; kill -USR1 $$; sleep 0.1; echo after", "usr1\nafter\n", 0},
+		{"trap 'echo usr1' USR1; kill -USR1 $$; echo after", "usr1\nafter\n", 0},
+		{"trap 'echo usr2; exit 3' USR2; kill -USR2 $$ && echo no", "usr2\n", 3},
+		{"trap true ERR; (exit 5); echo $?", "5\n", 0},
+		{"trap 'echo usr2; exit 3' USR2; kill -USR2 $$; sleep 0.1; echo no", "usr2\n", 3},
+		{"trap '' USR1; sh -c 'kill -USR1 $$; echo alive'", "alive\n", 0},
+		{"trap 'echo x' INT; trap; trap - INT; trap", "trap -- 'echo x' INT\n", 0},
+		{"trap 'echo x' USR2; trap '' HUP; (trap); trap - HUP USR2",
+			"trap -- '' HUP\n", 0},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"kill -INT $$; sleep 0.1; echo no", "", 130},
+		{"set -m; sh -c 'kill -INT $$'; echo no", "", 130},
+		{"while true; do kill -INT $$; sleep 0.1; done; echo no", "", 130},
+		{"trap 'echo int' INT; kill -INT $$; sleep 0.1; echo yes", "int\nyes\n", 0},
+	}
+
+	for _, tt := range tests {
+		stdout, err := os.CreateTemp(t.TempDir(), "stdout")
+
+		if err != nil {
+			t.Fatalf("Error creating temporary files")
+		}
+
+		program, err := NewParser(NewLexer(tt.source)).Parse()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.source, err)
+		}
+
+		interpreter := New()
+		interpreter.Stdout = stdout
+		interpreter.SetInteractive()
+		status := interpreter.Run(program)
+		interpreter.ResetTrap("INT")
+
+		stdout.Seek(0, io.SeekStart)
+		output, _ := io.ReadAll(stdout)
+		stdout.Close()
+
+		if string(output) != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	tests := []struct {
+		source         string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{"set -e; echo a; false; echo b", "a\n", 1},
+		{"set -e; false || echo a; false && echo b; echo c", "a\nc\n", 0},
+		{"set -e; if false; then true; fi; while false; do true; done; echo a", "a\n", 0},
+		{"set -e; f() { false; echo a; }; f && echo b; f; echo c", "a\nb\n", 1},
+		{"set -e; (false; echo a); echo b", "", 1},
+		{"set -e; x=$(false); echo a", "", 1},
+		{"set -e; false | true; echo a", "a\n", 0},
+		{"set -e; (set +e; false; echo a); echo b", "a\nb\n", 0},
+		{"set -e; ! true; echo $?; ! false | false; echo $?", "1\n0\n", 0},
+		{"set -e; trap 'echo err' ERR; ! true; ! false; echo a", "a\n", 0},
+		{"set -e; trap 'echo err' ERR; false; echo a", "err\n", 1},
+		{"set -u; echo ${x-a} ${x:+b} ${x:=c} \"$@\" $*; echo $x", "a c\nc\n", 0},
+		{"set -u; echo $x; echo a", "", 1},
+		{"set -u; for i in $1; do echo a; done; echo b", "", 1},
+		{"set -u; (echo $x); echo $?", "1\n", 0},
+		{"set -u; echo ${#x}", "", 1},
+		{
+			"{ set -x; x=1 y='a b'; echo \"$y\" $x ''; } 2>&1",
+			"+ x=1 y='a b'\n+ echo 'a b' 1 ''\na b 1 \n",
+			0,
+		},
+		{"x=1; PS4='$x> '; { set -x; echo a; } 2>&1", "1> echo a\na\n", 0},
+		{"false | true; echo $?; set -o pipefail; false | true; echo $?", "0\n1\n", 0},
+		{"set -o pipefail; (exit 2) | (exit 3) | true; echo $? ${PIPESTATUS[@]}", "3 2 3 0\n", 0},
+		{"set -euo pipefail; echo $-; true | false | true; echo a", "eu\n", 1},
+		{"set -n; echo a; exit 3", "", 0},
+		{"set -C; echo $-; set +C -f; echo $-; set -o noexec; set +n", "C\nf\n", 0},
+	}
+
+	for _, tt := range tests {
+		output, status := run(t, tt.source)
+
+		if output != tt.expectedOutput {
+			t.Errorf("%s: Expected output %q, but got %q", tt.source, tt.expectedOutput, output)
+		}
+
+		if status != tt.expectedStatus {
+			t.Errorf("%s: Expected status %d, but got %d", tt.source, tt.expectedStatus, status)
+		}
+	}
+}
+
+func mustGetwd(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.Getwd()
+
+	if err != nil {
+		t.Fatalf("Error getting the working directory: %s", err)
+	}
+
+	return dir
+}
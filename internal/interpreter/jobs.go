@@ -0,0 +1,908 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/marcos-brito/arosh/internal/builtins"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+type jobState int
+
+const (
+	running jobState = iota
+	stopped
+	done
+)
+
+// Job is a pipeline or a list the shell runs as a unit: in the background,
+// or in the foreground with job control on. It's stopped and continued as
+// a whole.
+type Job struct {
+	table   *jobTable
+	id      int
+	command string
+	// control tells if the job has a process group of its own, pgid, which
+	// is the process id of its first process.
+	control    bool
+	pgid       int
+	foreground bool
+	processes  []*process
+	// finished tells if the commands of the job are done. Programs that
+	// were stopped may still be there.
+	finished bool
+	status   int
+	// state is the state the job was last seen in.
+	state jobState
+	// pid is what $! expands to: the process id of the first program of
+	// the job, or a made up one if it never starts any.
+	pid int
+	// signals are the signals sent to the job with kill, which the shells
+	// running its commands act on before the next one. killedBy is the
+	// one that stopped them.
+	signals  []syscall.Signal
+	killedBy syscall.Signal
+}
+
+// process is a program started by the shell.
+type process struct {
+	pid        int
+	stopped    bool
+	stopSignal syscall.Signal
+	done       bool
+	signaled   bool
+	status     int
+}
+
+// jobTable keeps the jobs of a shell. Subshells get a table of their own,
+// like they would in a shell that forks, so a job never waits for itself.
+// Everything in it is guarded by mu, and cond is signaled when a job or a
+// process changes state.
+type jobTable struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	jobs []*Job
+	// recent has the jobs from the one most recently started, stopped or
+	// continued. It tells which are the current and the previous jobs.
+	recent []*Job
+	// terminal is the file descriptor jobs in the foreground are given,
+	// -1 without job control. pgid is the process group of the shell,
+	// which gets it back after each job.
+	terminal int
+	pgid     int
+	// notify is called with the notifications in pending, once mu is
+	// unlocked.
+	notify  func(message string)
+	pending []string
+	// signals is how the shell wants its signals, which giveTerminal puts
+	// back.
+	signals *signals
+	// madePids is how many process ids were made up for jobs.
+	madePids int
+}
+
+// maxPid is above every process id Linux gives, the ones made up for jobs
+// come after it so they never clash with a program.
+const maxPid = 1 << 22
+
+func newJobTable() *jobTable {
+	table := &jobTable{terminal: -1}
+	table.cond = sync.NewCond(&table.mu)
+
+	return table
+}
+
+// StartJobControl lets the shell control the jobs it runs from terminal.
+// The shell is put in a process group of its own, in the foreground of the
+// terminal, and the monitor option is turned on. notify is called with what
+// to tell the user when a job stops or is done.
+func (i *Interpreter) StartJobControl(terminal *os.File, notify func(message string)) error {
+	pid := os.Getpid()
+
+	if unix.Getpgrp() != pid {
+		if err := unix.Setpgid(0, 0); err != nil {
+			return err
+		}
+	}
+
+	table := i.jobs
+	table.mu.Lock()
+	table.signals = i.signals
+	table.terminal = int(terminal.Fd())
+	table.pgid = pid
+	table.notify = notify
+	table.giveTerminal(pid)
+	table.mu.Unlock()
+
+	i.Options.Monitor = true
+
+	return nil
+}
+
+// foreground runs the commands of node as a job in the foreground and
+// waits until it's done or stopped. run gets the copy of the interpreter
+// the job runs in, in a goroutine of its own that goes on after the job is
+// stopped, since it can be continued.
+func (i *Interpreter) foreground(node Node, run func(shell *Interpreter)) {
+	job := i.jobs.add(i.source(node), true, true)
+	shell := *i
+	shell.job = job
+	shell.signals = nil
+
+	go func() {
+		run(&shell)
+		i.jobs.finish(job, shell.status)
+	}()
+
+	status, stopped := i.jobs.waitForeground(job)
+	i.status = status
+	i.pipeStatus = []int{status}
+
+	// A job killed by ctrl+c interrupts the shell too, as if it got SIGINT
+	// itself.
+	if i.interactive && i.signals != nil && i.jobs.killedBy(job, syscall.SIGINT) {
+		i.signals.raise(syscall.SIGINT)
+	}
+
+	if !stopped {
+		i.pipeStatus = shell.pipeStatus
+	}
+}
+
+// background runs node as a job in a subshell, without waiting for it.
+func (i *Interpreter) background(node Node) {
+	job := i.jobs.add(i.source(node), i.Options.Monitor, false)
+	shell := i.subshell()
+	shell.job = job
+
+	go func() {
+		shell.leave(shell.eval(node))
+		// Signals may come after the last command has started, they still
+		// stop the job before it's done.
+		shell.handleJobSignals()
+		shell.RunExitTrap()
+		i.jobs.finish(job, shell.status)
+	}()
+
+	i.lastJob = job
+	i.status = 0
+}
+
+// settleJob tells the job the shell runs commands for, if any, that it's
+// running something other than a program, so $! doesn't wait for one.
+func (i *Interpreter) settleJob() {
+	if i.job != nil {
+		i.job.table.settle(i.job)
+	}
+}
+
+// add puts a new job in the table. Its number is one more than the highest
+// one in use.
+func (t *jobTable) add(command string, control bool, foreground bool) *Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job := &Job{
+		table:      t,
+		id:         1,
+		command:    command,
+		control:    control,
+		foreground: foreground,
+	}
+
+	if len(t.jobs) > 0 {
+		job.id = t.jobs[len(t.jobs)-1].id + 1
+	}
+
+	t.jobs = append(t.jobs, job)
+	t.touch(job)
+
+	return job
+}
+
+// start starts a program for job, which is nil for programs that aren't
+// part of one, and watches it until it's done. The first program of a job
+// under job control makes its process group, and takes the terminal if the
+// job is in the foreground. The child takes it itself too when it can, so
+// it doesn't try to read from the terminal before it's its. A group is
+// gone once all of its programs are, the next program of the job makes a
+// new one then.
+func (t *jobTable) start(
+	job *Job,
+	path string,
+	args []string,
+	attr *os.ProcAttr,
+) (*process, error) {
+	t.mu.Lock()
+	defer t.unlock()
+
+	pgid := 0
+
+	if job != nil && job.control {
+		pgid = job.pgid
+		t.joinGroup(job, pgid, attr)
+	}
+
+	started, err := os.StartProcess(path, args, attr)
+
+	if pgid != 0 && errors.Is(err, syscall.EPERM) {
+		pgid = 0
+		t.joinGroup(job, pgid, attr)
+		started, err = os.StartProcess(path, args, attr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &process{pid: started.Pid}
+	started.Release()
+
+	if job != nil {
+		job.processes = append(job.processes, p)
+
+		if job.pid == 0 {
+			job.pid = p.pid
+		}
+
+		// The shells running the job may not have got to signals sent to
+		// it yet, the programs they start meanwhile get them too.
+		for _, sig := range job.signals {
+			unix.Kill(p.pid, sig)
+		}
+
+		if job.killedBy != 0 {
+			unix.Kill(p.pid, job.killedBy)
+		}
+
+		if job.control && pgid == 0 {
+			first := job.pgid == 0
+			job.pgid = p.pid
+
+			if first || job.foreground {
+				t.announce(job)
+			}
+		}
+
+		t.cond.Broadcast()
+	}
+
+	go t.watch(job, p)
+
+	return p, nil
+}
+
+// joinGroup sets up a program of job to join the process group pgid, or
+// to make one of its own when pgid is 0. A new group takes the terminal
+// if the job is in the foreground.
+func (t *jobTable) joinGroup(job *Job, pgid int, attr *os.ProcAttr) {
+	attr.Sys = &syscall.SysProcAttr{Setpgid: true, Pgid: pgid}
+
+	fd := terminalFd(attr.Files)
+
+	if job.foreground && pgid == 0 && t.terminal != -1 && fd != -1 {
+		attr.Sys.Foreground = true
+		attr.Sys.Ctty = fd
+	}
+}
+
+// announce tells the process group of a job that just got one. Jobs in the
+// foreground are given the terminal instead.
+func (t *jobTable) announce(job *Job) {
+	if t.terminal == -1 {
+		return
+	}
+
+	if job.foreground {
+		t.giveTerminal(job.pgid)
+		return
+	}
+
+	if t.notify != nil {
+		t.pending = append(t.pending, fmt.Sprintf("[%d] %d", job.id, job.pgid))
+	}
+}
+
+// terminalFd returns which of the standard streams of a child is a
+// terminal, or -1 if none is.
+func terminalFd(files []*os.File) int {
+	for fd, file := range files[:min(3, len(files))] {
+		if file != nil && term.IsTerminal(int(file.Fd())) {
+			return fd
+		}
+	}
+
+	return -1
+}
+
+// watch waits for p to stop, continue or end until it's done, updating
+// its job.
+func (t *jobTable) watch(job *Job, p *process) {
+	for !p.done {
+		var status syscall.WaitStatus
+
+		_, err := syscall.Wait4(p.pid, &status, syscall.WUNTRACED|syscall.WCONTINUED, nil)
+
+		if err == syscall.EINTR {
+			continue
+		}
+
+		t.mu.Lock()
+
+		switch {
+		case err != nil:
+			p.done, p.status = true, 1
+		case status.Stopped():
+			p.stopped, p.stopSignal = true, status.StopSignal()
+		case status.Continued():
+			p.stopped = false
+		case status.Signaled():
+			p.done, p.signaled, p.status = true, true, 128+int(status.Signal())
+		default:
+			p.done, p.status = true, status.ExitStatus()
+		}
+
+		t.update(job)
+		t.cond.Broadcast()
+		t.unlock()
+	}
+}
+
+// wait waits until p is done, returning its exit status.
+func (t *jobTable) wait(p *process) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for !p.done {
+		t.cond.Wait()
+	}
+
+	return p.status
+}
+
+// finish records that the commands of job are done.
+func (t *jobTable) finish(job *Job, status int) {
+	t.mu.Lock()
+	defer t.unlock()
+
+	job.finished, job.status = true, status
+	t.update(job)
+	t.cond.Broadcast()
+}
+
+// waitForeground waits until a job in the foreground is done or stopped,
+// then the shell takes the terminal back. Jobs that are done are removed.
+// The status of a stopped job is 128 plus the signal that stopped it.
+func (t *jobTable) waitForeground(job *Job) (int, bool) {
+	t.mu.Lock()
+	defer t.unlock()
+
+	for job.current() == running {
+		t.cond.Wait()
+	}
+
+	if t.terminal != -1 {
+		t.giveTerminal(t.pgid)
+	}
+
+	t.update(job)
+
+	if job.state == stopped {
+		job.foreground = false
+		return job.stoppedStatus(), true
+	}
+
+	t.remove(job)
+
+	return job.status, false
+}
+
+// update notices when job changes state. Jobs that stop are reported, and
+// so are the ones in the background when they're done.
+func (t *jobTable) update(job *Job) {
+	if job == nil || job.current() == job.state {
+		return
+	}
+
+	job.state = job.current()
+
+	switch {
+	case job.state == stopped:
+		t.touch(job)
+		t.report(job)
+	case job.state == done && !job.foreground:
+		t.report(job)
+	}
+}
+
+// report queues a notification about job. Without anyone to tell, jobs
+// that are done stay in the table, so they can be waited for.
+func (t *jobTable) report(job *Job) {
+	if t.notify == nil {
+		return
+	}
+
+	t.pending = append(t.pending, t.line(job))
+
+	if job.state == done {
+		t.remove(job)
+	}
+}
+
+// unlock unlocks mu and then sends the notifications that are pending.
+func (t *jobTable) unlock() {
+	pending := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+
+	for _, message := range pending {
+		t.notify(message)
+	}
+}
+
+// line is how job is shown to the user.
+func (t *jobTable) line(job *Job) string {
+	return builtins.JobLine(job.id, t.marker(job), job.describe(), job.command)
+}
+
+// giveTerminal puts pgid in the foreground of the terminal. SIGTTOU is
+// ignored meanwhile, since the shell isn't in the foreground when it takes
+// the terminal back. It's done with mu locked, so programs, which are only
+// started with it locked, don't inherit it ignored.
+func (t *jobTable) giveTerminal(pgid int) {
+	signal.Ignore(syscall.SIGTTOU)
+	unix.IoctlSetPointerInt(t.terminal, unix.TIOCSPGRP, pgid)
+
+	if t.signals != nil {
+		t.signals.restore(syscall.SIGTTOU)
+		return
+	}
+
+	signal.Reset(syscall.SIGTTOU)
+}
+
+// signalForeground sends sig to the job in the foreground, if it has a
+// process group.
+func (t *jobTable) signalForeground(sig syscall.Signal) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, job := range t.jobs {
+		if job.foreground && job.control && job.pgid != 0 {
+			unix.Kill(-job.pgid, sig)
+		}
+	}
+}
+
+// killedBy tells if a process of job was killed by sig.
+func (t *jobTable) killedBy(job *Job, sig syscall.Signal) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, p := range job.processes {
+		if p.signaled && p.status == 128+int(sig) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// resume continues the processes of job that were stopped.
+func (t *jobTable) resume(job *Job) {
+	for _, p := range job.processes {
+		p.stopped = false
+
+		if !job.control && !p.done {
+			unix.Kill(p.pid, syscall.SIGCONT)
+		}
+	}
+
+	if job.control && job.pgid != 0 {
+		unix.Kill(-job.pgid, syscall.SIGCONT)
+	}
+
+	t.touch(job)
+	t.update(job)
+	t.cond.Broadcast()
+}
+
+// touch makes job the most recent one.
+func (t *jobTable) touch(job *Job) {
+	t.recent = slices.DeleteFunc(t.recent, func(other *Job) bool { return other == job })
+	t.recent = append([]*Job{job}, t.recent...)
+}
+
+func (t *jobTable) remove(job *Job) {
+	isJob := func(other *Job) bool { return other == job }
+	t.jobs = slices.DeleteFunc(t.jobs, isJob)
+	t.recent = slices.DeleteFunc(t.recent, isJob)
+}
+
+// marked returns the current and the previous jobs. Jobs that are stopped
+// come first, then the most recent ones.
+func (t *jobTable) marked() (*Job, *Job) {
+	ordered := slices.Clone(t.recent)
+	slices.SortStableFunc(ordered, func(a, b *Job) int {
+		return int(a.current()) - int(b.current())
+	})
+
+	switch len(ordered) {
+	case 0:
+		return nil, nil
+	case 1:
+		return ordered[0], nil
+	}
+
+	return ordered[0], ordered[1]
+}
+
+func (t *jobTable) marker(job *Job) string {
+	switch current, previous := t.marked(); job {
+	case current:
+		return "+"
+	case previous:
+		return "-"
+	}
+
+	return " "
+}
+
+// find returns the job spec refers to: %n is job n, %+, %% and % are the
+// current job, %- is the previous one, %name is the job whose command
+// starts with name and %?name the one whose command contains it.
+func (t *jobTable) find(spec string) (*Job, error) {
+	current, previous := t.marked()
+
+	switch spec {
+	case "%", "%%", "%+":
+		if current == nil {
+			return nil, errors.New("current: no such job")
+		}
+
+		return current, nil
+	case "%-":
+		if previous == nil {
+			return nil, errors.New("previous: no such job")
+		}
+
+		return previous, nil
+	}
+
+	name := strings.TrimPrefix(spec, "%")
+
+	if n, err := strconv.Atoi(name); err == nil {
+		for _, job := range t.jobs {
+			if job.id == n {
+				return job, nil
+			}
+		}
+
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	matches := []*Job{}
+
+	for _, job := range t.jobs {
+		if contains, ok := strings.CutPrefix(name, "?"); ok &&
+			strings.Contains(job.command, contains) ||
+			!ok && strings.HasPrefix(job.command, name) {
+			matches = append(matches, job)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("%s: no such job", spec)
+	case 1:
+		return matches[0], nil
+	}
+
+	return nil, fmt.Errorf("%s: ambiguous job spec", spec)
+}
+
+// pid returns the process id of job, which is what $! expands to. It's
+// the one of the first program the job starts, or one made up if it runs
+// something else first, which wait and kill take like any other. Since the
+// job runs in a goroutine that may not have got there yet, it waits.
+func (t *jobTable) pid(job *Job) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.waitPid(job)
+
+	return job.pid
+}
+
+// waitPid waits until job has a process id. Jobs that end without one get
+// one made up. It must be called with mu locked.
+func (t *jobTable) waitPid(job *Job) {
+	for job.pid == 0 && !job.finished {
+		t.cond.Wait()
+	}
+
+	if job.pid == 0 {
+		t.makePid(job)
+	}
+}
+
+// settle makes up a process id for job if it has none yet. It's called when
+// the job runs something other than a program, which has none to give.
+func (t *jobTable) settle(job *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if job.pid == 0 {
+		t.makePid(job)
+		t.cond.Broadcast()
+	}
+}
+
+func (t *jobTable) makePid(job *Job) {
+	t.madePids++
+	job.pid = maxPid + t.madePids
+}
+
+// takeSignals returns the signals sent to job since they were last taken,
+// and the one that stopped it if any did.
+func (t *jobTable) takeSignals(job *Job) ([]syscall.Signal, syscall.Signal) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	signals := job.signals
+	job.signals = nil
+
+	return signals, job.killedBy
+}
+
+// killed records that sig stopped the commands of job.
+func (t *jobTable) killed(job *Job, sig syscall.Signal) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if job.killedBy == 0 {
+		job.killedBy = sig
+	}
+}
+
+// current works out the state of the job from its processes.
+func (j *Job) current() jobState {
+	anyRunning, anyStopped := false, false
+
+	for _, p := range j.processes {
+		switch {
+		case p.done:
+		case p.stopped:
+			anyStopped = true
+		default:
+			anyRunning = true
+		}
+	}
+
+	switch {
+	case anyStopped && !anyRunning:
+		return stopped
+	case j.finished && !anyRunning:
+		return done
+	}
+
+	return running
+}
+
+// describe tells the state of the job in words: whether it's running or
+// stopped, or how it ended.
+func (j *Job) describe() string {
+	switch j.current() {
+	case running:
+		return "Running"
+	case stopped:
+		return "Stopped"
+	}
+
+	killed := j.killedBy != 0 && j.status == 128+int(j.killedBy)
+
+	for _, p := range j.processes {
+		killed = killed || p.signaled && p.status == j.status
+	}
+
+	if killed {
+		signal := syscall.Signal(j.status - 128).String()
+		return strings.ToUpper(signal[:1]) + signal[1:]
+	}
+
+	if j.status != 0 {
+		return fmt.Sprintf("Done(%d)", j.status)
+	}
+
+	return "Done"
+}
+
+func (j *Job) stoppedStatus() int {
+	for _, p := range j.processes {
+		if p.stopped {
+			return 128 + int(p.stopSignal)
+		}
+	}
+
+	return 128 + int(syscall.SIGTSTP)
+}
+
+// These make the table and its jobs what the job control builtins work
+// with.
+
+func (t *jobTable) List() []builtins.Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	jobs := []builtins.Job{}
+
+	for _, job := range t.jobs {
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+func (t *jobTable) Find(spec string) (builtins.Job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	job, err := t.find(spec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (t *jobTable) Marker(job builtins.Job) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.marker(job.(*Job))
+}
+
+func (t *jobTable) Foreground(job builtins.Job) int {
+	t.mu.Lock()
+	j := job.(*Job)
+	j.foreground = true
+
+	if t.terminal != -1 && j.control && j.pgid != 0 {
+		t.giveTerminal(j.pgid)
+	}
+
+	t.resume(j)
+	t.unlock()
+
+	status, _ := t.waitForeground(j)
+
+	return status
+}
+
+func (t *jobTable) Background(job builtins.Job) {
+	t.mu.Lock()
+	defer t.unlock()
+
+	job.(*Job).foreground = false
+	t.resume(job.(*Job))
+}
+
+// Wait waits until job is done or stopped, returning its status.
+func (t *jobTable) Wait(job builtins.Job) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	j := job.(*Job)
+
+	for j.current() == running {
+		t.cond.Wait()
+	}
+
+	if j.current() == stopped {
+		return j.stoppedStatus()
+	}
+
+	return j.status
+}
+
+// Signal sends sig to the programs of job, to its process group if it has
+// one, and to the shells running its commands. A stopped job is continued,
+// so it can handle it.
+func (t *jobTable) Signal(job builtins.Job, sig syscall.Signal) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	j := job.(*Job)
+	pids := []int{}
+
+	if j.control && j.pgid != 0 {
+		pids = append(pids, -j.pgid)
+	} else {
+		for _, p := range j.processes {
+			if !p.done {
+				pids = append(pids, p.pid)
+			}
+		}
+	}
+
+	if sig != 0 && !j.finished {
+		j.signals = append(j.signals, sig)
+	}
+
+	for _, pid := range pids {
+		if err := unix.Kill(pid, sig); err != nil && err != unix.ESRCH {
+			return err
+		}
+
+		if j.current() == stopped && sig != syscall.SIGCONT {
+			unix.Kill(pid, syscall.SIGCONT)
+		}
+	}
+
+	return nil
+}
+
+func (t *jobTable) Remove(job builtins.Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.remove(job.(*Job))
+}
+
+func (j *Job) ID() int {
+	return j.id
+}
+
+func (j *Job) Command() string {
+	return j.command
+}
+
+// Pgid and Pids wait for the job to have a process id, like $! does, so
+// they don't miss the program it's about to start.
+func (j *Job) Pgid() int {
+	j.table.mu.Lock()
+	defer j.table.mu.Unlock()
+
+	if !j.control {
+		return 0
+	}
+
+	j.table.waitPid(j)
+
+	return j.pgid
+}
+
+func (j *Job) Pids() []int {
+	j.table.mu.Lock()
+	defer j.table.mu.Unlock()
+
+	j.table.waitPid(j)
+	pids := []int{}
+
+	if j.pid > maxPid {
+		pids = append(pids, j.pid)
+	}
+
+	for _, p := range j.processes {
+		pids = append(pids, p.pid)
+	}
+
+	return pids
+}
+
+func (j *Job) State() string {
+	j.table.mu.Lock()
+	defer j.table.mu.Unlock()
+
+	return j.describe()
+}
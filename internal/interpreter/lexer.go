@@ -1,43 +1,59 @@
 package interpreter
 
 import (
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
-	source      string
+	source string
+	// position is the byte offset of currentChar, which is width
+	// bytes long.
 	position    int
+	width       int
+	runeIndex   int
 	line        int
+	column      int
 	currentChar rune
+	hereDocs    []*HereDoc
+	unclosed    bool
+	// errors are the syntax errors found in command substitutions, which
+	// are parsed while they are read.
+	errors ParseErrors
 }
 
 func NewLexer(source string) *Lexer {
-	return &Lexer{
-		source:      source,
-		position:    0,
-		line:        0,
-		currentChar: rune(source[0]),
-	}
+	lexer := &Lexer{source: source}
+	lexer.decode()
+
+	return lexer
 }
 
 func (l *Lexer) NextToken() Token {
 	var token Token
 
 	for {
-		if !unicode.IsSpace(l.currentChar) && l.currentChar != '\n' && l.currentChar != '\r' {
+		if l.currentChar == '\\' && l.peek(1) == '\n' {
+			l.consume()
+			l.unclosed = l.peek(1) == 0
+		} else if l.currentChar == '#' {
+			l.skipComment()
+		} else if !unicode.IsSpace(l.currentChar) || l.currentChar == '\n' {
 			break
 		}
 
 		l.consume()
 	}
 
+	start := l.Position()
+
 	switch l.currentChar {
 	case 0:
 		token = Token{T: EOF, Lexeme: EOF}
 	case '\n':
-		l.line++
-	case '\r':
-		l.line++
+		token = Token{T: NEWLINE, Lexeme: "\n"}
 	case '&':
 		token = l.readAmpersand()
 	case ';':
@@ -52,16 +68,22 @@ func (l *Lexer) NextToken() Token {
 	case '<':
 		token = l.readInputRedirection()
 
-	default:
-		if l.isNumeric(l.currentChar) {
-			token = l.readNumber()
-			break
-		}
+	case '(':
+		token = Token{T: LPAREN, Lexeme: LPAREN}
 
+	case ')':
+		token = Token{T: RPAREN, Lexeme: RPAREN}
+
+	default:
 		token = l.readWord()
 	}
 
 	l.consume()
+	token.Span = Span{Start: start, End: l.Position()}
+
+	if token.T == NEWLINE {
+		l.readHereDocs()
+	}
 
 	return token
 }
@@ -82,25 +104,73 @@ func (l *Lexer) Tokenize() []Token {
 	return tokens
 }
 
+// Position returns where the current character is in the source.
+func (l *Lexer) Position() Position {
+	return Position{Offset: l.position, Rune: l.runeIndex, Line: l.line, Column: l.column}
+}
+
 func (l *Lexer) consume() {
-	if l.position >= len(l.source)-1 {
+	if l.position >= len(l.source) {
+		return
+	}
+
+	if l.currentChar == '\n' {
+		l.line++
+		l.column = 0
+	} else {
+		l.column++
+	}
+
+	l.position += l.width
+	l.runeIndex++
+	l.decode()
+}
+
+// decode reads the character at the current position. The source is
+// treated as UTF-8, invalid bytes are read one by one as
+// utf8.RuneError.
+func (l *Lexer) decode() {
+	if l.position >= len(l.source) {
 		l.currentChar = 0
+		l.width = 0
 		return
 	}
 
-	l.position++
-	l.currentChar = rune(l.source[l.position])
+	l.currentChar, l.width = utf8.DecodeRuneInString(l.source[l.position:])
+}
+
+// seek moves the lexer back to a position it has already been at.
+func (l *Lexer) seek(position Position) {
+	l.position = position.Offset
+	l.runeIndex = position.Rune
+	l.line = position.Line
+	l.column = position.Column
+	l.decode()
 }
 
 func (l *Lexer) peek(at int) rune {
-	if l.position+at > len(l.source)-1 {
-		return rune(0)
+	offset := l.position
+
+	for ; at > 0 && offset < len(l.source); at-- {
+		_, width := utf8.DecodeRuneInString(l.source[offset:])
+		offset += width
+	}
+
+	if offset >= len(l.source) {
+		return 0
 	}
 
-	return rune(l.source[l.position+at])
+	char, _ := utf8.DecodeRuneInString(l.source[offset:])
+
+	return char
 }
 
 func (l *Lexer) readSemiColon() Token {
+	if l.peek(1) == ';' {
+		l.consume()
+		return Token{T: DSEMI, Lexeme: DSEMI}
+	}
+
 	return Token{T: SEMI, Lexeme: SEMI}
 }
 
@@ -170,48 +240,636 @@ func (l *Lexer) readInputRedirection() Token {
 	}
 }
 
-func (l *Lexer) readNumber() Token {
-	if l.isRedirection(l.peek(1)) {
-		return Token{T: IO_NUMBER, Lexeme: string(l.currentChar)}
-	}
+// readWord reads a word made of unquoted, quoted and escaped parts. It
+// stops at the first unquoted metacharacter. A single digit right before
+// a redirection operator is an IO_NUMBER instead.
+func (l *Lexer) readWord() Token {
+	start := l.position
+	parts := []WordPart{}
+	literal := ""
 
-	lexeme := ""
 	for {
-		lexeme += string(l.currentChar)
+		if strings.ContainsRune("'\"\\`", l.currentChar) {
+			if literal != "" {
+				parts = append(parts, &Literal{value: literal})
+				literal = ""
+			}
+		}
+
+		switch l.currentChar {
+		case '\'':
+			parts = append(parts, l.readSingleQuoted())
+		case '"':
+			parts = append(parts, l.readDoubleQuoted())
+		case '\\':
+			if part := l.readEscaped(); part != nil {
+				parts = append(parts, part)
+			}
+		case '$':
+			parts, literal = l.readDollar(parts, literal, false)
+		case '`':
+			parts = append(parts, l.readBackquoted(false))
+		default:
+			literal += string(l.currentChar)
+		}
 
-		if !l.isNumeric(l.peek(1)) {
+		if l.isMetaCharacter(l.peek(1)) || l.currentChar == 0 {
 			break
 		}
 
 		l.consume()
 	}
 
-	return Token{T: WORD, Lexeme: lexeme}
+	if literal != "" {
+		parts = append(parts, &Literal{value: literal})
+	}
+
+	lexeme := l.source[start : l.position+l.width]
+
+	if len(lexeme) == 1 && unicode.IsDigit(l.currentChar) && l.isRedirection(l.peek(1)) {
+		return Token{T: IO_NUMBER, Lexeme: lexeme}
+	}
+
+	return Token{T: WORD, Lexeme: lexeme, Parts: parts}
 }
 
-func (l *Lexer) readWord() Token {
-	lexeme := ""
-	for {
-		lexeme += string(l.currentChar)
+// readSingleQuoted reads everything up to the closing quote. Nothing is
+// special inside single quotes.
+func (l *Lexer) readSingleQuoted() WordPart {
+	value := ""
+
+	for l.consume(); l.currentChar != '\''; l.consume() {
+		if l.currentChar == 0 {
+			l.unclosed = true
+			break
+		}
+
+		value += string(l.currentChar)
+	}
 
-		if !l.isWord(l.peek(1)) {
+	return &SingleQuoted{value: value}
+}
+
+// readDoubleQuoted reads everything up to the closing quote. Backslashes
+// only escape "$", "`", "\"", "\\" and newlines here, otherwise they
+// are kept as they are.
+func (l *Lexer) readDoubleQuoted() WordPart {
+	parts := []WordPart{}
+	literal := ""
+
+	for l.consume(); l.currentChar != '"'; l.consume() {
+		if l.currentChar == 0 {
+			l.unclosed = true
 			break
 		}
 
+		if l.currentChar == '\\' && strings.ContainsRune("$`\"\\\n", l.peek(1)) {
+			if literal != "" {
+				parts = append(parts, &Literal{value: literal})
+				literal = ""
+			}
+
+			if part := l.readEscaped(); part != nil {
+				parts = append(parts, part)
+			}
+
+			continue
+		}
+
+		if l.currentChar == '$' {
+			parts, literal = l.readDollar(parts, literal, true)
+			continue
+		}
+
+		if l.currentChar == '`' {
+			if literal != "" {
+				parts = append(parts, &Literal{value: literal})
+				literal = ""
+			}
+
+			parts = append(parts, l.readBackquoted(true))
+			continue
+		}
+
+		literal += string(l.currentChar)
+	}
+
+	if literal != "" {
+		parts = append(parts, &Literal{value: literal})
+	}
+
+	return &DoubleQuoted{parts: parts}
+}
+
+// readDollar reads an expansion starting at a "$", adding it to parts after
+// the literal read so far. A "$" that doesn't start an expansion is just
+// part of the literal. quoted tells if it's inside double quotes.
+func (l *Lexer) readDollar(parts []WordPart, literal string, quoted bool) ([]WordPart, string) {
+	var part WordPart
+
+	switch next := l.peek(1); {
+	case next == '(' && l.peek(2) == '(':
+		part = l.readArithmetic()
+	case next == '(':
+		l.consume()
+		part = l.readCommandSubstitution()
+	case next == '{':
 		l.consume()
+		part = l.readBracedParameter(quoted)
+	case isNameStart(next) || isDigit(next) || isSpecialParameter(next):
+		part = &Parameter{name: l.readParameterName()}
+	default:
+		return parts, literal + "$"
+	}
+
+	if literal != "" {
+		parts = append(parts, &Literal{value: literal})
 	}
 
-	return Token{t: WORD, lexeme: lexeme}
+	return append(parts, part), ""
 }
 
-func (l *Lexer) isWord(c rune) bool {
-	return c == '_' || unicode.IsNumber(c) || unicode.IsLetter(c)
+// readParameterName reads the name after a "$" or "${". Outside braces,
+// only the first digit of a positional parameter is part of the name.
+func (l *Lexer) readParameterName() string {
+	next := l.peek(1)
+
+	if isDigit(next) || isSpecialParameter(next) {
+		l.consume()
+		return string(l.currentChar)
+	}
+
+	name := ""
+
+	for isNameChar(l.peek(1)) {
+		l.consume()
+		name += string(l.currentChar)
+	}
+
+	return name
 }
 
-func (l *Lexer) isRedirection(c rune) bool {
-	return c == '>' || c == '<'
+// readBracedParameter reads from "{" up to the matching "}". Anything that
+// isn't a valid expansion leaves the parameter without a name.
+func (l *Lexer) readBracedParameter(quoted bool) WordPart {
+	parameter := &Parameter{braced: true}
+	invalid := false
+
+	// ${#} is the number of positional parameters, ${#name} the length of
+	// name.
+	if l.peek(1) == '#' && l.peek(2) != '}' && isParameterStart(l.peek(2)) {
+		l.consume()
+		parameter.length = true
+	}
+
+	if isDigit(l.peek(1)) {
+		for isDigit(l.peek(1)) {
+			l.consume()
+			parameter.name += string(l.currentChar)
+		}
+	} else if isParameterStart(l.peek(1)) {
+		parameter.name = l.readParameterName()
+	}
+
+	if isName(parameter.name) && l.peek(1) == '[' {
+		parameter.index, invalid = l.readSubscript()
+	}
+
+	if l.peek(1) == '}' && !invalid {
+		l.consume()
+		return parameter
+	}
+
+	switch next := l.peek(1); {
+	case next == '}':
+	case next == ':' && strings.ContainsRune("-=?+", l.peek(2)):
+		l.consume()
+		l.consume()
+		parameter.operator = ":" + string(l.currentChar)
+	case strings.ContainsRune("-=?+", next):
+		l.consume()
+		parameter.operator = string(l.currentChar)
+	case next == '#' || next == '%':
+		l.consume()
+		parameter.operator = string(l.currentChar)
+
+		if l.peek(1) == next {
+			l.consume()
+			parameter.operator += string(l.currentChar)
+		}
+	default:
+		invalid = true
+	}
+
+	parameter.word = l.readParameterWord(quoted)
+
+	// What was read is kept as it was written, for the error message.
+	if invalid || parameter.length && parameter.operator != "" {
+		written := parameter.name
+
+		if parameter.index != "" {
+			written += "[" + parameter.index + "]"
+		}
+
+		written += parameter.operator
+
+		if parameter.length {
+			written = "#" + written
+		}
+
+		parts := append([]WordPart{&Literal{value: written}}, parameter.word.parts...)
+
+		return &Parameter{braced: true, word: &Word{parts: parts}}
+	}
+
+	return parameter
+}
+
+// readSubscript reads the "[index]" after the name of a parameter. Only
+// numbers, "@" and "*" are valid.
+func (l *Lexer) readSubscript() (string, bool) {
+	index := ""
+
+	for l.consume(); l.peek(1) != ']' && l.peek(1) != '}' && l.peek(1) != 0; {
+		l.consume()
+		index += string(l.currentChar)
+	}
+
+	if l.peek(1) != ']' {
+		return index, true
+	}
+
+	l.consume()
+	_, err := strconv.Atoi(index)
+
+	return index, index != "@" && index != "*" && (err != nil || index[0] == '-')
+}
+
+// readParameterWord reads the word after the operator of a parameter
+// expansion, up to the closing "}". Blanks don't end it.
+func (l *Lexer) readParameterWord(quoted bool) *Word {
+	parts := []WordPart{}
+	literal := ""
+
+	for l.consume(); l.currentChar != '}'; l.consume() {
+		if l.currentChar == 0 {
+			l.unclosed = true
+			break
+		}
+
+		if strings.ContainsRune("'\"\\`", l.currentChar) && literal != "" {
+			parts = append(parts, &Literal{value: literal})
+			literal = ""
+		}
+
+		switch {
+		case l.currentChar == '\'' && !quoted:
+			parts = append(parts, l.readSingleQuoted())
+		case l.currentChar == '"':
+			parts = append(parts, l.readDoubleQuoted())
+		case l.currentChar == '\\':
+			if part := l.readEscaped(); part != nil {
+				parts = append(parts, part)
+			}
+		case l.currentChar == '$':
+			parts, literal = l.readDollar(parts, literal, quoted)
+		case l.currentChar == '`':
+			parts = append(parts, l.readBackquoted(quoted))
+		default:
+			literal += string(l.currentChar)
+		}
+	}
+
+	if literal != "" {
+		parts = append(parts, &Literal{value: literal})
+	}
+
+	return &Word{parts: parts}
 }
 
-func (l *Lexer) isNumeric(c rune) bool {
-	return unicode.IsNumber(c)
+// readCommandSubstitution reads from "(" up to the matching ")". The
+// commands inside are parsed right away, since only the parser knows which
+// ")" closes them: the one after a case pattern doesn't.
+func (l *Lexer) readCommandSubstitution() WordPart {
+	l.consume()
+
+	start := l.position
+	parser := NewParser(l)
+	program, err := parser.program(RPAREN)
+
+	if err != nil {
+		program = &Program{}
+	}
+
+	l.errors = append(l.errors, parser.errors...)
+
+	if !parser.match(RPAREN) {
+		l.unclosed = true
+		return &CommandSubstitution{program: program, source: l.source[start:]}
+	}
+
+	// The parser already read past the ")", the word goes on from there.
+	l.seek(parser.current.Span.Start)
+
+	return &CommandSubstitution{program: program, source: l.source[start:l.position]}
+}
+
+// readArithmetic reads from "$((" up to the matching "))". Something like
+// $((cd dir) && ls) is a command substitution starting with a subshell
+// instead, when the parentheses don't close that way the lexer goes back and
+// reads it as one.
+func (l *Lexer) readArithmetic() WordPart {
+	start, unclosed, recorded := l.Position(), l.unclosed, len(l.errors)
+	parts := []WordPart{}
+	literal := ""
+	depth := 0
+
+	l.consume()
+	l.consume()
+
+	for l.consume(); ; l.consume() {
+		if strings.ContainsRune("\"\\`", l.currentChar) && literal != "" {
+			parts = append(parts, &Literal{value: literal})
+			literal = ""
+		}
+
+		switch {
+		case l.currentChar == 0:
+			l.unclosed = true
+		case l.currentChar == ')' && depth == 0 && l.peek(1) != ')':
+			l.seek(start)
+			l.consume()
+			l.unclosed, l.errors = unclosed, l.errors[:recorded]
+
+			return l.readCommandSubstitution()
+		case l.currentChar == ')' && depth == 0:
+			l.consume()
+		case l.currentChar == '"':
+			parts = append(parts, l.readDoubleQuoted())
+			continue
+		case l.currentChar == '\\' && strings.ContainsRune("$`\"\\\n", l.peek(1)):
+			if part := l.readEscaped(); part != nil {
+				parts = append(parts, part)
+			}
+
+			continue
+		case l.currentChar == '$':
+			parts, literal = l.readDollar(parts, literal, true)
+			continue
+		case l.currentChar == '`':
+			parts = append(parts, l.readBackquoted(true))
+			continue
+		default:
+			if l.currentChar == '(' {
+				depth++
+			} else if l.currentChar == ')' {
+				depth--
+			}
+
+			literal += string(l.currentChar)
+			continue
+		}
+
+		break
+	}
+
+	if literal != "" {
+		parts = append(parts, &Literal{value: literal})
+	}
+
+	return &Arithmetic{parts: parts}
+}
+
+// readBackquoted reads the old form of command substitution, up to the
+// closing backquote. Backslashes only escape "$", "`" and "\" here, and
+// "\"" too inside double quotes. The text left is parsed on its own.
+func (l *Lexer) readBackquoted(quoted bool) WordPart {
+	escapes := "$`\\"
+
+	if quoted {
+		escapes += "\""
+	}
+
+	l.consume()
+
+	start := l.position
+	source := ""
+	// positions has where each character of the text inside is in the
+	// source, and then where the text ends.
+	positions := []Position{}
+
+	for ; l.currentChar != '`'; l.consume() {
+		if l.currentChar == 0 {
+			l.unclosed = true
+			break
+		}
+
+		if l.currentChar == '\\' && strings.ContainsRune(escapes, l.peek(1)) {
+			l.consume()
+		}
+
+		source += string(l.currentChar)
+		positions = append(positions, l.Position())
+	}
+
+	positions = append(positions, l.Position())
+
+	parser := NewParser(NewLexer(source))
+	program, err := parser.program()
+
+	if err != nil {
+		program = &Program{}
+	}
+
+	for _, err := range parser.syntaxErrors() {
+		err.Span.Start = positions[err.Span.Start.Rune]
+		err.Span.End = positions[err.Span.End.Rune]
+		err.Found.Span = err.Span
+		err.source = l.source
+
+		// The end of the text inside is the closing backquote, so more
+		// input can't fix it.
+		if err.Found.T == EOF && l.currentChar == '`' {
+			err.Found = Token{T: "`", Lexeme: "`", Span: err.Span}
+		}
+
+		l.errors = append(l.errors, err)
+	}
+
+	return &CommandSubstitution{
+		program:    program,
+		source:     l.source[start:l.position],
+		backquoted: true,
+	}
+}
+
+// readEscaped reads the character after a backslash. A backslash followed
+// by a newline is a line continuation, both are removed and nil is
+// returned. One at the end of the source leaves it unclosed, the line it
+// continues is still to come.
+func (l *Lexer) readEscaped() WordPart {
+	l.consume()
+
+	switch l.currentChar {
+	case 0:
+		l.unclosed = true
+		return nil
+	case '\n':
+		l.unclosed = l.peek(1) == 0
+		return nil
+	}
+
+	return &Escaped{value: string(l.currentChar)}
+}
+
+// skipComment moves the lexer to the last character before the next
+// newline.
+func (l *Lexer) skipComment() {
+	for l.peek(1) != '\n' && l.peek(1) != 0 {
+		l.consume()
+	}
+}
+
+// addHereDoc queues a here-document whose body starts after the
+// next newline.
+func (l *Lexer) addHereDoc(hereDoc *HereDoc) {
+	l.hereDocs = append(l.hereDocs, hereDoc)
+}
+
+// Incomplete tells if the source ended in the middle of something: a quote
+// that was never closed, a line continuation or a here-document that is
+// still waiting for its delimiter.
+func (l *Lexer) Incomplete() bool {
+	return l.unclosed || len(l.hereDocs) > 0
+}
+
+// readHereDocs reads the bodies of queued here-documents. It must be called
+// with the lexer at the start of the line after the one where they are.
+// Here-documents that reach EOF before their delimiter are kept in the
+// queue.
+func (l *Lexer) readHereDocs() {
+	for len(l.hereDocs) > 0 {
+		hereDoc := l.hereDocs[0]
+		body := ""
+
+		for {
+			line, ok := l.readLine()
+
+			if !ok {
+				return
+			}
+
+			if hereDoc.stripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+
+			if line == hereDoc.delimiter {
+				break
+			}
+
+			body += line + "\n"
+		}
+
+		hereDoc.body = body
+
+		if !hereDoc.quoted {
+			hereDoc.parts = hereDocParts(body)
+		}
+		l.hereDocs = l.hereDocs[1:]
+	}
+}
+
+// readLine reads up to the end of the line, leaving the lexer at the start
+// of the next one. The line break is not included. It reports false if
+// there's nothing left to read.
+func (l *Lexer) readLine() (string, bool) {
+	if l.currentChar == 0 {
+		return "", false
+	}
+
+	start := l.position
+
+	for l.currentChar != '\n' && l.currentChar != 0 {
+		l.consume()
+	}
+
+	line := l.source[start:l.position]
+	l.consume()
+
+	return line, true
+}
+
+// hereDocParts breaks the body of an unquoted here-document down like the
+// inside of double quotes, except that double quotes are just characters.
+func hereDocParts(body string) []WordPart {
+	l := NewLexer(body)
+	parts := []WordPart{}
+	literal := ""
+
+	for ; l.currentChar != 0; l.consume() {
+		if l.currentChar == '\\' && strings.ContainsRune("$`\\\n", l.peek(1)) {
+			if literal != "" {
+				parts = append(parts, &Literal{value: literal})
+				literal = ""
+			}
+
+			if part := l.readEscaped(); part != nil {
+				parts = append(parts, part)
+			}
+
+			continue
+		}
+
+		if l.currentChar == '$' {
+			parts, literal = l.readDollar(parts, literal, true)
+			continue
+		}
+
+		if l.currentChar == '`' {
+			if literal != "" {
+				parts = append(parts, &Literal{value: literal})
+				literal = ""
+			}
+
+			parts = append(parts, l.readBackquoted(false))
+			continue
+		}
+
+		literal += string(l.currentChar)
+	}
+
+	if literal != "" {
+		parts = append(parts, &Literal{value: literal})
+	}
+
+	return parts
+}
+
+func isNameStart(c rune) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameChar(c rune) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func isDigit(c rune) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isSpecialParameter(c rune) bool {
+	return c != 0 && strings.ContainsRune("@*#?-$!", c)
+}
+
+// isParameterStart tells if c can start the name of a parameter.
+func isParameterStart(c rune) bool {
+	return isNameStart(c) || isDigit(c) || isSpecialParameter(c)
+}
+
+func (l *Lexer) isMetaCharacter(c rune) bool {
+	return c == 0 || unicode.IsSpace(c) || strings.ContainsRune(";&|<>()", c)
+}
+
+func (l *Lexer) isRedirection(c rune) bool {
+	return c == '>' || c == '<'
 }
@@ -5,6 +5,28 @@ import (
 	"testing"
 )
 
+// typesAndLexemes drops everything but the type and lexeme of tokens.
+func typesAndLexemes(tokens []Token) []Token {
+	stripped := []Token{}
+
+	for _, token := range tokens {
+		stripped = append(stripped, Token{T: token.T, Lexeme: token.Lexeme})
+	}
+
+	return stripped
+}
+
+func withoutSpans(tokens []Token) []Token {
+	stripped := []Token{}
+
+	for _, token := range tokens {
+		token.Span = Span{}
+		stripped = append(stripped, token)
+	}
+
+	return stripped
+}
+
 func TestReadingAmpersand(t *testing.T) {
 	tests := []struct {
 		source   string
@@ -35,7 +57,7 @@ func TestReadingAmpersand(t *testing.T) {
 
 	for _, tt := range tests {
 		lexer := NewLexer(tt.source)
-		tokens := lexer.Tokenize()
+		tokens := typesAndLexemes(lexer.Tokenize())
 
 		if !reflect.DeepEqual(tokens, tt.expected) {
 			t.Errorf("%s: Expected %v, but got %v", tt.source, tt.expected, tokens)
@@ -85,7 +107,7 @@ func TestReadingPipes(t *testing.T) {
 
 	for _, tt := range tests {
 		lexer := NewLexer(tt.source)
-		tokens := lexer.Tokenize()
+		tokens := typesAndLexemes(lexer.Tokenize())
 
 		if !reflect.DeepEqual(tokens, tt.expected) {
 			t.Errorf("%s: Expected %v, but got %v", tt.source, tt.expected, tokens)
@@ -179,7 +201,7 @@ func TestReadingInputRedirection(t *testing.T) {
 
 	for _, tt := range tests {
 		lexer := NewLexer(tt.source)
-		tokens := lexer.Tokenize()
+		tokens := typesAndLexemes(lexer.Tokenize())
 
 		if !reflect.DeepEqual(tokens, tt.expected) {
 			t.Errorf("%s: Expected %v, but got %v", tt.source, tt.expected, tokens)
@@ -259,7 +281,7 @@ func TestReadingOutputRedirection(t *testing.T) {
 
 	for _, tt := range tests {
 		lexer := NewLexer(tt.source)
-		tokens := lexer.Tokenize()
+		tokens := typesAndLexemes(lexer.Tokenize())
 
 		if !reflect.DeepEqual(tokens, tt.expected) {
 			t.Errorf("%s: Expected %v, but got %v", tt.source, tt.expected, tokens)
@@ -267,3 +289,402 @@ func TestReadingOutputRedirection(t *testing.T) {
 
 	}
 }
+
+func TestReadingWords(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected []Token
+	}{
+		{
+			"ls -la /usr/bin",
+			[]Token{
+				{T: WORD, Lexeme: "ls", Parts: []WordPart{&Literal{value: "ls"}}},
+				{T: WORD, Lexeme: "-la", Parts: []WordPart{&Literal{value: "-la"}}},
+				{T: WORD, Lexeme: "/usr/bin", Parts: []WordPart{&Literal{value: "/usr/bin"}}},
+			},
+		},
+		{
+			"echo 'hello world'",
+			[]Token{
+				{T: WORD, Lexeme: "echo", Parts: []WordPart{&Literal{value: "echo"}}},
+				{
+					T:      WORD,
+					Lexeme: "'hello world'",
+					Parts:  []WordPart{&SingleQuoted{value: "hello world"}},
+				},
+			},
+		},
+		{
+			`"$HOME/x"a\ b`,
+			[]Token{
+				{
+					T:      WORD,
+					Lexeme: `"$HOME/x"a\ b`,
+					Parts: []WordPart{
+						&DoubleQuoted{parts: []WordPart{
+							&Parameter{name: "HOME"},
+							&Literal{value: "/x"},
+						}},
+						&Literal{value: "a"},
+						&Escaped{value: " "},
+						&Literal{value: "b"},
+					},
+				},
+			},
+		},
+		{
+			`"a \"b\" \n"`,
+			[]Token{
+				{
+					T:      WORD,
+					Lexeme: `"a \"b\" \n"`,
+					Parts: []WordPart{
+						&DoubleQuoted{parts: []WordPart{
+							&Literal{value: "a "},
+							&Escaped{value: "\""},
+							&Literal{value: "b"},
+							&Escaped{value: "\""},
+							&Literal{value: ` \n`},
+						}},
+					},
+				},
+			},
+		},
+		{
+			"'a;b'|c",
+			[]Token{
+				{T: WORD, Lexeme: "'a;b'", Parts: []WordPart{&SingleQuoted{value: "a;b"}}},
+				{T: PIPE, Lexeme: PIPE},
+				{T: WORD, Lexeme: "c", Parts: []WordPart{&Literal{value: "c"}}},
+			},
+		},
+		{
+			"a#b # comment",
+			[]Token{
+				{T: WORD, Lexeme: "a#b", Parts: []WordPart{&Literal{value: "a#b"}}},
+			},
+		},
+		{
+			"a \\\nb",
+			[]Token{
+				{T: WORD, Lexeme: "a", Parts: []WordPart{&Literal{value: "a"}}},
+				{T: WORD, Lexeme: "b", Parts: []WordPart{&Literal{value: "b"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		tokens := withoutSpans(lexer.Tokenize())
+
+		if !reflect.DeepEqual(tokens, tt.expected) {
+			t.Errorf("%s: Expected %v, but got %v", tt.source, tt.expected, tokens)
+		}
+	}
+}
+
+func TestReadingParameters(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected []WordPart
+	}{
+		{
+			"$HOME/bin",
+			[]WordPart{&Parameter{name: "HOME"}, &Literal{value: "/bin"}},
+		},
+		{
+			"$12$@$",
+			[]WordPart{
+				&Parameter{name: "1"},
+				&Literal{value: "2"},
+				&Parameter{name: "@"},
+				&Literal{value: "$"},
+			},
+		},
+		{
+			"${10}${#}${#x}${#-}",
+			[]WordPart{
+				&Parameter{name: "10", braced: true},
+				&Parameter{name: "#", braced: true},
+				&Parameter{name: "x", braced: true, length: true},
+				&Parameter{name: "-", braced: true, length: true},
+			},
+		},
+		{
+			`${x:-a b}`,
+			[]WordPart{
+				&Parameter{
+					name:     "x",
+					braced:   true,
+					operator: ":-",
+					word:     &Word{parts: []WordPart{&Literal{value: "a b"}}},
+				},
+			},
+		},
+		{
+			`${x##*"/"}`,
+			[]WordPart{
+				&Parameter{
+					name:     "x",
+					braced:   true,
+					operator: "##",
+					word: &Word{parts: []WordPart{
+						&Literal{value: "*"},
+						&DoubleQuoted{parts: []WordPart{&Literal{value: "/"}}},
+					}},
+				},
+			},
+		},
+		{
+			`"${x-'$y'}"`,
+			[]WordPart{
+				&DoubleQuoted{parts: []WordPart{
+					&Parameter{
+						name:     "x",
+						braced:   true,
+						operator: "-",
+						word: &Word{parts: []WordPart{
+							&Literal{value: "'"},
+							&Parameter{name: "y"},
+							&Literal{value: "'"},
+						}},
+					},
+				}},
+			},
+		},
+		{
+			"${x:y}",
+			[]WordPart{
+				&Parameter{
+					braced: true,
+					word: &Word{parts: []WordPart{
+						&Literal{value: "x"},
+						&Literal{value: ":y"},
+					}},
+				},
+			},
+		},
+		{
+			"${x[1]}${#x[@]}${x[*]-a}",
+			[]WordPart{
+				&Parameter{name: "x", braced: true, index: "1"},
+				&Parameter{name: "x", braced: true, length: true, index: "@"},
+				&Parameter{
+					name:     "x",
+					braced:   true,
+					index:    "*",
+					operator: "-",
+					word:     &Word{parts: []WordPart{&Literal{value: "a"}}},
+				},
+			},
+		},
+		{
+			"${x[y]}",
+			[]WordPart{
+				&Parameter{braced: true, word: &Word{parts: []WordPart{&Literal{value: "x[y]"}}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		token := lexer.NextToken()
+
+		if !reflect.DeepEqual(token.Parts, tt.expected) {
+			t.Errorf("%s: Expected %v, but got %v", tt.source, tt.expected, token.Parts)
+		}
+	}
+}
+
+func TestReadingCommandSubstitutions(t *testing.T) {
+	tests := []struct {
+		source          string
+		expectedLexeme  string
+		expectedProgram string
+	}{
+		{
+			"$(echo a)b c",
+			"$(echo a)b",
+			"(simpleCommand [] echo [a] [])",
+		},
+		{
+			"x$(echo \")\" ')' $(echo))",
+			"x$(echo \")\" ')' $(echo))",
+			"(simpleCommand [] echo [\")\" ')' $(echo)] [])",
+		},
+		{
+			"$(case a in a) echo;; esac)",
+			"$(case a in a) echo;; esac)",
+			"(case a [(caseItem [a] (simpleCommand [] echo [] []))])",
+		},
+		{
+			"\"`echo \\\"a\\\" \\$x`\"",
+			"\"`echo \\\"a\\\" \\$x`\"",
+			"(simpleCommand [] echo [\"a\" $x] [])",
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		token := lexer.NextToken()
+
+		if token.Lexeme != tt.expectedLexeme {
+			t.Errorf("%s: Expected %q, but got %q", tt.source, tt.expectedLexeme, token.Lexeme)
+		}
+
+		var substitution *CommandSubstitution
+
+		for _, part := range token.Parts {
+			if quoted, ok := part.(*DoubleQuoted); ok {
+				part = quoted.parts[0]
+			}
+
+			if part, ok := part.(*CommandSubstitution); ok {
+				substitution = part
+			}
+		}
+
+		if substitution == nil {
+			t.Errorf("%s: Expected a command substitution in %v", tt.source, token.Parts)
+			continue
+		}
+
+		if substitution.program.String() != tt.expectedProgram {
+			t.Errorf(
+				"%s: Expected %s, but got %s",
+				tt.source,
+				tt.expectedProgram,
+				substitution.program,
+			)
+		}
+	}
+}
+
+func TestIncompleteWords(t *testing.T) {
+	tests := []struct {
+		source     string
+		incomplete bool
+	}{
+		{"echo 'unclosed", true},
+		{"echo \"unclosed", true},
+		{"echo \"it's\"", false},
+		{"echo continued \\", true},
+		{"echo continued \\\n", true},
+		{"echo continued\\\n", true},
+		{"echo one \\\n  two \\\n", true},
+		{"echo one \\\n  two\\\nthree", false},
+		{"echo 'a\\\n", true},
+		{"echo 'closed'", false},
+		{"echo ${unclosed", true},
+		{"echo \"${x:-}\"", false},
+		{"echo $(echo", true},
+		{"echo $(echo ')'", true},
+		{"echo `echo", true},
+		{"echo $(echo) `echo`", false},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		lexer.Tokenize()
+
+		if lexer.Incomplete() != tt.incomplete {
+			t.Errorf("%s: Expected incomplete to be %t", tt.source, tt.incomplete)
+		}
+	}
+}
+
+func TestTokenSpans(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected []Span
+	}{
+		{
+			"ls -la",
+			[]Span{
+				{Start: Position{0, 0, 0, 0}, End: Position{2, 2, 0, 2}},
+				{Start: Position{3, 3, 0, 3}, End: Position{6, 6, 0, 6}},
+			},
+		},
+		{
+			"echo ção && ls",
+			[]Span{
+				{Start: Position{0, 0, 0, 0}, End: Position{4, 4, 0, 4}},
+				{Start: Position{5, 5, 0, 5}, End: Position{10, 8, 0, 8}},
+				{Start: Position{11, 9, 0, 9}, End: Position{13, 11, 0, 11}},
+				{Start: Position{14, 12, 0, 12}, End: Position{16, 14, 0, 14}},
+			},
+		},
+		{
+			"a\n  🐹b",
+			[]Span{
+				{Start: Position{0, 0, 0, 0}, End: Position{1, 1, 0, 1}},
+				{Start: Position{1, 1, 0, 1}, End: Position{2, 2, 1, 0}},
+				{Start: Position{4, 4, 1, 2}, End: Position{9, 6, 1, 4}},
+			},
+		},
+		{
+			"cat <<EOF\nbody\nEOF\nls",
+			[]Span{
+				{Start: Position{0, 0, 0, 0}, End: Position{3, 3, 0, 3}},
+				{Start: Position{4, 4, 0, 4}, End: Position{6, 6, 0, 6}},
+				{Start: Position{6, 6, 0, 6}, End: Position{9, 9, 0, 9}},
+				{Start: Position{9, 9, 0, 9}, End: Position{10, 10, 1, 0}},
+				{Start: Position{19, 19, 3, 0}, End: Position{21, 21, 3, 2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		spans := []Span{}
+		previous := Token{}
+
+		for token := lexer.NextToken(); token.T != EOF; token = lexer.NextToken() {
+			if previous.T == DLESS {
+				lexer.addHereDoc(&HereDoc{delimiter: token.Lexeme})
+			}
+
+			spans = append(spans, token.Span)
+			previous = token
+		}
+
+		if !reflect.DeepEqual(spans, tt.expected) {
+			t.Errorf("%q: Expected %v, but got %v", tt.source, tt.expected, spans)
+		}
+	}
+}
+
+func TestReadingUnicode(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected []Token
+	}{
+		{
+			"",
+			[]Token{},
+		},
+		{
+			"echo olá",
+			[]Token{{T: WORD, Lexeme: "echo"}, {T: WORD, Lexeme: "olá"}},
+		},
+		{
+			"echo 'ünï cödé' 🐹|wc",
+			[]Token{
+				{T: WORD, Lexeme: "echo"},
+				{T: WORD, Lexeme: "'ünï cödé'"},
+				{T: WORD, Lexeme: "🐹"},
+				{T: PIPE, Lexeme: PIPE},
+				{T: WORD, Lexeme: "wc"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		tokens := typesAndLexemes(lexer.Tokenize())
+
+		if !reflect.DeepEqual(tokens, tt.expected) {
+			t.Errorf("%s: Expected %v, but got %v", tt.source, tt.expected, tokens)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package interpreter
+
+import "fmt"
+
+// Options are the flags that change how the interpreter behaves.
+type Options struct {
+	// Noclobber prevents ">" from overwriting existing files. ">|" still
+	// does it.
+	Noclobber bool
+	// Noglob turns pathname expansion off, patterns are left as they are.
+	Noglob bool
+	// Posix turns off what isn't in POSIX, like brace expansion.
+	Posix bool
+	// Monitor turns job control on: each job runs in a process group of
+	// its own, which is given the terminal while it's in the foreground.
+	Monitor bool
+	// Errexit makes the shell exit when a command fails, unless its status
+	// is tested, like in the condition of an if or on the left of "&&".
+	Errexit bool
+	// Nounset makes expanding an unset parameter an error, which exits a
+	// shell that isn't interactive. $@ and $* are fine.
+	Nounset bool
+	// Xtrace writes each simple command to the standard error, after its
+	// expansions and with $PS4 before it, right before it runs.
+	Xtrace bool
+	// Noexec reads commands without running them, to check their syntax.
+	// Interactive shells don't take it.
+	Noexec bool
+	// Verbose writes the input to the standard error as it's read.
+	Verbose bool
+	// Pipefail makes the status of a pipeline the one of its last command
+	// that failed, instead of the one of its last command.
+	Pipefail bool
+}
+
+// optionNames are the names of the options, used by "set -o", and their
+// letters, used by "set -" and $-. Not every option has a letter.
+var optionNames = []struct {
+	name   string
+	letter rune
+	field  func(*Options) *bool
+}{
+	{"noclobber", 'C', func(o *Options) *bool { return &o.Noclobber }},
+	{"monitor", 'm', func(o *Options) *bool { return &o.Monitor }},
+	{"noglob", 'f', func(o *Options) *bool { return &o.Noglob }},
+	{"posix", 0, func(o *Options) *bool { return &o.Posix }},
+	{"errexit", 'e', func(o *Options) *bool { return &o.Errexit }},
+	{"nounset", 'u', func(o *Options) *bool { return &o.Nounset }},
+	{"xtrace", 'x', func(o *Options) *bool { return &o.Xtrace }},
+	{"noexec", 'n', func(o *Options) *bool { return &o.Noexec }},
+	{"verbose", 'v', func(o *Options) *bool { return &o.Verbose }},
+	{"pipefail", 0, func(o *Options) *bool { return &o.Pipefail }},
+}
+
+// Set turns the option called name on or off.
+func (o *Options) Set(name string, on bool) error {
+	for _, option := range optionNames {
+		if option.name == name {
+			*option.field(o) = on
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: invalid option name", name)
+}
+
+// SetFlag turns the option with the letter flag on or off.
+func (o *Options) SetFlag(flag rune, on bool) error {
+	for _, option := range optionNames {
+		if option.letter != 0 && option.letter == flag {
+			*option.field(o) = on
+			return nil
+		}
+	}
+
+	return fmt.Errorf("-%c: invalid option", flag)
+}
+
+// List tells which options are on, by their names.
+func (o Options) List() map[string]bool {
+	options := map[string]bool{}
+
+	for _, option := range optionNames {
+		options[option.name] = *option.field(&o)
+	}
+
+	return options
+}
+
+// Flags returns the letters of the options that are on, which is what
+// $- expands to.
+func (o Options) Flags() string {
+	flags := ""
+
+	for _, option := range optionNames {
+		if option.letter != 0 && *option.field(&o) {
+			flags += string(option.letter)
+		}
+	}
+
+	return flags
+}
@@ -1,21 +1,59 @@
 package interpreter
 
 import (
-	"fmt"
+	"errors"
+	"slices"
+	"strconv"
 	"strings"
 )
 
+// ErrIncomplete is returned when the input ends before a command is
+// complete, like a here-document without its delimiter. More input can make
+// it valid.
+var ErrIncomplete = errors.New("incomplete input")
+
+// Besides tokens, the parser can expect one of these, which are parts of
+// the grammar made of them.
+const (
+	COMMAND = "COMMAND"
+	NAME    = "NAME"
+	PATTERN = "PATTERN"
+)
+
 var operators []tokenType = []tokenType{AND, DAND, PIPE, DPIPE, SEMI}
 
+var closingWords []string = []string{"then", "else", "elif", "fi", "do", "done", "esac", "}"}
+
+var redirectionOperators []tokenType = []tokenType{
+	DLESS,
+	DLESSDASH,
+	LESS,
+	GREAT,
+	DGREAT,
+	LESSAND,
+	GREATAND,
+	LESSGREAT,
+	CLOBBER,
+}
+
 type Parser struct {
 	lexer   *Lexer
 	current Token
+	// previous is the token before current.
+	previous Token
+	// peeked is the token after current, once it has been looked at.
+	peeked *Token
+	errors ParseErrors
+	// sources has the text of the pipelines and lists parsed, which is
+	// how jobs are shown.
+	sources map[Node]string
 }
 
 func NewParser(lexer *Lexer) *Parser {
 	return &Parser{
 		lexer:   lexer,
 		current: lexer.NextToken(),
+		sources: map[Node]string{},
 	}
 }
 
@@ -33,66 +71,301 @@ func (p *Parser) expect(t tokenType) bool {
 	return p.current.T == t
 }
 
-func (p *Parser) expectError() error {
-	return fmt.Errorf(
-		"Unexpected token near %d:%d: %s",
-		p.lexer.line,
-		p.lexer.position,
-		strings.Split(p.lexer.source, "\n\r")[p.lexer.line],
-	)
-}
-
-func (p *Parser) eofError() error {
-	return fmt.Errorf(
-		"Unexpected EOF at %d:%d: %s",
-		p.lexer.line,
-		p.lexer.position,
-		strings.Split(p.lexer.source, "\n\r")[p.lexer.line],
-	)
+func (p *Parser) expectError(expected ...tokenType) error {
+	return &ParseError{
+		Span:     p.current.Span,
+		Expected: expected,
+		Found:    p.current,
+		source:   p.lexer.source,
+	}
 }
 
 func (p *Parser) next() {
+	p.previous = p.current
+
+	if p.peeked != nil {
+		p.current = *p.peeked
+		p.peeked = nil
+		return
+	}
+
 	p.current = p.lexer.NextToken()
 }
 
+// peek returns the token after the current one without moving on.
+func (p *Parser) peek() Token {
+	if p.peeked == nil {
+		token := p.lexer.NextToken()
+		p.peeked = &token
+	}
+
+	return *p.peeked
+}
+
+func (p *Parser) skipNewlines() {
+	for p.match(NEWLINE) {
+		p.next()
+	}
+}
+
+// Parse parses the whole source. Syntax errors don't stop it, the parser
+// recovers at the next separator, or after the compound command the error
+// is in, and keeps going. In that case the program holds everything that
+// could be parsed and the error is a ParseErrors with every syntax error
+// found.
 func (p *Parser) Parse() (*Program, error) {
-	return p.program()
+	program, err := p.program()
+
+	if err != nil {
+		return program, err
+	}
+
+	if found := p.syntaxErrors(); len(found) > 0 {
+		return program, found
+	}
+
+	if p.lexer.Incomplete() {
+		return nil, ErrIncomplete
+	}
+
+	return program, nil
 }
 
-func (p *Parser) program() (*Program, error) {
-	program := &Program{}
+// program parses commands up to the end of the input, or up to one of the
+// tokens in end.
+func (p *Parser) program(end ...tokenType) (*Program, error) {
+	program := &Program{sources: p.sources}
 
-	for !p.match(EOF) {
+	for p.skipNewlines(); !p.match(EOF) && !p.match(end...); p.skipNewlines() {
 		node, err := p.sequence()
 
 		if err != nil {
 			return nil, err
 		}
 
-		program.nodes = append(program.nodes, node)
+		if node != nil {
+			program.nodes = append(program.nodes, node)
+		}
+
+		// A sequence only stops early at something that can't start a
+		// command, like a closing keyword without its compound command.
+		if !p.match(EOF, NEWLINE) && !p.match(end...) {
+			if err := p.recover(p.expectError(p.expectedAfter()...)); err != nil {
+				return nil, err
+			}
+
+			if p.match(SEMI, AND) {
+				p.next()
+			}
+		}
 	}
 
 	return program, nil
 }
 
+// syntaxErrors returns the errors found by the parser together with the
+// ones the lexer found in command substitutions, in the order they appear.
+func (p *Parser) syntaxErrors() ParseErrors {
+	found := append(slices.Clone(p.errors), p.lexer.errors...)
+
+	slices.SortStableFunc(found, func(a, b *ParseError) int {
+		return a.Span.Start.Offset - b.Span.Start.Offset
+	})
+
+	return found
+}
+
+// recover records a syntax error and skips everything up to the next point
+// where parsing can start again: a separator, a conditional operator or a
+// closing keyword. The offending token is always skipped, unless it's a
+// separator, so the parser doesn't stop at the same error twice. Compound
+// commands in what's skipped are skipped whole, their separators and
+// closing keywords aren't places to start again.
+func (p *Parser) recover(err error) error {
+	var parseError *ParseError
+
+	if !errors.As(err, &parseError) {
+		return err
+	}
+
+	p.errors = append(p.errors, parseError)
+	closers := []string{}
+
+	if p.current.Span == parseError.Span && !p.match(SEMI, AND, NEWLINE, EOF) {
+		closers = p.skipToken(closers)
+	}
+
+	for !p.match(EOF) {
+		stop := p.match(SEMI, DSEMI, AND, NEWLINE, DAND, DPIPE, RPAREN) || p.matchClosing()
+
+		if stop && len(closers) == 0 {
+			break
+		}
+
+		closers = p.skipToken(closers)
+	}
+
+	return nil
+}
+
+// skipCompound records a syntax error found in a compound command and
+// skips everything up to the word or operator in closing that ends it,
+// which is consumed too. Parsing starts again after the whole command,
+// instead of at what's left of it.
+func (p *Parser) skipCompound(err error, closing string) error {
+	var parseError *ParseError
+
+	if !errors.As(err, &parseError) {
+		return err
+	}
+
+	p.errors = append(p.errors, parseError)
+	closers := []string{closing}
+
+	for len(closers) > 0 && !p.match(EOF) {
+		closers = p.skipToken(closers)
+	}
+
+	return nil
+}
+
+// openers are the words and operators that start a compound command, with
+// the ones that end it.
+var openers = map[string]string{
+	"if":    "fi",
+	"while": "done",
+	"until": "done",
+	"for":   "done",
+	"case":  "esac",
+	"{":     "}",
+	LPAREN:  RPAREN,
+}
+
+// skipToken moves past the current token while recovering from an error.
+// closers are what ends the compound commands being skipped, innermost
+// last. The token closes the innermost one, or it may open another. Words
+// only open one where a command can start.
+func (p *Parser) skipToken(closers []string) []string {
+	lexeme := p.current.Lexeme
+
+	switch closer, opens := openers[lexeme]; {
+	case len(closers) > 0 && lexeme == closers[len(closers)-1]:
+		closers = closers[:len(closers)-1]
+	case opens && (p.match(LPAREN) || p.afterSeparator()):
+		closers = append(closers, closer)
+	}
+
+	p.next()
+
+	return closers
+}
+
+// afterSeparator tells if the token before the current one is one after
+// which a command can start.
+func (p *Parser) afterSeparator() bool {
+	if p.previous.T == WORD {
+		return slices.Contains(
+			[]string{"if", "then", "else", "elif", "while", "until", "do", "{", "!"},
+			p.previous.Lexeme,
+		)
+	}
+
+	return slices.Contains(
+		[]tokenType{"", SEMI, DSEMI, AND, NEWLINE, DAND, DPIPE, PIPE, LPAREN, RPAREN},
+		p.previous.T,
+	)
+}
+
+// expectedAfter returns what can come where a sequence stopped early: a
+// command after a separator, or the end of the one before.
+func (p *Parser) expectedAfter() []tokenType {
+	if p.afterSeparator() {
+		return []tokenType{COMMAND}
+	}
+
+	return []tokenType{SEMI, AND, NEWLINE}
+}
+
+// matchClosing tells if the current token is one of the words that close
+// a compound command.
+func (p *Parser) matchClosing() bool {
+	return p.match(WORD) && slices.Contains(closingWords, p.current.Lexeme)
+}
+
+// matchReserved tells if the current token is one of the reserved words in
+// types. They are only recognized unquoted and where the grammar expects
+// them, anywhere else they are ordinary words.
+func (p *Parser) matchReserved(types ...tokenType) bool {
+	if !p.match(WORD) {
+		return false
+	}
+
+	t, ok := LookupReservedWord(p.current.Lexeme)
+
+	return ok && slices.Contains(types, t)
+}
+
 func (p *Parser) sequence() (Node, error) {
+	errors := len(p.errors)
 	lhs, err := p.conditional()
+	// A compound command with an error recovers from it itself, and is
+	// left out like the other commands with one.
+	recovered := len(p.errors) > errors
 
 	if err != nil {
-		return nil, err
+		if err := p.recover(err); err != nil {
+			return nil, err
+		}
+
+		recovered = true
 	}
 
-	for p.match(SEMI, AND) {
+	for p.match(SEMI, AND, NEWLINE) {
+		if lhs == nil && !recovered {
+			if err := p.recover(p.expectError(COMMAND)); err != nil {
+				return nil, err
+			}
+
+			recovered = true
+		}
+
 		separator := p.current.T
+
+		if separator == NEWLINE {
+			separator = SEMI
+		}
+
 		p.next()
+		p.skipNewlines()
+		recovered = false
 
 		if p.match(operators...) {
-			return nil, p.expectError()
+			if err := p.recover(p.expectError(COMMAND)); err != nil {
+				return nil, err
+			}
+
+			recovered = true
+			continue
 		}
 
+		errors = len(p.errors)
 		rhs, err := p.conditional()
+		recovered = len(p.errors) > errors
+
 		if err != nil {
-			return nil, err
+			if err := p.recover(err); err != nil {
+				return nil, err
+			}
+
+			recovered = true
+			continue
+		}
+
+		// Commands dropped while recovering from an error leave a hole
+		// in the sequence.
+		if lhs == nil {
+			lhs = rhs
+			continue
 		}
 
 		lhs = &Sequence{separator: separator, lhs: lhs, rhs: rhs}
@@ -101,18 +374,33 @@ func (p *Parser) sequence() (Node, error) {
 	return lhs, nil
 }
 
+// remember keeps the text of node, which starts at start and ends with
+// the last token read.
+func (p *Parser) remember(node Node, start Position) {
+	if node != nil && p.previous.Span.End.Offset > start.Offset {
+		p.sources[node] = p.lexer.source[start.Offset:p.previous.Span.End.Offset]
+	}
+}
+
 func (p *Parser) conditional() (Node, error) {
+	start, errors := p.current.Span.Start, len(p.errors)
 	lhs, err := p.pipe()
 
 	if err != nil {
 		return nil, err
 	}
+
 	for p.match(DAND, DPIPE) {
+		if lhs == nil && len(p.errors) == errors {
+			return nil, p.expectError(COMMAND)
+		}
+
 		conditionalType := p.current.T
 		p.next()
+		p.skipNewlines()
 
 		if p.match(operators...) {
-			return nil, p.expectError()
+			return nil, p.expectError(COMMAND)
 		}
 
 		rhs, err := p.pipe()
@@ -120,13 +408,52 @@ func (p *Parser) conditional() (Node, error) {
 			return nil, err
 		}
 
+		if rhs == nil && len(p.errors) == errors {
+			return nil, p.expectError(COMMAND)
+		}
+
 		lhs = &Conditional{conditionalType: conditionalType, lhs: lhs, rhs: rhs}
 	}
 
+	// A compound command in it that had an error leaves it all out, like
+	// an error of its own does.
+	if len(p.errors) > errors {
+		return nil, nil
+	}
+
+	p.remember(lhs, start)
+
 	return lhs, nil
 }
 
+// pipe parses a pipeline, which "!" before it negates.
 func (p *Parser) pipe() (Node, error) {
+	start := p.current.Span.Start
+
+	if !p.matchReserved(BANG) {
+		return p.pipeSequence()
+	}
+
+	p.next()
+	errors := len(p.errors)
+	pipeline, err := p.pipe()
+
+	if err != nil || pipeline == nil && len(p.errors) > errors {
+		return nil, err
+	}
+
+	if pipeline == nil {
+		return nil, p.expectError(COMMAND)
+	}
+
+	not := &Not{pipeline: pipeline}
+	p.remember(not, start)
+
+	return not, nil
+}
+
+func (p *Parser) pipeSequence() (Node, error) {
+	start, errors := p.current.Span.Start, len(p.errors)
 	lhs, err := p.command()
 
 	if err != nil {
@@ -134,10 +461,15 @@ func (p *Parser) pipe() (Node, error) {
 	}
 
 	for p.match(PIPE) {
+		if lhs == nil && len(p.errors) == errors {
+			return nil, p.expectError(COMMAND)
+		}
+
 		p.next()
+		p.skipNewlines()
 
 		if p.match(operators...) {
-			return nil, p.expectError()
+			return nil, p.expectError(COMMAND)
 		}
 
 		rhs, err := p.command()
@@ -145,41 +477,524 @@ func (p *Parser) pipe() (Node, error) {
 			return nil, err
 		}
 
+		if rhs == nil && len(p.errors) == errors {
+			return nil, p.expectError(COMMAND)
+		}
+
 		lhs = &Pipe{lhs: lhs, rhs: rhs}
 	}
 
+	// A compound command in it that had an error leaves it all out, like
+	// an error of its own does.
+	if len(p.errors) > errors {
+		return nil, nil
+	}
+
+	p.remember(lhs, start)
+
 	return lhs, nil
 }
 
 func (p *Parser) command() (Node, error) {
-	if p.match(FUNCTION) {
+	// Closing keywords end the list the command would be part of.
+	if p.matchClosing() {
+		return nil, nil
+	}
+
+	if p.matchReserved(FUNCTION) || p.match(WORD) && p.peek().T == LPAREN {
 		return p.function()
 	}
 
-	if p.match(WORD) {
+	if p.matchReserved(IF, WHILE, UNTIL, FOR, CASE, LBRACE) {
+		return p.redirectedCommand()
+	}
+
+	if p.match(WORD, IO_NUMBER) || p.match(redirectionOperators...) {
 		return p.simpleCommand()
 	}
 
-	return p.compoundCommand()
+	return p.redirectedCommand()
+}
+
+// redirectedCommand parses a compound command and the redirections
+// after it.
+func (p *Parser) redirectedCommand() (Node, error) {
+	command, err := p.compoundCommand()
+
+	if err != nil || command == nil {
+		return command, err
+	}
+
+	redirections := []*Redirection{}
+
+	for p.match(IO_NUMBER) || p.match(redirectionOperators...) {
+		redirection, err := p.redirection()
+
+		if err != nil {
+			return nil, err
+		}
+
+		redirections = append(redirections, redirection)
+	}
+
+	if len(redirections) == 0 {
+		return command, nil
+	}
+
+	return &RedirectedCommand{command: command, redirections: redirections}, nil
 }
 
+// function parses both "name() compound_command" and
+// "function name compound_command". The parentheses are optional in the
+// second form.
 func (p *Parser) function() (Node, error) {
-	return nil, nil
+	keyword := p.matchReserved(FUNCTION)
+
+	if keyword {
+		p.next()
+	}
+
+	if !p.match(WORD) || !isName(p.current.Lexeme) {
+		return nil, p.expectError(NAME)
+	}
+
+	name := p.current.Lexeme
+	p.next()
+
+	if p.match(LPAREN) || !keyword {
+		p.next()
+
+		if !p.match(RPAREN) {
+			return nil, p.expectError(RPAREN)
+		}
+
+		p.next()
+	}
+
+	p.skipNewlines()
+	errors := len(p.errors)
+	body, err := p.redirectedCommand()
+
+	// A body with an error was already skipped.
+	if err != nil || body == nil && len(p.errors) > errors {
+		return nil, err
+	}
+
+	if body == nil {
+		return nil, p.expectError(LBRACE)
+	}
+
+	return &FunctionDef{name: name, body: body}, nil
 }
 
 func (p *Parser) simpleCommand() (Node, error) {
-	name := p.current.Lexeme
-	params := []string{}
+	command := &SimpleCommand{params: []*Word{}}
+
+	for p.match(WORD, IO_NUMBER) || p.match(redirectionOperators...) {
+		if p.match(WORD) {
+			// Assignments are only recognized before the name.
+			switch {
+			case command.name != nil:
+				command.params = append(command.params, p.word())
+			case p.assignment() != nil:
+				command.assignments = append(command.assignments, p.assignment())
+			default:
+				command.name = p.word()
+			}
+
+			p.next()
+			continue
+		}
 
-	p.next()
-	for p.match(WORD) {
-		params = append(params, p.current.Lexeme)
+		redirection, err := p.redirection()
+
+		if err != nil {
+			return nil, err
+		}
+
+		command.redirections = append(command.redirections, redirection)
+	}
+
+	return command, nil
+}
+
+func (p *Parser) word() *Word {
+	return &Word{parts: p.current.Parts}
+}
+
+// assignment returns the current word as an assignment if it looks like
+// "NAME=value", with the name unquoted. Otherwise it returns nil.
+func (p *Parser) assignment() *Assignment {
+	parts := p.current.Parts
+
+	if len(parts) == 0 {
+		return nil
+	}
+
+	literal, ok := parts[0].(*Literal)
+
+	if !ok {
+		return nil
+	}
+
+	name, value, found := strings.Cut(literal.value, "=")
+
+	if !found || !isName(name) {
+		return nil
+	}
+
+	parts = parts[1:]
+
+	if value != "" {
+		parts = append([]WordPart{&Literal{value: value}}, parts...)
+	}
+
+	return &Assignment{name: name, value: &Word{parts: parts}}
+}
+
+func (p *Parser) redirection() (*Redirection, error) {
+	redirection := &Redirection{ioNumber: -1}
+
+	if p.match(IO_NUMBER) {
+		redirection.ioNumber, _ = strconv.Atoi(p.current.Lexeme)
 		p.next()
 	}
 
-	return &SimpleCommand{name: name, params: params}, nil
+	if !p.match(redirectionOperators...) {
+		return nil, p.expectError(redirectionOperators...)
+	}
+
+	redirection.redirectionType = p.current.T
+	p.next()
+
+	if !p.expect(WORD) {
+		return nil, p.expectError(WORD)
+	}
+
+	redirection.file = p.word()
+
+	// The body of a here-document starts at the next newline. It has to be
+	// queued before moving on, since the lexer could reach it. Quoting any
+	// part of the delimiter disables expansion of the body.
+	if redirection.redirectionType == DLESS || redirection.redirectionType == DLESSDASH {
+		redirection.hereDoc = &HereDoc{
+			delimiter: removeQuotes(redirection.file.parts),
+			quoted:    isQuoted(redirection.file.parts),
+			stripTabs: redirection.redirectionType == DLESSDASH,
+		}
+
+		p.lexer.addHereDoc(redirection.hereDoc)
+	}
+
+	p.next()
+
+	if redirection.ioNumber == -1 {
+		redirection.ioNumber = defaultIoNumber(redirection.redirectionType)
+	}
+
+	return redirection, nil
+}
+
+// defaultIoNumber returns the file descriptor a redirection operator
+// applies to when no IO_NUMBER is given.
+func defaultIoNumber(redirectionType tokenType) int {
+	switch redirectionType {
+	case LESS, LESSAND, LESSGREAT, DLESS, DLESSDASH:
+		return 0
+	default:
+		return 1
+	}
 }
 
+// compoundCommand parses a compound command. A syntax error inside one
+// skips the rest of it, so its closing keyword isn't taken for a command.
 func (p *Parser) compoundCommand() (Node, error) {
-	return nil, nil
+	var clause func() (Node, error)
+
+	switch {
+	case p.matchReserved(IF):
+		clause = p.ifClause
+	case p.matchReserved(WHILE):
+		clause = func() (Node, error) { return p.whileClause(false) }
+	case p.matchReserved(UNTIL):
+		clause = func() (Node, error) { return p.whileClause(true) }
+	case p.matchReserved(FOR):
+		clause = p.forClause
+	case p.matchReserved(CASE):
+		clause = p.caseClause
+	case p.matchReserved(LBRACE):
+		clause = p.braceGroup
+	case p.match(LPAREN):
+		clause = p.subshell
+	default:
+		return nil, nil
+	}
+
+	closing := openers[p.current.Lexeme]
+	command, err := clause()
+
+	if err != nil {
+		return nil, p.skipCompound(err, closing)
+	}
+
+	return command, nil
+}
+
+func (p *Parser) braceGroup() (Node, error) {
+	p.next()
+	body, err := p.compoundList(RBRACE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.next()
+
+	return &BraceGroup{body: body}, nil
+}
+
+func (p *Parser) subshell() (Node, error) {
+	p.next()
+	body, err := p.compoundList(RPAREN)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.next()
+
+	return &Subshell{body: body}, nil
+}
+
+// compoundList parses the commands inside a compound command, which go up
+// to one of the reserved words or operators in closing. The closing token
+// is left for the caller.
+func (p *Parser) compoundList(closing ...tokenType) (Node, error) {
+	errors := len(p.errors)
+	p.skipNewlines()
+	list, err := p.sequence()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The list being empty is only worth reporting if it isn't because of
+	// an error that was already recorded.
+	if list == nil && len(p.errors) == errors {
+		return nil, p.expectError(COMMAND)
+	}
+
+	if !p.match(closing...) && !p.matchReserved(closing...) {
+		return nil, p.expectError(closing...)
+	}
+
+	return list, nil
+}
+
+// ifClause parses an if, or an elif, which is handled like an if nested in
+// the else part. Either way, the fi is consumed.
+func (p *Parser) ifClause() (Node, error) {
+	p.next()
+	condition, err := p.compoundList(THEN)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.next()
+	consequent, err := p.compoundList(ELIF, ELSE, FI)
+
+	if err != nil {
+		return nil, err
+	}
+
+	clause := &IfClause{condition: condition, consequent: consequent}
+
+	switch {
+	case p.matchReserved(ELIF):
+		if clause.alternative, err = p.ifClause(); err != nil {
+			return nil, err
+		}
+
+		return clause, nil
+	case p.matchReserved(ELSE):
+		p.next()
+
+		if clause.alternative, err = p.compoundList(FI); err != nil {
+			return nil, err
+		}
+	}
+
+	p.next()
+
+	return clause, nil
+}
+
+func (p *Parser) whileClause(until bool) (Node, error) {
+	p.next()
+	condition, err := p.compoundList(DO)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.doGroup()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &WhileClause{condition: condition, body: body, until: until}, nil
+}
+
+func (p *Parser) forClause() (Node, error) {
+	p.next()
+
+	if !p.match(WORD) || !isName(p.current.Lexeme) {
+		return nil, p.expectError(NAME)
+	}
+
+	clause := &ForClause{name: p.current.Lexeme}
+	p.next()
+	p.skipNewlines()
+
+	if p.matchReserved(IN) {
+		clause.words = []*Word{}
+
+		for p.next(); p.match(WORD); p.next() {
+			clause.words = append(clause.words, p.word())
+		}
+
+		if !p.match(SEMI, NEWLINE) {
+			return nil, p.expectError(SEMI, NEWLINE)
+		}
+
+		p.next()
+	} else if p.match(SEMI) {
+		p.next()
+	}
+
+	p.skipNewlines()
+	body, err := p.doGroup()
+
+	if err != nil {
+		return nil, err
+	}
+
+	clause.body = body
+
+	return clause, nil
+}
+
+// doGroup parses the body of a loop, from do to done.
+func (p *Parser) doGroup() (Node, error) {
+	if !p.matchReserved(DO) {
+		return nil, p.expectError(DO)
+	}
+
+	p.next()
+	body, err := p.compoundList(DONE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.next()
+
+	return body, nil
+}
+
+func (p *Parser) caseClause() (Node, error) {
+	p.next()
+
+	if !p.match(WORD) {
+		return nil, p.expectError(WORD)
+	}
+
+	clause := &CaseClause{word: p.word(), items: []*CaseItem{}}
+	p.next()
+	p.skipNewlines()
+
+	if !p.matchReserved(IN) {
+		return nil, p.expectError(IN)
+	}
+
+	p.next()
+	p.skipNewlines()
+
+	for !p.matchReserved(ESAC) {
+		item, err := p.caseItem()
+
+		if err != nil {
+			return nil, err
+		}
+
+		clause.items = append(clause.items, item)
+
+		// The last item doesn't need a ";;".
+		if !p.match(DSEMI) {
+			if !p.matchReserved(ESAC) {
+				return nil, p.expectError(DSEMI, ESAC)
+			}
+
+			break
+		}
+
+		p.next()
+		p.skipNewlines()
+	}
+
+	p.next()
+
+	return clause, nil
+}
+
+func (p *Parser) caseItem() (*CaseItem, error) {
+	item := &CaseItem{patterns: []*Word{}}
+
+	if p.match(LPAREN) {
+		p.next()
+	}
+
+	for {
+		if !p.match(WORD) {
+			return nil, p.expectError(PATTERN)
+		}
+
+		item.patterns = append(item.patterns, p.word())
+		p.next()
+
+		if !p.match(PIPE) {
+			break
+		}
+
+		p.next()
+	}
+
+	if !p.match(RPAREN) {
+		return nil, p.expectError(PIPE, RPAREN)
+	}
+
+	p.next()
+	p.skipNewlines()
+	body, err := p.sequence()
+
+	if err != nil {
+		return nil, err
+	}
+
+	item.body = body
+
+	return item, nil
+}
+
+// isName tells if str can be the name of a variable.
+func isName(str string) bool {
+	for n, char := range str {
+		if !isNameChar(char) || n == 0 && !isNameStart(char) {
+			return false
+		}
+	}
+
+	return str != ""
 }
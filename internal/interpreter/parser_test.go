@@ -1,9 +1,25 @@
 package interpreter
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 )
 
+func word(lexeme string) *Word {
+	return &Word{parts: []WordPart{&Literal{value: lexeme}}}
+}
+
+func words(lexemes ...string) []*Word {
+	words := []*Word{}
+
+	for _, lexeme := range lexemes {
+		words = append(words, word(lexeme))
+	}
+
+	return words
+}
+
 func TestParseSequence(t *testing.T) {
 	tests := []struct {
 		source     string
@@ -18,21 +34,52 @@ func TestParseSequence(t *testing.T) {
 						separator: AND,
 						lhs: &Pipe{
 							lhs: &SimpleCommand{
-								name:   "cat",
-								params: []string{"file"},
+								name:   word("cat"),
+								params: words("file"),
 							},
 							rhs: &SimpleCommand{
-								name:   "grep",
-								params: []string{"struct"},
+								name:   word("grep"),
+								params: words("struct"),
 							},
 						},
 						rhs: &Pipe{
 							lhs: &SimpleCommand{
-								name:   "echo",
-								params: []string{"sup"},
+								name:   word("echo"),
+								params: words("sup"),
 							},
 							rhs: &SimpleCommand{
-								name: "grep",
+								name: word("grep"),
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"! cat file | grep struct && ! ! echo !",
+			&Program{
+				nodes: []Node{
+					&Conditional{
+						conditionalType: DAND,
+						lhs: &Not{
+							pipeline: &Pipe{
+								lhs: &SimpleCommand{
+									name:   word("cat"),
+									params: words("file"),
+								},
+								rhs: &SimpleCommand{
+									name:   word("grep"),
+									params: words("struct"),
+								},
+							},
+						},
+						rhs: &Not{
+							pipeline: &Not{
+								pipeline: &SimpleCommand{
+									name:   word("echo"),
+									params: words("!"),
+								},
 							},
 						},
 					},
@@ -40,6 +87,11 @@ func TestParseSequence(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"! | cat",
+			nil,
+			true,
+		},
 		{
 			"echo 123 & ls & pacman -Syu; nvim",
 			&Program{
@@ -51,20 +103,20 @@ func TestParseSequence(t *testing.T) {
 							lhs: &Sequence{
 								separator: AND,
 								lhs: &SimpleCommand{
-									name:   "echo",
-									params: []string{"123"},
+									name:   word("echo"),
+									params: words("123"),
 								},
 								rhs: &SimpleCommand{
-									name: "ls",
+									name: word("ls"),
 								},
 							},
 							rhs: &SimpleCommand{
-								name:   "pacman",
-								params: []string{"-Syu"},
+								name:   word("pacman"),
+								params: words("-Syu"),
 							},
 						},
 						rhs: &SimpleCommand{
-							name: "nvim",
+							name: word("nvim"),
 						},
 					},
 				},
@@ -78,7 +130,7 @@ func TestParseSequence(t *testing.T) {
 					&Sequence{
 						separator: AND,
 						lhs: &SimpleCommand{
-							name: "tmux",
+							name: word("tmux"),
 						},
 					},
 				},
@@ -146,21 +198,21 @@ func TestParseConditional(t *testing.T) {
 							conditionalType: DPIPE,
 							lhs: &Pipe{
 								lhs: &SimpleCommand{
-									name:   "find",
-									params: []string{"-name", "dir"},
+									name:   word("find"),
+									params: words("-name", "dir"),
 								},
 								rhs: &SimpleCommand{
-									name: "ls",
+									name: word("ls"),
 								},
 							},
 							rhs: &SimpleCommand{
-								name:   "ls",
-								params: []string{"/other"},
+								name:   word("ls"),
+								params: words("/other"),
 							},
 						},
 						rhs: &SimpleCommand{
-							name:   "grep",
-							params: []string{"file"},
+							name:   word("grep"),
+							params: words("file"),
 						},
 					},
 				},
@@ -176,14 +228,14 @@ func TestParseConditional(t *testing.T) {
 						lhs: &Conditional{
 							conditionalType: DAND,
 							lhs: &SimpleCommand{
-								name: "grep",
+								name: word("grep"),
 							},
 							rhs: &SimpleCommand{
-								name: "awk",
+								name: word("awk"),
 							},
 						},
 						rhs: &SimpleCommand{
-							name: "sed",
+							name: word("sed"),
 						},
 					},
 				},
@@ -230,8 +282,8 @@ func TestParseSimpleCommand(t *testing.T) {
 			&Program{
 				nodes: []Node{
 					&SimpleCommand{
-						name:   "tmux",
-						params: []string{"attach", "-t", "dotfiles"},
+						name:   word("tmux"),
+						params: words("attach", "-t", "dotfiles"),
 					},
 				},
 			},
@@ -254,3 +306,949 @@ func TestParseSimpleCommand(t *testing.T) {
 	}
 
 }
+
+func TestParseRedirection(t *testing.T) {
+	tests := []struct {
+		source     string
+		expected   *Program
+		shouldFail bool
+	}{
+		{
+			"echo hello > file",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						name:   word("echo"),
+						params: words("hello"),
+						redirections: []*Redirection{
+							{ioNumber: 1, redirectionType: GREAT, file: word("file")},
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"< input 2>> log sort -r 3<> rw",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						name:   word("sort"),
+						params: words("-r"),
+						redirections: []*Redirection{
+							{ioNumber: 0, redirectionType: LESS, file: word("input")},
+							{ioNumber: 2, redirectionType: DGREAT, file: word("log")},
+							{ioNumber: 3, redirectionType: LESSGREAT, file: word("rw")},
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"make 2>& 1 >| out <& 0",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						name: word("make"),
+						redirections: []*Redirection{
+							{ioNumber: 2, redirectionType: GREATAND, file: word("1")},
+							{ioNumber: 1, redirectionType: CLOBBER, file: word("out")},
+							{ioNumber: 0, redirectionType: LESSAND, file: word("0")},
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"> file",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						redirections: []*Redirection{
+							{ioNumber: 1, redirectionType: GREAT, file: word("file")},
+						},
+					},
+				},
+			},
+			false,
+		},
+		{
+			"echo >",
+			nil,
+			true,
+		},
+		{
+			"echo > | cat",
+			nil,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		got, err := parser.Parse()
+
+		if tt.shouldFail {
+			if err == nil {
+				t.Errorf(
+					"Expected %s to fail but got %s",
+					tt.source,
+					got,
+				)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Expected %s to succeed but got %s", tt.source, err)
+			continue
+		}
+
+		if got.String() != tt.expected.String() {
+			t.Errorf(
+				"Got mismatching ast's for \"%s\": \n got: %s \n expected: %s",
+				tt.source,
+				got,
+				tt.expected,
+			)
+		}
+	}
+}
+
+func TestParseHereDoc(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected *Program
+	}{
+		{
+			"cat <<EOF\nhello\nworld\nEOF",
+			&Program{
+				nodes: []Node{
+					&Sequence{
+						separator: SEMI,
+						lhs: &SimpleCommand{
+							name: word("cat"),
+							redirections: []*Redirection{
+								{
+									ioNumber:        0,
+									redirectionType: DLESS,
+									hereDoc: &HereDoc{
+										delimiter: "EOF",
+										body:      "hello\nworld\n",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"cat <<-EOF | grep x\n\t\tindented\n\tEOF\n",
+			&Program{
+				nodes: []Node{
+					&Sequence{
+						separator: SEMI,
+						lhs: &Pipe{
+							lhs: &SimpleCommand{
+								name: word("cat"),
+								redirections: []*Redirection{
+									{
+										ioNumber:        0,
+										redirectionType: DLESSDASH,
+										hereDoc: &HereDoc{
+											delimiter: "EOF",
+											stripTabs: true,
+											body:      "indented\n",
+										},
+									},
+								},
+							},
+							rhs: &SimpleCommand{
+								name:   word("grep"),
+								params: words("x"),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"cat <<A 3<<B\na\nA\nb\nB",
+			&Program{
+				nodes: []Node{
+					&Sequence{
+						separator: SEMI,
+						lhs: &SimpleCommand{
+							name: word("cat"),
+							redirections: []*Redirection{
+								{
+									ioNumber:        0,
+									redirectionType: DLESS,
+									hereDoc:         &HereDoc{delimiter: "A", body: "a\n"},
+								},
+								{
+									ioNumber:        3,
+									redirectionType: DLESS,
+									hereDoc:         &HereDoc{delimiter: "B", body: "b\n"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"cat <<'E'OF\n$x\nEOF",
+			&Program{
+				nodes: []Node{
+					&Sequence{
+						separator: SEMI,
+						lhs: &SimpleCommand{
+							name: word("cat"),
+							redirections: []*Redirection{
+								{
+									ioNumber:        0,
+									redirectionType: DLESS,
+									hereDoc: &HereDoc{
+										delimiter: "EOF",
+										quoted:    true,
+										body:      "$x\n",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		got, err := parser.Parse()
+
+		if err != nil {
+			t.Errorf("Expected %q to succeed but got %s", tt.source, err)
+			continue
+		}
+
+		if got.String() != tt.expected.String() {
+			t.Errorf(
+				"Got mismatching ast's for %q: \n got: %s \n expected: %s",
+				tt.source,
+				got,
+				tt.expected,
+			)
+		}
+	}
+}
+
+func TestParseIncompleteHereDoc(t *testing.T) {
+	tests := []string{
+		"cat <<EOF",
+		"cat <<EOF\n",
+		"cat <<EOF\nhello",
+		"cat <<EOF\nhello\nEOFF",
+		"cat <<A <<B\na\nA\n",
+	}
+
+	for _, source := range tests {
+		lexer := NewLexer(source)
+		parser := NewParser(lexer)
+		_, err := parser.Parse()
+
+		if !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Expected %q to be incomplete but got %v", source, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source             string
+		expectedFound      Token
+		expectedDiagnostic string
+	}{
+		{
+			"cat file & | grep",
+			Token{
+				T:      PIPE,
+				Lexeme: PIPE,
+				Span:   Span{Start: Position{11, 11, 0, 11}, End: Position{12, 12, 0, 12}},
+			},
+			"unexpected \"|\", expected command\n" +
+				" --> 1:12\n" +
+				"  |\n" +
+				"1 | cat file & | grep\n" +
+				"  |            ^",
+		},
+		{
+			"echo ok\n\tls ||\n\t&& wc",
+			Token{
+				T:      DAND,
+				Lexeme: DAND,
+				Span:   Span{Start: Position{16, 16, 2, 1}, End: Position{18, 18, 2, 3}},
+			},
+			"unexpected \"&&\", expected command\n" +
+				" --> 3:2\n" +
+				"  |\n" +
+				"3 | \t&& wc\n" +
+				"  | \t^^",
+		},
+		{
+			"for 1 in a; do :; done",
+			Token{
+				T:      WORD,
+				Lexeme: "1",
+				Parts:  []WordPart{&Literal{value: "1"}},
+				Span:   Span{Start: Position{4, 4, 0, 4}, End: Position{5, 5, 0, 5}},
+			},
+			"unexpected word \"1\", expected name\n" +
+				" --> 1:5\n" +
+				"  |\n" +
+				"1 | for 1 in a; do :; done\n" +
+				"  |     ^",
+		},
+		{
+			"case x in ) ;; esac",
+			Token{
+				T:      RPAREN,
+				Lexeme: RPAREN,
+				Span:   Span{Start: Position{10, 10, 0, 10}, End: Position{11, 11, 0, 11}},
+			},
+			"unexpected \")\", expected pattern\n" +
+				" --> 1:11\n" +
+				"  |\n" +
+				"1 | case x in ) ;; esac\n" +
+				"  |           ^",
+		},
+		{
+			"echo >",
+			Token{
+				T:      EOF,
+				Lexeme: EOF,
+				Span:   Span{Start: Position{6, 6, 0, 6}, End: Position{6, 6, 0, 6}},
+			},
+			"unexpected end of input, expected word\n" +
+				" --> 1:7\n" +
+				"  |\n" +
+				"1 | echo >\n" +
+				"  |       ^",
+		},
+		{
+			"echo 'ünï' > ; ls",
+			Token{
+				T:      SEMI,
+				Lexeme: SEMI,
+				Span:   Span{Start: Position{15, 13, 0, 13}, End: Position{16, 14, 0, 14}},
+			},
+			"unexpected \";\", expected word\n" +
+				" --> 1:14\n" +
+				"  |\n" +
+				"1 | echo 'ünï' > ; ls\n" +
+				"  |              ^",
+		},
+		{
+			"ls\necho \"`echo \\\"x\\\" ;;`\"",
+			Token{
+				T:      DSEMI,
+				Lexeme: DSEMI,
+				Span:   Span{Start: Position{21, 21, 1, 18}, End: Position{23, 23, 1, 20}},
+			},
+			"unexpected \";;\", expected \";\" or \"&\" or newline\n" +
+				" --> 2:19\n" +
+				"  |\n" +
+				"2 | echo \"`echo \\\"x\\\" ;;`\"\n" +
+				"  |                   ^^",
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		_, err := parser.Parse()
+
+		var parseError *ParseError
+		if !errors.As(err, &parseError) {
+			t.Errorf("%q: Expected a ParseError, but got %v", tt.source, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(parseError.Found, tt.expectedFound) {
+			t.Errorf(
+				"%q: Expected to find %v, but got %v",
+				tt.source,
+				tt.expectedFound,
+				parseError.Found,
+			)
+		}
+
+		if parseError.Diagnostic() != tt.expectedDiagnostic {
+			t.Errorf(
+				"%q: Expected diagnostic \n%s\n but got \n%s",
+				tt.source,
+				tt.expectedDiagnostic,
+				parseError.Diagnostic(),
+			)
+		}
+	}
+}
+
+func TestParseErrorRecovery(t *testing.T) {
+	tests := []struct {
+		source         string
+		expected       *Program
+		expectedErrors []Span
+	}{
+		{
+			"ls | | wc; echo ok",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{name: word("echo"), params: words("ok")},
+				},
+			},
+			[]Span{
+				{Start: Position{5, 5, 0, 5}, End: Position{6, 6, 0, 6}},
+			},
+		},
+		{
+			"echo a\ncat > ; echo b\n&& wc\necho c >",
+			&Program{
+				nodes: []Node{
+					&Sequence{
+						separator: SEMI,
+						lhs:       &SimpleCommand{name: word("echo"), params: words("a")},
+						rhs:       &SimpleCommand{name: word("echo"), params: words("b")},
+					},
+				},
+			},
+			[]Span{
+				{Start: Position{13, 13, 1, 6}, End: Position{14, 14, 1, 7}},
+				{Start: Position{22, 22, 2, 0}, End: Position{24, 24, 2, 2}},
+				{Start: Position{36, 36, 3, 8}, End: Position{36, 36, 3, 8}},
+			},
+		},
+		{
+			"; true",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{name: word("true")},
+				},
+			},
+			[]Span{
+				{Start: Position{0, 0, 0, 0}, End: Position{1, 1, 0, 1}},
+			},
+		},
+		{
+			"for 1 in a; do :; done; echo ok",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{name: word("echo"), params: words("ok")},
+				},
+			},
+			[]Span{
+				{Start: Position{4, 4, 0, 4}, End: Position{5, 5, 0, 5}},
+			},
+		},
+		{
+			"if then fi\necho ok",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{name: word("echo"), params: words("ok")},
+				},
+			},
+			[]Span{
+				{Start: Position{3, 3, 0, 3}, End: Position{7, 7, 0, 7}},
+			},
+		},
+		{
+			"X=1 f(){ :; }; echo ok",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						assignments: []*Assignment{{name: "X", value: word("1")}},
+						name:        word("f"),
+					},
+					&SimpleCommand{name: word("echo"), params: words("ok")},
+				},
+			},
+			[]Span{
+				{Start: Position{5, 5, 0, 5}, End: Position{6, 6, 0, 6}},
+			},
+		},
+		{
+			"while do if true; then :; fi; done | cat; echo ok",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{name: word("echo"), params: words("ok")},
+				},
+			},
+			[]Span{
+				{Start: Position{6, 6, 0, 6}, End: Position{8, 8, 0, 8}},
+			},
+		},
+		{
+			"case x in (a) fi;; (b) { (:); };; esac; echo ok",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{name: word("echo"), params: words("ok")},
+				},
+			},
+			[]Span{
+				{Start: Position{14, 14, 0, 14}, End: Position{16, 16, 0, 16}},
+			},
+		},
+		{
+			"f() ( ) | cat; echo ok",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{name: word("echo"), params: words("ok")},
+				},
+			},
+			[]Span{
+				{Start: Position{6, 6, 0, 6}, End: Position{7, 7, 0, 7}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		got, err := parser.Parse()
+
+		var parseErrors ParseErrors
+		if !errors.As(err, &parseErrors) {
+			t.Errorf("%q: Expected ParseErrors, but got %v", tt.source, err)
+			continue
+		}
+
+		spans := []Span{}
+		for _, parseError := range parseErrors {
+			spans = append(spans, parseError.Span)
+		}
+
+		if !reflect.DeepEqual(spans, tt.expectedErrors) {
+			t.Errorf("%q: Expected errors at %v, but got %v", tt.source, tt.expectedErrors, spans)
+		}
+
+		if got.String() != tt.expected.String() {
+			t.Errorf(
+				"Got mismatching ast's for %q: \n got: %s \n expected: %s",
+				tt.source,
+				got,
+				tt.expected,
+			)
+		}
+	}
+}
+
+func TestParseAssignments(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected *Program
+	}{
+		{
+			"A=1 B= C=\"x y\" cmd D=2",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						assignments: []*Assignment{
+							{name: "A", value: word("1")},
+							{name: "B", value: &Word{parts: []WordPart{}}},
+							{
+								name: "C",
+								value: &Word{parts: []WordPart{
+									&DoubleQuoted{parts: []WordPart{&Literal{value: "x y"}}},
+								}},
+							},
+						},
+						name:   word("cmd"),
+						params: words("D=2"),
+					},
+				},
+			},
+		},
+		{
+			"PATH=/bin > out",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						assignments: []*Assignment{{name: "PATH", value: word("/bin")}},
+						redirections: []*Redirection{
+							{ioNumber: 1, redirectionType: GREAT, file: word("out")},
+						},
+					},
+				},
+			},
+		},
+		{
+			"'A'=1 =2 1A=3",
+			&Program{
+				nodes: []Node{
+					&SimpleCommand{
+						name: &Word{parts: []WordPart{
+							&SingleQuoted{value: "A"},
+							&Literal{value: "=1"},
+						}},
+						params: words("=2", "1A=3"),
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		got, err := parser.Parse()
+
+		if err != nil {
+			t.Errorf("Expected %q to succeed but got %s", tt.source, err)
+			continue
+		}
+
+		if got.String() != tt.expected.String() {
+			t.Errorf(
+				"Got mismatching ast's for %q: \n got: %s \n expected: %s",
+				tt.source,
+				got,
+				tt.expected,
+			)
+		}
+	}
+}
+
+func TestParseCompoundCommands(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected *Program
+	}{
+		{
+			"if true; then echo a; elif false; then echo b; else echo c; fi",
+			&Program{
+				nodes: []Node{
+					&IfClause{
+						condition: &Sequence{
+							separator: SEMI,
+							lhs:       &SimpleCommand{name: word("true")},
+						},
+						consequent: &Sequence{
+							separator: SEMI,
+							lhs:       &SimpleCommand{name: word("echo"), params: words("a")},
+						},
+						alternative: &IfClause{
+							condition: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("false")},
+							},
+							consequent: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("echo"), params: words("b")},
+							},
+							alternative: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("echo"), params: words("c")},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"while\ntrue\ndo\necho then fi\ndone > out",
+			&Program{
+				nodes: []Node{
+					&RedirectedCommand{
+						command: &WhileClause{
+							condition: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("true")},
+							},
+							body: &Sequence{
+								separator: SEMI,
+								lhs: &SimpleCommand{
+									name:   word("echo"),
+									params: words("then", "fi"),
+								},
+							},
+						},
+						redirections: []*Redirection{
+							{ioNumber: 1, redirectionType: GREAT, file: word("out")},
+						},
+					},
+				},
+			},
+		},
+		{
+			"until false; do break; done | cat",
+			&Program{
+				nodes: []Node{
+					&Pipe{
+						lhs: &WhileClause{
+							condition: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("false")},
+							},
+							body: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("break")},
+							},
+							until: true,
+						},
+						rhs: &SimpleCommand{name: word("cat")},
+					},
+				},
+			},
+		},
+		{
+			"for x in a b\ndo echo; done; for y do echo; done",
+			&Program{
+				nodes: []Node{
+					&Sequence{
+						separator: SEMI,
+						lhs: &ForClause{
+							name:  "x",
+							words: words("a", "b"),
+							body: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("echo")},
+							},
+						},
+						rhs: &ForClause{
+							name: "y",
+							body: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("echo")},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"case $x in\n(a | b) echo ab;;\n*) ;;\nc) echo c\nesac",
+			&Program{
+				nodes: []Node{
+					&CaseClause{
+						word: word("$x"),
+						items: []*CaseItem{
+							{
+								patterns: words("a", "b"),
+								body:     &SimpleCommand{name: word("echo"), params: words("ab")},
+							},
+							{patterns: words("*")},
+							{
+								patterns: words("c"),
+								body: &Sequence{
+									separator: SEMI,
+									lhs: &SimpleCommand{
+										name:   word("echo"),
+										params: words("c"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"(cd /; ls) > out && { echo ok; }",
+			&Program{
+				nodes: []Node{
+					&Conditional{
+						conditionalType: DAND,
+						lhs: &RedirectedCommand{
+							command: &Subshell{
+								body: &Sequence{
+									separator: SEMI,
+									lhs:       &SimpleCommand{name: word("cd"), params: words("/")},
+									rhs:       &SimpleCommand{name: word("ls")},
+								},
+							},
+							redirections: []*Redirection{
+								{ioNumber: 1, redirectionType: GREAT, file: word("out")},
+							},
+						},
+						rhs: &BraceGroup{
+							body: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("echo"), params: words("ok")},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"echo if; 'if' true",
+			&Program{
+				nodes: []Node{
+					&Sequence{
+						separator: SEMI,
+						lhs:       &SimpleCommand{name: word("echo"), params: words("if")},
+						rhs: &SimpleCommand{
+							name:   &Word{parts: []WordPart{&SingleQuoted{value: "if"}}},
+							params: words("true"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		got, err := parser.Parse()
+
+		if err != nil {
+			t.Errorf("Expected %q to succeed but got %s", tt.source, err)
+			continue
+		}
+
+		if got.String() != tt.expected.String() {
+			t.Errorf(
+				"Got mismatching ast's for %q: \n got: %s \n expected: %s",
+				tt.source,
+				got,
+				tt.expected,
+			)
+		}
+	}
+}
+
+func TestParseIncompleteCompoundCommands(t *testing.T) {
+	tests := []struct {
+		source     string
+		incomplete bool
+	}{
+		{"if true; then\n", true},
+		{"if true; then echo\nelse", true},
+		{"while true\ndo\n", true},
+		{"for x in a b\n", true},
+		{"case a in\na) echo;;\n", true},
+		{"true &&\n", true},
+		{"echo |\n", true},
+		{"f() {\n", true},
+		{"(echo\n", true},
+		{"echo $(if true\n", true},
+		{"echo $(echo; fi)", false},
+		{"echo `echo |`", false},
+		{"if true; then fi", false},
+		{"fi", false},
+		{"for 1x in a; do echo; done", false},
+		{"case a in a) echo; done", false},
+		{"if true; then echo; fi; done", false},
+		{"f() echo", false},
+		{"function 'f' { echo; }", false},
+		{"(echo; }", false},
+		{"{ echo; )", false},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		_, err := parser.Parse()
+
+		if err == nil {
+			t.Errorf("Expected %q to fail", tt.source)
+			continue
+		}
+
+		if errors.Is(err, ErrIncomplete) != tt.incomplete {
+			t.Errorf("%q: Expected incomplete to be %t, but got %v", tt.source, tt.incomplete, err)
+		}
+	}
+}
+
+func TestParseFunctions(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected *Program
+	}{
+		{
+			"greet() { echo hi; }",
+			&Program{
+				nodes: []Node{
+					&FunctionDef{
+						name: "greet",
+						body: &BraceGroup{
+							body: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("echo"), params: words("hi")},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"function greet\n{\necho hi\n} 2> err",
+			&Program{
+				nodes: []Node{
+					&FunctionDef{
+						name: "greet",
+						body: &RedirectedCommand{
+							command: &BraceGroup{
+								body: &Sequence{
+									separator: SEMI,
+									lhs: &SimpleCommand{
+										name:   word("echo"),
+										params: words("hi"),
+									},
+								},
+							},
+							redirections: []*Redirection{
+								{ioNumber: 2, redirectionType: GREAT, file: word("err")},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			"function check () while false; do :; done",
+			&Program{
+				nodes: []Node{
+					&FunctionDef{
+						name: "check",
+						body: &WhileClause{
+							condition: &Sequence{
+								separator: SEMI,
+								lhs:       &SimpleCommand{name: word("false")},
+							},
+							body: &Sequence{separator: SEMI, lhs: &SimpleCommand{name: word(":")}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		lexer := NewLexer(tt.source)
+		parser := NewParser(lexer)
+		got, err := parser.Parse()
+
+		if err != nil {
+			t.Errorf("Expected %q to succeed but got %s", tt.source, err)
+			continue
+		}
+
+		if got.String() != tt.expected.String() {
+			t.Errorf(
+				"Got mismatching ast's for %q: \n got: %s \n expected: %s",
+				tt.source,
+				got,
+				tt.expected,
+			)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package interpreter
+
+import (
+	"strings"
+	"unicode"
+)
+
+var characterClasses = map[string]func(rune) bool{
+	"alnum": func(c rune) bool { return unicode.IsLetter(c) || unicode.IsDigit(c) },
+	"alpha": unicode.IsLetter,
+	"blank": func(c rune) bool { return c == ' ' || c == '\t' },
+	"cntrl": unicode.IsControl,
+	"digit": unicode.IsDigit,
+	"graph": func(c rune) bool { return unicode.IsGraphic(c) && !unicode.IsSpace(c) },
+	"lower": unicode.IsLower,
+	"print": unicode.IsPrint,
+	"punct": unicode.IsPunct,
+	"space": unicode.IsSpace,
+	"upper": unicode.IsUpper,
+	"xdigit": func(c rune) bool {
+		return strings.ContainsRune("0123456789abcdefABCDEF", c)
+	},
+}
+
+// matchPattern tells if str matches pattern, as in the pattern matching
+// notation of the shell: "*" matches any string, "?" any character and
+// "[...]" any character in the brackets. A backslash makes the character
+// after it match only itself.
+func matchPattern(pattern, str string) bool {
+	p, s := []rune(pattern), []rune(str)
+	pn, sn := 0, 0
+	// Where to start again when what follows the last "*" doesn't match.
+	star, starMatch := -1, 0
+
+	for sn < len(s) {
+		if pn < len(p) && p[pn] == '*' {
+			star, starMatch = pn, sn
+			pn++
+			continue
+		}
+
+		if pn < len(p) {
+			if width, ok := matchCharacter(p[pn:], s[sn]); ok {
+				pn += width
+				sn++
+				continue
+			}
+		}
+
+		if star == -1 {
+			return false
+		}
+
+		starMatch++
+		pn, sn = star+1, starMatch
+	}
+
+	for pn < len(p) && p[pn] == '*' {
+		pn++
+	}
+
+	return pn == len(p)
+}
+
+// matchCharacter matches c against the pattern element at the start of p,
+// returning how long the element is.
+func matchCharacter(p []rune, c rune) (int, bool) {
+	switch p[0] {
+	case '?':
+		return 1, true
+	case '[':
+		// A bracket that is never closed is just a "[".
+		if width, matched, ok := matchBracket(p, c); ok {
+			return width, matched
+		}
+	case '\\':
+		if len(p) > 1 {
+			return 2, p[1] == c
+		}
+	}
+
+	return 1, p[0] == c
+}
+
+// matchBracket matches c against a bracket expression, which can hold
+// characters, ranges like "a-z" and classes like "[:alpha:]". A "!" or "^"
+// at the start negates it. It reports false if the bracket isn't closed.
+func matchBracket(p []rune, c rune) (int, bool, bool) {
+	n := 1
+	negated := false
+	matched := false
+
+	if n < len(p) && (p[n] == '!' || p[n] == '^') {
+		negated = true
+		n++
+	}
+
+	for start := n; n < len(p); n++ {
+		if p[n] == ']' && n > start {
+			return n + 1, matched != negated, true
+		}
+
+		if p[n] == '[' && n+1 < len(p) && p[n+1] == ':' {
+			end := strings.Index(string(p[n+2:]), ":]")
+
+			if end != -1 {
+				name := string(p[n+2:])[:end]
+				class, ok := characterClasses[name]
+				matched = matched || ok && class(c)
+				n += 2 + len([]rune(name)) + 1
+				continue
+			}
+		}
+
+		low := p[n]
+
+		if low == '\\' && n+1 < len(p) {
+			n++
+			low = p[n]
+		}
+
+		high := low
+
+		if n+2 < len(p) && p[n+1] == '-' && p[n+2] != ']' {
+			n += 2
+			high = p[n]
+
+			if high == '\\' && n+1 < len(p) {
+				n++
+				high = p[n]
+			}
+		}
+
+		matched = matched || low <= c && c <= high
+	}
+
+	return 0, false, false
+}
@@ -0,0 +1,43 @@
+package interpreter
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		str      string
+		expected bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"*", "", true},
+		{"*", "anything/at all", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"*.go", "main.go", true},
+		{"*.go", "main.go.bak", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"?", "ü", true},
+		{"??", "a", false},
+		{"[abc]", "b", true},
+		{"[!abc]", "b", false},
+		{"[^abc]", "d", true},
+		{"[a-z]x", "qx", true},
+		{"[a-z]", "Q", false},
+		{"[]]", "]", true},
+		{"[!]]", "a", true},
+		{"[[:digit:]][[:alpha:]]", "1a", true},
+		{"[[:upper:]]", "a", false},
+		{"[abc", "[abc", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{`[\]]`, "]", true},
+		{`a\`, `a\`, true},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.str); got != tt.expected {
+			t.Errorf("%q against %q: Expected %t, but got %t", tt.pattern, tt.str, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+var errBadFileDescriptor = errors.New("Bad file descriptor")
+
+// redirect applies redirections to the file descriptors of the interpreter.
+// The returned function undoes them and closes every file that was opened.
+func (i *Interpreter) redirect(redirections []*Redirection) (func(), error) {
+	saved := map[int]*os.File{}
+	opened := []*os.File{}
+
+	restore := func() {
+		for fd, file := range saved {
+			i.setFile(fd, file)
+		}
+
+		for _, file := range opened {
+			file.Close()
+		}
+	}
+
+	for _, redirection := range redirections {
+		fd := redirection.ioNumber
+
+		if _, ok := saved[fd]; !ok {
+			saved[fd] = i.file(fd)
+		}
+
+		if redirection.hereDoc != nil {
+			file, err := i.hereDocument(redirection.hereDoc)
+
+			if err != nil {
+				restore()
+				return nil, err
+			}
+
+			opened = append(opened, file)
+			i.setFile(fd, file)
+			continue
+		}
+
+		target, err := i.expandWord(redirection.file)
+
+		if err != nil {
+			restore()
+			return nil, err
+		}
+
+		if redirection.redirectionType == LESSAND || redirection.redirectionType == GREATAND {
+			file, err := i.duplicate(target)
+
+			if err != nil {
+				restore()
+				return nil, fmt.Errorf("%s: %w", target, err)
+			}
+
+			i.setFile(fd, file)
+			continue
+		}
+
+		file, err := i.open(redirection.redirectionType, target)
+
+		if err != nil {
+			restore()
+			return nil, fmt.Errorf("%s: %w", target, unwrapPathError(err))
+		}
+
+		opened = append(opened, file)
+		i.setFile(fd, file)
+	}
+
+	return restore, nil
+}
+
+func (i *Interpreter) open(redirectionType tokenType, target string) (*os.File, error) {
+	name := resolve(i.dir, target)
+
+	switch redirectionType {
+	case LESS:
+		return os.Open(name)
+	case GREAT:
+		if i.Options.Noclobber {
+			return openNoClobber(name)
+		}
+
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	case CLOBBER:
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	case DGREAT:
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	case LESSGREAT:
+		return os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0666)
+	}
+
+	return nil, fmt.Errorf("unknown redirection %s", redirectionType)
+}
+
+// openNoClobber refuses to truncate existing regular files. Other kinds of
+// files, like /dev/null, can still be written to.
+func openNoClobber(name string) (*os.File, error) {
+	info, err := os.Stat(name)
+
+	if err != nil {
+		return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	}
+
+	if info.Mode().IsRegular() {
+		return nil, errors.New("cannot overwrite existing file")
+	}
+
+	return os.OpenFile(name, os.O_WRONLY, 0666)
+}
+
+// duplicate handles the word of "<&" and ">&". It's either a file
+// descriptor to be copied or "-", which closes it.
+func (i *Interpreter) duplicate(word string) (*os.File, error) {
+	if word == "-" {
+		return nil, nil
+	}
+
+	fd, err := strconv.Atoi(word)
+
+	if err != nil || fd < 0 {
+		return nil, errors.New("ambiguous redirect")
+	}
+
+	file := i.file(fd)
+
+	if file == nil {
+		return nil, errBadFileDescriptor
+	}
+
+	return file, nil
+}
+
+// hereDocument returns a pipe the body of hereDoc can be read from. The body
+// is written by another goroutine, so it doesn't matter if it doesn't fit in
+// the pipe's buffer.
+func (i *Interpreter) hereDocument(hereDoc *HereDoc) (*os.File, error) {
+	body := hereDoc.body
+
+	// Unquoted bodies are expanded like they were inside double quotes.
+	if !hereDoc.quoted {
+		fields, err := i.expandParts(hereDoc.parts, true)
+
+		if err != nil {
+			return nil, err
+		}
+
+		body = joinFields(fields, " ", field.String)
+	}
+
+	reader, writer, err := os.Pipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer writer.Close()
+		writer.WriteString(body)
+	}()
+
+	return reader, nil
+}
@@ -0,0 +1,195 @@
+package interpreter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RunLines runs the lines next returns, a command at a time, so the
+// commands before a syntax error are still run and those that read the
+// input get what comes after them. With verbose on, the lines are written
+// to the standard error as they're read. It stops at the end of the
+// input, at a syntax error, which makes the status 2, or when the script
+// exits. Errors are reported with where, the name of the script if it has
+// one, and the line they're on, like "~/.aroshrc:3: ".
+func (i *Interpreter) RunLines(where string, next func() (string, error)) int {
+	i.runLines(where, next, func(program *Program) error {
+		i.Run(program)
+
+		if i.exited {
+			return errExit
+		}
+
+		return nil
+	})
+
+	return i.status
+}
+
+// runLines is what RunLines and the . builtin have in common. run runs
+// each command, and what it returns stops the lines and is returned.
+func (i *Interpreter) runLines(
+	where string,
+	next func() (string, error),
+	run func(program *Program) error,
+) error {
+	if where != "" {
+		where += ":"
+	}
+
+	location := i.location
+	defer func() { i.location = location }()
+
+	source, line := "", 0
+
+	for {
+		text, err := next()
+		source += text
+
+		if i.Options.Verbose && text != "" {
+			fmt.Fprintln(i.Stderr, strings.TrimSuffix(text, "\n"))
+		}
+
+		done := err != nil
+
+		if done && !errors.Is(err, io.EOF) {
+			fmt.Fprintf(i.Stderr, "arosh: %s%d: %s\n", where, line+1, err)
+			return nil
+		}
+
+		program, err := NewParser(NewLexer(source)).Parse()
+
+		if errors.Is(err, ErrIncomplete) && !done {
+			continue
+		}
+
+		// A line continuation at the end of the input has nothing left to
+		// continue, so it's only dropped.
+		if errors.Is(err, ErrIncomplete) && strings.HasSuffix(source, "\\\n") {
+			program, err = NewParser(NewLexer(strings.TrimSuffix(source, "\\\n"))).Parse()
+		}
+
+		if err != nil {
+			i.reportSyntax(where, line, err)
+			i.status = 2
+
+			return nil
+		}
+
+		i.location = fmt.Sprintf("%s%d: ", where, line+1)
+		line += strings.Count(source, "\n")
+		source = ""
+
+		if err := run(program); err != nil || done {
+			return err
+		}
+	}
+}
+
+// reportSyntax writes the syntax errors of a command that starts after
+// line lines of the script, with the lines they're on in the script.
+func (i *Interpreter) reportSyntax(where string, line int, err error) {
+	var parseErrors ParseErrors
+
+	if !errors.As(err, &parseErrors) {
+		fmt.Fprintf(i.Stderr, "arosh: %s%d: %s\n", where, line+1, err)
+		return
+	}
+
+	for _, parseError := range parseErrors {
+		parseError.Span.Start.Line += line
+		fmt.Fprintf(i.Stderr, "arosh: %s%s\n", where, parseError)
+	}
+}
+
+// StringLines returns the lines of source one at a time, with their
+// newlines, and io.EOF with the last one.
+func StringLines(source string) func() (string, error) {
+	return func() (string, error) {
+		end := strings.IndexByte(source, '\n')
+
+		if end == -1 {
+			line := source
+			source = ""
+
+			return line, io.EOF
+		}
+
+		line := source[:end+1]
+		source = source[end+1:]
+
+		return line, nil
+	}
+}
+
+// dotBuiltin runs the commands of a file in the shell itself, with the
+// operands after it as the positional parameters. return leaves the file.
+func (i *Interpreter) dotBuiltin(args []string) error {
+	if len(args) < 2 {
+		i.errorf(".: filename argument required")
+		i.status = 2
+
+		return nil
+	}
+
+	path := i.sourcePath(args[1])
+	source, err := os.ReadFile(resolve(i.dir, path))
+
+	if err != nil {
+		i.errorf(".: %s: %s", args[1], unwrapPathError(err))
+		i.status = 1
+
+		return nil
+	}
+
+	positional, sources := i.positional, i.sources
+	i.sourcing++
+
+	if len(args) > 2 {
+		i.positional = args[2:]
+	}
+
+	defer func() {
+		i.sources = sources
+		i.sourcing--
+
+		if len(args) > 2 {
+			i.positional = positional
+		}
+	}()
+
+	i.status = 0
+	err = i.runLines(path, StringLines(string(source)), i.run)
+
+	if ret, ok := err.(*returnError); ok {
+		i.status = ret.status
+		err = nil
+	}
+
+	return err
+}
+
+// sourcePath finds the file the . builtin runs. A name without a slash is
+// looked for in PATH, and then in the working directory.
+func (i *Interpreter) sourcePath(file string) string {
+	if strings.Contains(file, "/") {
+		return file
+	}
+
+	path, _ := i.env.Get("PATH")
+
+	for _, dir := range filepath.SplitList(path) {
+		candidate := filepath.Join(dir, file)
+		info, err := os.Stat(resolve(i.dir, candidate))
+
+		if err == nil && info.Mode().IsRegular() {
+			return candidate
+		}
+	}
+
+	return file
+}
@@ -0,0 +1,92 @@
+package interpreter
+
+import (
+	"maps"
+
+	"github.com/marcos-brito/arosh/internal/builtins"
+	"github.com/marcos-brito/arosh/internal/env"
+	"golang.org/x/sys/unix"
+)
+
+// These methods make the interpreter a builtins.Shell.
+
+func (i *Interpreter) Env() *env.Env {
+	return i.env
+}
+
+func (i *Interpreter) Dir() string {
+	return i.dir
+}
+
+func (i *Interpreter) SetDir(dir string) {
+	i.dir = dir
+}
+
+func (i *Interpreter) Positional() []string {
+	return i.positional
+}
+
+// Exit makes the interpreter stop once the running command is done. Only
+// the subshell it's called in exits.
+func (i *Interpreter) Exit(status int) {
+	i.status = status
+	i.exited = true
+}
+
+// Exited tells if the exit builtin was run.
+func (i *Interpreter) Exited() bool {
+	return i.exited
+}
+
+func (i *Interpreter) UnsetFunction(name string) {
+	delete(i.functions, name)
+}
+
+func (i *Interpreter) InFunction() bool {
+	return i.calls > 0
+}
+
+func (i *Interpreter) ListOptions() map[string]bool {
+	return i.Options.List()
+}
+
+func (i *Interpreter) SetOption(name string, on bool) error {
+	return i.Options.Set(name, on)
+}
+
+func (i *Interpreter) SetFlag(flag rune, on bool) error {
+	return i.Options.SetFlag(flag, on)
+}
+
+func (i *Interpreter) LineReader() builtins.LineReader {
+	return i.Editor
+}
+
+func (i *Interpreter) Jobs() builtins.Jobs {
+	return i.jobs
+}
+
+// SetTrap sets the action run on condition, which is a signal name without
+// the SIG prefix or one of EXIT, ERR, DEBUG and RETURN. An empty action
+// ignores the signal.
+func (i *Interpreter) SetTrap(condition string, action string) {
+	i.traps[condition] = action
+
+	if sig := unix.SignalNum("SIG" + condition); sig != 0 {
+		i.dispose(sig)
+	}
+}
+
+// ResetTrap removes the trap of condition. Signals go back to how the
+// shell handles them.
+func (i *Interpreter) ResetTrap(condition string) {
+	delete(i.traps, condition)
+
+	if sig := unix.SignalNum("SIG" + condition); sig != 0 {
+		i.dispose(sig)
+	}
+}
+
+func (i *Interpreter) Traps() map[string]string {
+	return maps.Clone(i.traps)
+}
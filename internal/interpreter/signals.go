@@ -0,0 +1,297 @@
+package interpreter
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// errInterrupt unwinds evaluation up to Run when an interactive shell gets
+// SIGINT, so the rest of the command line is dropped.
+var errInterrupt = errors.New("interrupt")
+
+// interactiveSignals are handled by an interactive shell itself when they
+// have no trap: SIGINT interrupts the command running, the others are
+// dropped so the shell is neither stopped nor killed by them.
+var interactiveSignals = []syscall.Signal{
+	syscall.SIGINT,
+	syscall.SIGQUIT,
+	syscall.SIGTSTP,
+	syscall.SIGTTIN,
+	syscall.SIGTTOU,
+}
+
+type disposition int
+
+const (
+	defaulted disposition = iota
+	caught
+	ignored
+)
+
+// harmlessSignals don't kill a process that gets them without a handler.
+var harmlessSignals = []syscall.Signal{
+	syscall.SIGCHLD,
+	syscall.SIGCONT,
+	syscall.SIGSTOP,
+	syscall.SIGTSTP,
+	syscall.SIGTTIN,
+	syscall.SIGTTOU,
+	syscall.SIGURG,
+	syscall.SIGWINCH,
+}
+
+// signals gets the signals the shell catches, which it acts on between
+// commands. Signals are set up for the whole process, so only the
+// interpreter the shell started with has them. Subshells run in the same
+// process and can't have handlers of their own: the traps they set on
+// signals only run for the ones sent to their job with kill.
+type signals struct {
+	mu           sync.Mutex
+	once         sync.Once
+	incoming     chan os.Signal
+	pending      []syscall.Signal
+	dispositions map[syscall.Signal]disposition
+	// jobs is where SIGINT is sent on to the job in the foreground.
+	jobs *jobTable
+}
+
+func newSignals(jobs *jobTable) *signals {
+	return &signals{dispositions: map[syscall.Signal]disposition{}, jobs: jobs}
+}
+
+func (s *signals) set(sig syscall.Signal, disposition disposition) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dispositions[sig] = disposition
+	s.apply(sig)
+}
+
+// restore sets sig back to how the shell wants it, after something else
+// changed it.
+func (s *signals) restore(sig syscall.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(sig)
+}
+
+func (s *signals) apply(sig syscall.Signal) {
+	switch s.dispositions[sig] {
+	case caught:
+		s.once.Do(func() {
+			s.incoming = make(chan os.Signal, 16)
+			go s.receive()
+		})
+
+		signal.Notify(s.incoming, sig)
+	case ignored:
+		signal.Ignore(sig)
+	default:
+		signal.Reset(sig)
+	}
+}
+
+// receive queues the signals caught. SIGINT is also sent to the job in the
+// foreground, which doesn't get it from the terminal when it's sent to the
+// shell alone.
+func (s *signals) receive() {
+	for sig := range s.incoming {
+		if sig == syscall.SIGINT {
+			s.jobs.signalForeground(syscall.SIGINT)
+		}
+
+		s.raise(sig.(syscall.Signal))
+	}
+}
+
+// catches tells if sig is caught, instead of ignored or left to kill the
+// process.
+func (s *signals) catches(sig syscall.Signal) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dispositions[sig] == caught
+}
+
+func (s *signals) raise(sig syscall.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, sig)
+}
+
+func (s *signals) take() []syscall.Signal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+
+	return pending
+}
+
+// SetInteractive makes the shell behave like an interactive one, which
+// handles the interactiveSignals itself.
+func (i *Interpreter) SetInteractive() {
+	i.interactive = true
+
+	for _, sig := range interactiveSignals {
+		i.dispose(sig)
+	}
+}
+
+// dispose sets up how the process reacts to sig, after its trap changed.
+func (i *Interpreter) dispose(sig syscall.Signal) {
+	if i.signals == nil {
+		return
+	}
+
+	action, trapped := i.traps[signalName(sig)]
+
+	switch {
+	case trapped && action == "":
+		i.signals.set(sig, ignored)
+	case trapped || i.interactive && slices.Contains(interactiveSignals, sig):
+		i.signals.set(sig, caught)
+	default:
+		i.signals.set(sig, defaulted)
+	}
+}
+
+// Raise sends sig to the shell. The signals it catches are queued right
+// away, rather than once they get through, so they're acted on before the
+// next command.
+func (i *Interpreter) Raise(sig syscall.Signal) error {
+	if i.signals != nil && i.signals.catches(sig) {
+		i.signals.raise(sig)
+		return nil
+	}
+
+	return unix.Kill(os.Getpid(), sig)
+}
+
+// handleSignals runs the traps of the signals caught since it was last
+// called, unless a trap is running already. SIGINT without a trap
+// interrupts the shell, unless it's idle between command lines.
+func (i *Interpreter) handleSignals(idle bool) error {
+	if err := i.handleJobSignals(); err != nil {
+		return err
+	}
+
+	if i.signals == nil || i.trapping {
+		return nil
+	}
+
+	interrupted := false
+
+	for _, sig := range i.signals.take() {
+		if _, trapped := i.traps[signalName(sig)]; trapped {
+			if err := i.trap(signalName(sig)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		interrupted = interrupted || sig == syscall.SIGINT && !idle
+	}
+
+	if interrupted {
+		i.status = 128 + int(syscall.SIGINT)
+		return errInterrupt
+	}
+
+	return nil
+}
+
+// handleJobSignals acts on the signals sent with kill to the job the
+// commands run belong to, like a shell running it in a process of its own
+// would. Their traps are run, and a signal without one that kills
+// processes stops every shell running the job, with 128 plus the signal
+// as their status.
+func (i *Interpreter) handleJobSignals() error {
+	if i.job == nil || i.trapping {
+		return nil
+	}
+
+	signals, killedBy := i.job.table.takeSignals(i.job)
+
+	for _, sig := range signals {
+		action, trapped := i.traps[signalName(sig)]
+
+		switch {
+		case trapped && action != "":
+			if err := i.trap(signalName(sig)); err != nil {
+				return err
+			}
+		case !trapped && killedBy == 0 && !slices.Contains(harmlessSignals, sig):
+			i.job.table.killed(i.job, sig)
+			killedBy = sig
+		}
+	}
+
+	if killedBy == 0 {
+		return nil
+	}
+
+	i.status = 128 + int(killedBy)
+	i.exited = true
+
+	return errExit
+}
+
+// trap runs the action set for condition, if there's any. The status is
+// left as it was, unless the action exits. Traps aren't set off by the
+// commands of other traps.
+func (i *Interpreter) trap(condition string) error {
+	action, ok := i.traps[condition]
+
+	if !ok || action == "" || i.trapping {
+		return nil
+	}
+
+	if condition == "EXIT" {
+		delete(i.traps, condition)
+	}
+
+	program, err := NewParser(NewLexer(action)).Parse()
+
+	if err != nil {
+		i.errorf("trap: %s", err)
+		return nil
+	}
+
+	status, sources, exited := i.status, i.sources, i.exited
+	i.trapping, i.exited = true, false
+
+	defer func() {
+		i.trapping, i.sources = false, sources
+	}()
+
+	if err := i.run(program); err != nil {
+		return err
+	}
+
+	i.status, i.exited = status, exited
+
+	return nil
+}
+
+// RunExitTrap runs the EXIT trap, which is only run once. It's up to
+// whoever runs the shell to call it before exiting.
+func (i *Interpreter) RunExitTrap() {
+	i.trap("EXIT")
+}
+
+// signalName is the name of sig as trap takes it, without the SIG prefix.
+func signalName(sig syscall.Signal) string {
+	return strings.TrimPrefix(unix.SignalName(sig), "SIG")
+}
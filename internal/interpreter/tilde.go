@@ -0,0 +1,108 @@
+package interpreter
+
+import (
+	"os/user"
+	"strings"
+)
+
+// expandTilde replaces the tilde prefixes of a word with the directories
+// they name. A tilde prefix is an unquoted "~" at the start of the word, or
+// after a ":" in assignments, up to the next "/". The directories are
+// quoted, so they aren't split or globbed.
+func (i *Interpreter) expandTilde(parts []WordPart, assignment bool) []WordPart {
+	expanded := []WordPart{}
+
+	for n, part := range parts {
+		literal, ok := part.(*Literal)
+
+		if !ok || n > 0 && !assignment {
+			expanded = append(expanded, part)
+			continue
+		}
+
+		pieces := []string{literal.value}
+
+		if assignment {
+			pieces = strings.Split(literal.value, ":")
+		}
+
+		text := ""
+
+		for k, piece := range pieces {
+			if k > 0 {
+				text += ":"
+			}
+
+			// The last piece runs into the next part, which can't be in a
+			// tilde prefix since it's either quoted or an expansion.
+			start := n == 0 || k > 0
+			last := k == len(pieces)-1 && n < len(parts)-1
+			prefix, rest, found := strings.Cut(piece, "/")
+
+			if !start || !strings.HasPrefix(prefix, "~") || !found && last {
+				text += piece
+				continue
+			}
+
+			dir, ok := i.tildeDirectory(prefix[1:])
+
+			if !ok {
+				text += piece
+				continue
+			}
+
+			if text != "" {
+				expanded = append(expanded, &Literal{value: text})
+			}
+
+			expanded = append(expanded, &SingleQuoted{value: dir})
+			text = ""
+
+			if found {
+				text = "/" + rest
+			}
+		}
+
+		if text != "" {
+			expanded = append(expanded, &Literal{value: text})
+		}
+	}
+
+	return expanded
+}
+
+// tildeDirectory returns the directory named by what follows a "~": the
+// home directory of the user, or of the one named, the working directory
+// for "+" and the previous one for "-".
+func (i *Interpreter) tildeDirectory(name string) (string, bool) {
+	switch name {
+	case "":
+		if home, ok := i.env.Get("HOME"); ok {
+			return home, true
+		}
+
+		current, err := user.Current()
+
+		if err != nil {
+			return "", false
+		}
+
+		return current.HomeDir, true
+	case "+":
+		if pwd, ok := i.env.Get("PWD"); ok {
+			return pwd, true
+		}
+
+		return i.dir, true
+	case "-":
+		return i.env.Get("OLDPWD")
+	}
+
+	named, err := user.Lookup(name)
+
+	if err != nil {
+		return "", false
+	}
+
+	return named.HomeDir, true
+}
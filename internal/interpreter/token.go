@@ -5,11 +5,31 @@ type tokenType = string
 type Token struct {
 	T      tokenType
 	Lexeme string
+	// Parts keeps how each part of a WORD was quoted.
+	Parts []WordPart
+	Span  Span
+}
+
+// Position is a location in the source. Offset is counted in bytes and Rune
+// in runes. Line and Column start at 0, and Column is counted in runes.
+type Position struct {
+	Offset int
+	Rune   int
+	Line   int
+	Column int
+}
+
+// Span is the part of the source a token was read from. End is the position
+// right after the last character.
+type Span struct {
+	Start Position
+	End   Position
 }
 
 const (
-	EOF  = "EOF"
-	WORD = "WORD"
+	EOF     = "EOF"
+	WORD    = "WORD"
+	NEWLINE = "NEWLINE"
 
 	IO_NUMBER = "IO_NUMBER"
 
@@ -25,6 +45,8 @@ const (
 
 	SEMI     = ";"
 	DSEMI    = ";;"
+	LPAREN   = "("
+	RPAREN   = ")"
 	DLESS    = "<<"
 	LESS     = "<"
 	GREAT    = ">"
@@ -77,6 +99,9 @@ var reservedWords = map[string]string{
 	"until":    UNTIL,
 	"for":      FOR,
 	"in":       IN,
+	"{":        LBRACE,
+	"}":        RBRACE,
+	"!":        BANG,
 }
 
 func LookupReservedWord(word string) (tokenType, bool) {
@@ -0,0 +1,52 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marcos-brito/arosh/internal/builtins"
+)
+
+// trace writes a simple command about to run to the standard error, with
+// xtrace on. The assignments are shown with the values they were given
+// and the arguments quoted, so the line can be read back by the shell.
+func (i *Interpreter) trace(assignments []*Assignment, args []string) {
+	if !i.Options.Xtrace || len(assignments) == 0 && len(args) == 0 {
+		return
+	}
+
+	words := []string{}
+
+	for _, assignment := range assignments {
+		value, _ := i.env.Get(assignment.name)
+		words = append(words, assignment.name+"="+builtins.Quote(value))
+	}
+
+	for _, arg := range args {
+		words = append(words, builtins.Quote(arg))
+	}
+
+	fmt.Fprintf(i.Stderr, "%s%s\n", i.tracePrefix(), strings.Join(words, " "))
+}
+
+// tracePrefix is $PS4 after parameter expansion, "+ " if it's unset. The
+// expansions are done without xtrace and nounset, so they are not traced
+// nor fail.
+func (i *Interpreter) tracePrefix() string {
+	ps4, ok := i.env.Get("PS4")
+
+	if !ok {
+		return "+ "
+	}
+
+	options := i.Options
+	i.Options.Xtrace, i.Options.Nounset = false, false
+	fields, err := i.expandParts(hereDocParts(ps4), true)
+	i.Options = options
+
+	if err != nil {
+		return ps4
+	}
+
+	return joinFields(fields, " ", field.String)
+}
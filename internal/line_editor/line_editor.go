@@ -3,6 +3,8 @@ package lineEditor
 import (
 	"fmt"
 	"slices"
+	"strings"
+	"sync"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	tea "github.com/charmbracelet/bubbletea"
@@ -11,6 +13,7 @@ import (
 )
 
 const DEFAULT_PROMPT = "$ "
+const DEFAULT_CONTINUATION_PROMPT = "> "
 
 type Action = func() (tea.Model, tea.Cmd)
 
@@ -20,22 +23,31 @@ type Binding struct {
 }
 
 type LineEditor struct {
-	text     []rune
-	prompt   string
-	position int
-	keyMap   map[string]Binding
-	Events   *event.EventManager
-	cursor   cursor.Model
-	width    int
-}
+	text               []rune
+	prompt             string
+	continuationPrompt string
+	position           int
+	keyMap             map[string]Binding
+	Events             *event.EventManager
+	cursor             cursor.Model
+	width              int
+	// notifications are printed above the prompt before the next redraw.
+	// They come from other goroutines, so they're guarded by mu.
+	mu            sync.Mutex
+	notifications []string
+}
+
+// NotifyMsg makes the editor redraw, printing the notifications it has.
+type NotifyMsg struct{}
 
 func New() *LineEditor {
 	editor := &LineEditor{
-		text:   []rune{},
-		prompt: DEFAULT_PROMPT,
-		keyMap: map[string]Binding{},
-		Events: event.New(),
-		cursor: cursor.New(),
+		text:               []rune{},
+		prompt:             DEFAULT_PROMPT,
+		continuationPrompt: DEFAULT_CONTINUATION_PROMPT,
+		keyMap:             map[string]Binding{},
+		Events:             event.New(),
+		cursor:             cursor.New(),
 	}
 
 	editor.cursor.TextStyle = lipgloss.NewStyle().
@@ -50,6 +62,16 @@ func (editor *LineEditor) Init() tea.Cmd {
 }
 
 func (editor *LineEditor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := editor.update(msg)
+
+	if notifications := editor.takeNotifications(); len(notifications) > 0 {
+		cmd = tea.Sequence(tea.Println(strings.Join(notifications, "\n")), cmd)
+	}
+
+	return model, cmd
+}
+
+func (editor *LineEditor) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		return editor.handleKey(msg)
@@ -60,16 +82,37 @@ func (editor *LineEditor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return editor, nil
 }
 
+// Notify queues message to be printed above the prompt, which happens on
+// the next update. It can be called from any goroutine, sending a NotifyMsg
+// afterwards shows it right away.
+func (e *LineEditor) Notify(message string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.notifications = append(e.notifications, message)
+}
+
+func (e *LineEditor) takeNotifications() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	notifications := e.notifications
+	e.notifications = nil
+
+	return notifications
+}
+
 func (e *LineEditor) View() string {
 	out := ""
 	e.setCursorChar()
 	rightOffset := e.position + 1
 
-	if e.position == len(e.text) {
-		rightOffset = len(e.text)
+	if e.position == len(e.text) || e.text[e.position] == '\n' {
+		rightOffset = e.position
 	}
 
-	out += e.prompt + string(e.text[:e.position]) + e.cursor.View() + string(e.text[rightOffset:])
+	out += e.prompt + e.continued(e.text[:e.position]) + e.cursor.View() +
+		e.continued(e.text[rightOffset:])
 
 	return lipgloss.NewStyle().Width(e.width).Render(out)
 }
@@ -89,8 +132,14 @@ func (editor *LineEditor) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return editor, nil
 }
 
+// continued puts the continuation prompt at the start of every line
+// after the first one.
+func (e *LineEditor) continued(text []rune) string {
+	return strings.ReplaceAll(string(text), "\n", "\n"+e.continuationPrompt)
+}
+
 func (e *LineEditor) setCursorChar() {
-	if e.position == len(e.text) {
+	if e.position == len(e.text) || e.text[e.position] == '\n' {
 		e.cursor.SetChar(" ")
 		return
 	}
@@ -127,6 +176,20 @@ func (e *LineEditor) SetPrompt(prompt string) {
 	e.prompt = prompt
 }
 
+func (e *LineEditor) ContinuationPrompt() string {
+	return e.continuationPrompt
+}
+
+func (e *LineEditor) SetContinuationPrompt(prompt string) {
+	e.continuationPrompt = prompt
+}
+
+// PromptedLine returns the text of the editor with the prompts in front of
+// each line, as it's shown to the user.
+func (e *LineEditor) PromptedLine() string {
+	return e.prompt + e.continued(e.text)
+}
+
 func (e *LineEditor) Width() int {
 	return e.width
 }
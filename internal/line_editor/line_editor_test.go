@@ -88,3 +88,62 @@ func TestDelete(t *testing.T) {
 	}
 
 }
+
+func TestPromptedLine(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{
+			"",
+			"$ ",
+		},
+		{
+			"ls",
+			"$ ls",
+		},
+		{
+			"cat <<EOF\nhello\nEOF",
+			"$ cat <<EOF\n> hello\n> EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		lineEditor := New()
+		lineEditor.SetLine(tt.text)
+
+		got := lineEditor.PromptedLine()
+		if tt.want != got {
+			t.Errorf("Want: '%s'\ngot: '%s'", tt.want, got)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	tests := []struct {
+		notifications []string
+		wantCmd       bool
+	}{
+		{[]string{}, false},
+		{[]string{"[1]+  Done                    sleep 1"}, true},
+		{[]string{"[1]  Done                    a", "[2]+  Stopped                 b"}, true},
+	}
+
+	for _, tt := range tests {
+		lineEditor := New()
+
+		for _, notification := range tt.notifications {
+			lineEditor.Notify(notification)
+		}
+
+		_, cmd := lineEditor.Update(NotifyMsg{})
+
+		if gotCmd := cmd != nil; gotCmd != tt.wantCmd {
+			t.Errorf("%q: want a command %t, got %t", tt.notifications, tt.wantCmd, gotCmd)
+		}
+
+		if _, cmd := lineEditor.Update(NotifyMsg{}); cmd != nil {
+			t.Errorf("%q: notifications were printed twice", tt.notifications)
+		}
+	}
+}